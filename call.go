@@ -0,0 +1,86 @@
+package revolver
+
+import (
+	"context"
+	"time"
+
+	fromprovider "github.com/grezar/revolver/provider/from"
+	toprovider "github.com/grezar/revolver/provider/to"
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/retry"
+	"github.com/grezar/revolver/schema"
+)
+
+// callFrom calls the from provider of the rotation. The attempts are not
+// reported if rptr is nil.
+func (r *Runner) callFrom(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, f func(ctx context.Context) error) error {
+	var retries func() int
+	if rt, ok := rn.From.Spec.Operator.(fromprovider.Retrier); ok {
+		rt.SetRetryPolicy(retryPolicy(rn.Retry, rn.From.Retry))
+		retries = rt.Retries
+	}
+	return r.call(ctx, rptr, rn.From.Provider, time.Duration(rn.From.Timeout), retries, f)
+}
+
+// callTo calls a to provider of the rotation.
+func (r *Runner) callTo(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, to *schema.To, f func(ctx context.Context) error) error {
+	var retries func() int
+	if rt, ok := to.Spec.Operator.(toprovider.Retrier); ok {
+		rt.SetRetryPolicy(retryPolicy(rn.Retry, to.Retry))
+		retries = rt.Retries
+	}
+	return r.call(ctx, rptr, to.Provider, time.Duration(to.Timeout), retries, f)
+}
+
+// call calls a provider and reports the number of attempts, which is more than
+// one if the provider retried its API requests. The call itself is never
+// retried, since it might have changed something before it failed. It is
+// limited by the timeout of the provider, and waits for a slot if the
+// concurrency of the provider is limited.
+func (r *Runner) call(ctx context.Context, rptr *reporting.R, provider string, timeout time.Duration, retries func() int, f func(ctx context.Context) error) error {
+	if slots := r.providerSlots[provider]; slots != nil {
+		slots <- struct{}{}
+		defer func() {
+			<-slots
+		}()
+	}
+	var before int
+	if retries != nil {
+		before = retries()
+	}
+	pctx, cancel := stepContext(ctx, timeout)
+	defer cancel()
+	err := f(pctx)
+	if rptr != nil {
+		attempts := 1
+		if retries != nil {
+			attempts += retries() - before
+		}
+		rptr.AddAttempts(attempts)
+	}
+	return err
+}
+
+// retryPolicy returns the retry policy of a provider. The fields set in a later
+// retry block take precedence.
+func retryPolicy(blocks ...*schema.Retry) retry.Policy {
+	p := retry.DefaultPolicy
+	for _, b := range blocks {
+		if b == nil {
+			continue
+		}
+		if b.MaxAttempts > 0 {
+			p.MaxAttempts = b.MaxAttempts
+		}
+		if b.InitialBackoff > 0 {
+			p.InitialBackoff = time.Duration(b.InitialBackoff)
+		}
+		if b.MaxBackoff > 0 {
+			p.MaxBackoff = time.Duration(b.MaxBackoff)
+		}
+		if b.Jitter != nil {
+			p.Jitter = *b.Jitter
+		}
+	}
+	return p
+}
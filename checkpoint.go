@@ -0,0 +1,144 @@
+package revolver
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/grezar/revolver/schema"
+	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/state"
+)
+
+// checkpointer saves the progress of a rotation run to the state store so that
+// the run can be resumed when it is interrupted. All methods are no-ops on a
+// nil checkpointer, which is used when checkpointing is disabled.
+type checkpointer struct {
+	mu       sync.Mutex
+	store    state.Store
+	key      []byte
+	rotation string
+	cp       *state.Checkpoint
+}
+
+func (r *Runner) newCheckpointer(rn *schema.Rotation) *checkpointer {
+	if r.state == nil || r.checkpointKey == nil {
+		return nil
+	}
+	return &checkpointer{
+		store:    r.state,
+		key:      r.checkpointKey,
+		rotation: rn.Name,
+	}
+}
+
+// destinationID identifies a destination of a rotation in a checkpoint.
+func destinationID(i int, to *schema.To) string {
+	return fmt.Sprintf("%d/%s", i, to.Provider)
+}
+
+// start saves a checkpoint for the secrets issued in this run.
+func (c *checkpointer) start(rn *schema.Rotation, s secrets.Secrets) {
+	if c == nil {
+		return
+	}
+	encrypted, err := secrets.Encrypt(c.key, s)
+	if err != nil {
+		log.Printf("failed to save the checkpoint of %s: %v", c.rotation, err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cp = &state.Checkpoint{
+		CreatedAt:        time.Now(),
+		SecretID:         secretID(rn, s),
+		EncryptedSecrets: encrypted,
+		Superseded:       superseded(rn),
+	}
+	c.save()
+}
+
+// cleanedUp records that the previous secrets have been cleaned up, so that a
+// resumed run doesn't clean them up again.
+func (c *checkpointer) cleanedUp() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cp == nil {
+		return
+	}
+	c.cp.CleanedUp = true
+	c.save()
+}
+
+// resume continues from the checkpoint saved by a previous run and returns the
+// secrets issued in that run.
+func (c *checkpointer) resume(cp *state.Checkpoint) (secrets.Secrets, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cp = cp
+	return secrets.Decrypt(c.key, cp.EncryptedSecrets)
+}
+
+// isDone reports whether the destination has been updated with the secrets in
+// the checkpoint.
+func (c *checkpointer) isDone(id string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cp == nil {
+		return false
+	}
+	for _, d := range c.cp.Destinations {
+		if d == id {
+			return true
+		}
+	}
+	return false
+}
+
+// done records that the destination has been updated.
+func (c *checkpointer) done(id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cp == nil {
+		return
+	}
+	c.cp.Destinations = append(c.cp.Destinations, id)
+	c.save()
+}
+
+// clear removes the checkpoint once the run has completed or been rolled
+// back.
+func (c *checkpointer) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cp == nil {
+		return
+	}
+	c.cp = nil
+	c.save()
+}
+
+func (c *checkpointer) save() {
+	rs, err := c.store.Load(c.rotation)
+	if err == nil {
+		rs.Checkpoint = c.cp
+		err = c.store.Save(c.rotation, rs)
+	}
+	if err != nil {
+		log.Printf("failed to save the checkpoint of %s: %v", c.rotation, err)
+	}
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/grezar/revolver"
+	"github.com/grezar/revolver/plan"
 	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/schema"
+	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/state"
 	"github.com/urfave/cli/v2"
 )
 
@@ -17,8 +26,85 @@ var (
 	Revision string
 )
 
+// Exit codes of the commands. A command which fails otherwise, e.g. for an
+// unknown flag, exits with exitFailed.
+const (
+	// exitFailed is the code when some rotations failed without leaving
+	// anything to be cleaned up.
+	exitFailed = 1
+	// exitConfigInvalid is the code when the configuration cannot be loaded.
+	exitConfigInvalid = 2
+	// exitFromFailed is the code when only from providers failed, so no
+	// secret was issued.
+	exitFromFailed = 3
+	// exitPartiallyDistributed is the code when a new secret was issued but
+	// some destinations were not updated with it.
+	exitPartiallyDistributed = 4
+)
+
+// Flags to select the rotations to run.
+var (
+	onlyFlag = &cli.StringSliceFlag{
+		Name:  "only",
+		Usage: "Run only the rotation named `NAME`. Can be repeated",
+	}
+	excludeFlag = &cli.StringSliceFlag{
+		Name:  "exclude",
+		Usage: "Don't run the rotation named `NAME`. Can be repeated",
+	}
+	selectorFlag = &cli.StringFlag{
+		Name:  "selector",
+		Usage: "Run only the rotations with all of the labels in `SELECTOR`, like env=prod,team=infra",
+	}
+)
+
+// Flags to write the report of a run or a plan.
+var (
+	reportFormatFlag = &cli.StringFlag{
+		Name:  "report-format",
+		Usage: "Write the report in `FORMAT`, one of table, json, junit or markdown",
+		Value: string(reporting.Table),
+	}
+	reportFileFlag = &cli.StringFlag{
+		Name:  "report-file",
+		Usage: "Write the report to `FILE` instead of stdout, where the table is shown",
+	}
+)
+
+// progressFlag streams the progress of a run to stderr, as the report is only
+// written once all rotations complete.
+var progressFlag = &cli.BoolFlag{
+	Name:  "progress",
+	Usage: "Show the progress of the rotations on stderr while they run",
+}
+
+// parallelismFlag limits the providers called at the same time.
+var parallelismFlag = &cli.IntFlag{
+	Name:  "parallelism",
+	Usage: "Call at most `N` providers at the same time",
+	Value: reporting.DefaultParallelism,
+}
+
+// timeoutFlag limits how long a run of rotations may take.
+var timeoutFlag = &cli.DurationFlag{
+	Name:  "timeout",
+	Usage: "Stop the rotations before their next step once `DURATION` has passed",
+}
+
 func main() {
-	app := &cli.App{
+	// Errors of the providers may contain the secrets, e.g. in a request body
+	// echoed by an API.
+	log.SetOutput(secrets.RedactingWriter(os.Stderr))
+
+	err := newApp().Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newApp returns the application with its commands.
+func newApp() *cli.App {
+	return &cli.App{
 		Commands: []*cli.Command{
 			{
 				Name:  "version",
@@ -43,26 +129,417 @@ func main() {
 						Aliases: []string{"d"},
 						Usage:   "Dry run",
 					},
+					&cli.StringFlag{
+						Name:  "state",
+						Usage: "Persist the state of rotations to `FILE`",
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Usage: "Resume the rotations interrupted in a previous run",
+					},
+					onlyFlag,
+					excludeFlag,
+					selectorFlag,
+					timeoutFlag,
+					reportFormatFlag,
+					reportFileFlag,
+					progressFlag,
+					parallelismFlag,
+				},
+				Action: func(c *cli.Context) error {
+					format, err := reporting.ParseFormat(c.String("report-format"))
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					opts, err := runnerOptions(c)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					filter, err := filterOption(c)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					opts = append(opts, filter)
+					if c.Bool("resume") {
+						opts = append(opts, revolver.WithResume())
+					}
+					runner, err := revolver.NewRunner(c.String("config"), c.Bool("dry-run"), opts...)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					return run(c, runner, format)
+				},
+			},
+			{
+				Name:  "validate",
+				Usage: "Check configured YAML without running rotations",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "config",
+						Aliases:  []string{"c"},
+						Usage:    "Load configuration from `FILE`",
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					path := c.String("config")
+					f, err := os.Open(path)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+
+					_, err = schema.LoadConfig(f)
+					var verr *schema.ValidationError
+					if errors.As(err, &verr) {
+						for _, p := range verr.Problems {
+							fmt.Printf("%s:%d:%d: %s\n", path, p.Line, p.Column, p.Message)
+						}
+						return cli.Exit(fmt.Sprintf("%d problems found in %s", len(verr.Problems), path), exitConfigInvalid)
+					}
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					fmt.Printf("%s is valid\n", path)
+					return nil
+				},
+			},
+			{
+				Name:  "plan",
+				Usage: "Show the changes rotations would make and save them to apply later",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "config",
+						Aliases:  []string{"c"},
+						Usage:    "Load configuration from `FILE`",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "state",
+						Usage: "Load the state of rotations from `FILE`",
+					},
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "Save the plan to `FILE`",
+					},
+					onlyFlag,
+					excludeFlag,
+					selectorFlag,
+					reportFormatFlag,
+					reportFileFlag,
+				},
+				Action: func(c *cli.Context) error {
+					format, err := reporting.ParseFormat(c.String("report-format"))
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					opts, err := runnerOptions(c)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					filter, err := filterOption(c)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					opts = append(opts, filter)
+					runner, err := revolver.NewRunner(c.String("config"), true, opts...)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					p, err := runner.Plan(c.Context)
+					if err != nil {
+						return err
+					}
+					if err := writeReport(c, func(w io.Writer) error {
+						if w != os.Stdout {
+							reporting.RenderPlan(os.Stdout, p)
+						}
+						return reporting.WritePlan(w, p, format)
+					}); err != nil {
+						return err
+					}
+
+					if c.String("out") == "" {
+						return nil
+					}
+					f, err := os.Create(c.String("out"))
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					return plan.Save(f, p)
+				},
+			},
+			{
+				Name:      "apply",
+				Usage:     "Rotate secrets as planned by the plan command",
+				ArgsUsage: "PLAN",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "state",
+						Usage: "Persist the state of rotations to `FILE`",
+					},
+					timeoutFlag,
+					reportFormatFlag,
+					reportFileFlag,
+					progressFlag,
+					parallelismFlag,
 				},
 				Action: func(c *cli.Context) error {
-					runner, err := revolver.NewRunner(c.String("config"), c.Bool("dry-run"))
+					if c.NArg() != 1 {
+						return errors.New("the plan file must be specified")
+					}
+					format, err := reporting.ParseFormat(c.String("report-format"))
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					f, err := os.Open(c.Args().First())
 					if err != nil {
 						return err
 					}
-					ok := reporting.Run(func(rptr *reporting.R) {
-						runner.Run(rptr)
-					})
-					if !ok {
-						return errors.New("failed to execute rotations")
+					defer f.Close()
+					p, err := plan.Load(f)
+					if err != nil {
+						return err
+					}
+
+					opts, err := runnerOptions(c)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					// Only the planned rotations are applied.
+					var planned []string
+					for _, rn := range p.Rotations {
+						planned = append(planned, rn.Name)
 					}
+					opts = append(opts, revolver.WithFilter(revolver.Filter{Only: planned}))
+					runner, err := revolver.NewRunner(p.Config, false, opts...)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					if err := runner.CheckPlan(c.Context, p); err != nil {
+						return fmt.Errorf("refused to apply the plan: %w", err)
+					}
+					return run(c, runner, format)
+				},
+			},
+			{
+				Name:  "serve",
+				Usage: "Run rotations on their schedules",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "config",
+						Aliases:  []string{"c"},
+						Usage:    "Load configuration from `FILE`",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "state",
+						Usage:    "Save the state of rotations to `FILE`",
+						Required: true,
+					},
+					timeoutFlag,
+					parallelismFlag,
+				},
+				Action: func(c *cli.Context) error {
+					opts, err := runnerOptions(c)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					// A rotation interrupted by a restart is resumed rather than
+					// rotated again.
+					if _, ok := os.LookupEnv("REVOLVER_CHECKPOINT_KEY"); ok {
+						opts = append(opts, revolver.WithResume())
+					}
+					server, err := revolver.NewServer(c.String("config"), opts...)
+					if err != nil {
+						return cli.Exit(err, exitConfigInvalid)
+					}
+					server.Parallelism = c.Int("parallelism")
+
+					ctx, stop := signalContext(c.Context)
+					defer stop()
+
+					hup := make(chan os.Signal, 1)
+					signal.Notify(hup, syscall.SIGHUP)
+					defer signal.Stop(hup)
+					go func() {
+						for {
+							select {
+							case <-hup:
+								if err := server.Reload(); err != nil {
+									log.Printf("failed to reload the configuration: %v", err)
+									continue
+								}
+								log.Printf("reloaded the configuration")
+							case <-ctx.Done():
+								return
+							}
+						}
+					}()
+
+					return server.Serve(ctx)
+				},
+			},
+			{
+				Name:  "history",
+				Usage: "Show the history of rotations",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "state",
+						Usage:    "Load the state of rotations from `FILE`",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "rotation",
+						Aliases: []string{"r"},
+						Usage:   "Show only the history of the rotation named `NAME`",
+					},
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"n"},
+						Usage:   "Show only the last `N` runs of each rotation",
+						Value:   10,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					store := state.NewFileStore(c.String("state"))
+					rotations := []string{c.String("rotation")}
+					if c.String("rotation") == "" {
+						var err error
+						rotations, err = store.Rotations()
+						if err != nil {
+							return err
+						}
+					}
+
+					history := make(map[string][]*state.Run)
+					for _, name := range rotations {
+						rs, err := store.Load(name)
+						if err != nil {
+							return err
+						}
+						runs := rs.History
+						if limit := c.Int("limit"); limit > 0 && len(runs) > limit {
+							runs = runs[len(runs)-limit:]
+						}
+						history[name] = runs
+					}
+					reporting.RenderHistory(os.Stdout, rotations, history)
 					return nil
 				},
 			},
 		},
 	}
+}
 
-	err := app.Run(os.Args)
+// runnerOptions returns the options of the runner common to the commands which
+// run rotations.
+func runnerOptions(c *cli.Context) ([]revolver.Option, error) {
+	var opts []revolver.Option
+	if c.String("state") != "" {
+		opts = append(opts, revolver.WithStateStore(state.NewFileStore(c.String("state"))))
+	}
+	if d := c.Duration("timeout"); d > 0 {
+		opts = append(opts, revolver.WithTimeout(d))
+	}
+	if v, ok := os.LookupEnv("REVOLVER_CHECKPOINT_KEY"); ok {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REVOLVER_CHECKPOINT_KEY: %w", err)
+		}
+		opts = append(opts, revolver.WithCheckpointKey(key))
+	}
+	return opts, nil
+}
+
+func run(c *cli.Context, runner *revolver.Runner, format reporting.Format) error {
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	var result *reporting.Result
+	err := writeReport(c, func(w io.Writer) error {
+		opts := []reporting.Option{reporting.WithReport(w, format)}
+		if w != os.Stdout {
+			opts = append(opts, reporting.WithReport(os.Stdout, reporting.Table))
+		}
+		opts = append(opts, reporting.WithParallelism(c.Int("parallelism")))
+		if c.Bool("progress") {
+			opts = append(opts, reporting.WithProgress(os.Stderr))
+		}
+		reporting.Run(func(rptr *reporting.R) {
+			rptr.Cleanup(func() {
+				result = rptr.Result()
+			})
+			runner.RunContext(ctx, rptr)
+		}, opts...)
+		return nil
+	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+
+	// The summary tells on-call whether anything has to be cleaned up by
+	// hand, and is written to stderr not to mix with a report on stdout.
+	summary := revolver.Summarize(result)
+	summary.Write(os.Stderr)
+	return summaryError(summary)
+}
+
+// summaryError returns the error to exit with the code of the failures of a
+// run, or nil if all rotations succeeded.
+func summaryError(s *revolver.Summary) error {
+	switch s.Kind() {
+	case revolver.NotFailed:
+		return nil
+	case revolver.FromFailed:
+		return cli.Exit("failed to issue new secrets", exitFromFailed)
+	case revolver.PartiallyDistributed:
+		return cli.Exit("failed to distribute new secrets", exitPartiallyDistributed)
+	default:
+		return cli.Exit("failed to execute rotations", exitFailed)
+	}
+}
+
+// writeReport calls write with the file given by --report-file, or stdout.
+// The file is written even if the rotations fail, so that CI can show them.
+func writeReport(c *cli.Context, write func(w io.Writer) error) error {
+	path := c.String("report-file")
+	if path == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// filterOption returns the option to run the rotations selected by the flags.
+func filterOption(c *cli.Context) (revolver.Option, error) {
+	selector, err := revolver.ParseSelector(c.String("selector"))
+	if err != nil {
+		return nil, err
+	}
+	return revolver.WithFilter(revolver.Filter{
+		Only:     c.StringSlice("only"),
+		Exclude:  c.StringSlice("exclude"),
+		Selector: selector,
+	}), nil
+}
+
+// signalContext returns a context which is canceled on SIGINT or SIGTERM so
+// that the rotations stop at a safe point. Another signal terminates the
+// process as usual in case a provider doesn't return.
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestApp_ExitCode(t *testing.T) {
+	osExiter, errWriter := cli.OsExiter, cli.ErrWriter
+	cli.OsExiter, cli.ErrWriter = func(int) {}, io.Discard
+	t.Cleanup(func() {
+		cli.OsExiter, cli.ErrWriter = osExiter, errWriter
+	})
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{
+			name: "Invalid report format of rotate",
+			args: []string{"rotate", "--config", "../../testdata/valid.yml", "--report-format", "xml"},
+			want: exitConfigInvalid,
+		},
+		{
+			name: "Invalid report format of plan",
+			args: []string{"plan", "--config", "../../testdata/valid.yml", "--report-format", "xml"},
+			want: exitConfigInvalid,
+		},
+		{
+			name: "Invalid report format of apply",
+			args: []string{"apply", "--report-format", "xml", "plan.json"},
+			want: exitConfigInvalid,
+		},
+		{
+			name: "Invalid selector",
+			args: []string{"rotate", "--config", "../../testdata/valid.yml", "--selector", "=="},
+			want: exitConfigInvalid,
+		},
+		{
+			name: "Unknown rotation",
+			args: []string{"rotate", "--config", "../../testdata/valid.yml", "--dry-run", "--only", "Unknown"},
+			want: exitConfigInvalid,
+		},
+		{
+			name: "Invalid configuration",
+			args: []string{"validate", "--config", "../../testdata/invalid.yml"},
+			want: exitConfigInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newApp().Run(append([]string{"revolver"}, tt.args...))
+			var exitErr cli.ExitCoder
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("Run() error = %v, want an exit code", err)
+			}
+			if got := exitErr.ExitCode(); got != tt.want {
+				t.Errorf("exit code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package revolver
+
+import (
+	"context"
+	"time"
+
+	"github.com/grezar/revolver/reporting"
+)
+
+// cancelled reports the step as cancelled if the run has been cancelled or has
+// timed out, so that no step starts after that.
+func cancelled(ctx context.Context, rptr *reporting.R) bool {
+	if err := ctx.Err(); err != nil {
+		rptr.Cancel(err)
+		return true
+	}
+	return false
+}
+
+// stepContext returns the context of a call to a provider. A call which has
+// started is not interrupted when the run is cancelled, since it may leave the
+// secret half rotated, e.g. between deleting an access key and creating a new
+// one. It is interrupted only by the timeout of the provider, if any.
+func stepContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if ctx.Done() != nil {
+		ctx = detachedContext{ctx}
+	}
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// detachedContext keeps the values of its parent but is never cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
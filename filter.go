@@ -0,0 +1,102 @@
+package revolver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/grezar/revolver/schema"
+)
+
+// Filter selects the rotations to run. The zero value selects all rotations.
+type Filter struct {
+	// Only selects the rotations with the names, if any.
+	Only []string
+	// Exclude deselects the rotations with the names.
+	Exclude []string
+	// Selector selects the rotations which have all of the labels.
+	Selector map[string]string
+}
+
+// WithFilter makes the runner run only the rotations selected by the filter.
+// A rotation depending on a rotation which is not selected doesn't wait for
+// it.
+func WithFilter(f Filter) Option {
+	return func(r *Runner) {
+		r.filter = f
+	}
+}
+
+// ParseSelector parses a label selector like "env=prod,team=infra".
+func ParseSelector(s string) (map[string]string, error) {
+	selector := make(map[string]string)
+	if s == "" {
+		return selector, nil
+	}
+	for _, term := range strings.Split(s, ",") {
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid selector %q: labels must be in the form of key=value", term)
+		}
+		selector[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return selector, nil
+}
+
+func (f Filter) isZero() bool {
+	return len(f.Only) == 0 && len(f.Exclude) == 0 && len(f.Selector) == 0
+}
+
+// Match reports whether the filter selects the rotation.
+func (f Filter) Match(rn *schema.Rotation) bool {
+	if len(f.Only) > 0 && !contains(f.Only, rn.Name) {
+		return false
+	}
+	if contains(f.Exclude, rn.Name) {
+		return false
+	}
+	for k, v := range f.Selector {
+		if l, ok := rn.Labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	return true
+}
+
+// apply returns the rotations selected by the filter. The rotations named in
+// the filter must exist so that a typo doesn't silently select nothing.
+func (f Filter) apply(rotations []*schema.Rotation) ([]*schema.Rotation, error) {
+	if f.isZero() {
+		return rotations, nil
+	}
+
+	names := make(map[string]bool)
+	for _, rn := range rotations {
+		names[rn.Name] = true
+	}
+	for _, name := range append(append([]string{}, f.Only...), f.Exclude...) {
+		if !names[name] {
+			return nil, fmt.Errorf("unknown rotation: %s", name)
+		}
+	}
+
+	var selected []*schema.Rotation
+	for _, rn := range rotations {
+		if f.Match(rn) {
+			selected = append(selected, rn)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no rotations match the filter")
+	}
+	return selected, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
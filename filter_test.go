@@ -0,0 +1,115 @@
+package revolver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/grezar/revolver/schema"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			selector: "",
+			want:     map[string]string{},
+		},
+		{
+			selector: "env=prod,team=infra",
+			want:     map[string]string{"env": "prod", "team": "infra"},
+		},
+		{
+			selector: "env = prod , team=",
+			want:     map[string]string{"env": "prod", "team": ""},
+		},
+		{
+			selector: "env",
+			wantErr:  true,
+		},
+		{
+			selector: "=prod",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			got, err := ParseSelector(tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_apply(t *testing.T) {
+	rotations := []*schema.Rotation{
+		{Name: "a", Labels: map[string]string{"env": "prod", "team": "infra"}},
+		{Name: "b", Labels: map[string]string{"env": "prod", "team": "app"}},
+		{Name: "c", Labels: map[string]string{"env": "dev", "team": "infra"}},
+		{Name: "d"},
+	}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "Select all rotations by default",
+			filter: Filter{},
+			want:   []string{"a", "b", "c", "d"},
+		},
+		{
+			name:   "Select by names",
+			filter: Filter{Only: []string{"b", "d"}},
+			want:   []string{"b", "d"},
+		},
+		{
+			name:   "Exclude by names",
+			filter: Filter{Exclude: []string{"a"}},
+			want:   []string{"b", "c", "d"},
+		},
+		{
+			name:   "Select by labels",
+			filter: Filter{Selector: map[string]string{"env": "prod", "team": "infra"}},
+			want:   []string{"a"},
+		},
+		{
+			name:   "Combine the conditions",
+			filter: Filter{Exclude: []string{"a"}, Selector: map[string]string{"env": "prod"}},
+			want:   []string{"b"},
+		},
+		{
+			name:    "Unknown rotation",
+			filter:  Filter{Only: []string{"x"}},
+			wantErr: true,
+		},
+		{
+			name:    "No rotations match",
+			filter:  Filter{Selector: map[string]string{"env": "staging"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := tt.filter.apply(rotations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Filter.apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, rn := range selected {
+				got = append(got, rn.Name)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter.apply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package revolver
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/grezar/revolver/notify"
+	"github.com/grezar/revolver/reporting"
+)
+
+// notifyTimeout limits how long sending the notifications may take.
+const notifyTimeout = time.Minute
+
+// notify sends the outcome of the completed run reported by rptr to the
+// notification sinks. A sink which fails is logged rather than failing the
+// run, since the rotations are done by then.
+func (r *Runner) notify(rptr *reporting.R) {
+	var summary strings.Builder
+	Summarize(rptr.Result()).Write(&summary)
+	n, err := notify.NewNotification(rptr, summary.String())
+	if err == nil {
+		// The notifications are sent even if the run was cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		err = notify.Send(ctx, r.notifications, n)
+	}
+	if err != nil {
+		log.Printf("failed to send notifications: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Package notify sends the outcome of a run of rotations to Slack, HTTP
+// webhooks and email.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/secrets"
+	"github.com/pkg/errors"
+)
+
+// Trigger tells which outcomes of a run a sink is notified of.
+type Trigger string
+
+const (
+	OnFailure Trigger = "failure"
+	OnSuccess Trigger = "success"
+	Always    Trigger = "always"
+)
+
+// Keys are the values of a notification which the templates may refer to.
+var Keys = []string{
+	// Status is SUCCESS, or ERROR if any rotation failed.
+	"Status",
+	// Headline is the first line of Summary, like "1 of 3 rotations failed".
+	"Headline",
+	// Summary lists the failed rotations and what is left to be done.
+	"Summary",
+	// Failed is the names of the failed rotations separated by commas.
+	"Failed",
+	// DryRun is "true" in dry-run mode.
+	"DryRun",
+	// Table, Markdown and JSON are the report in the formats.
+	"Table",
+	"Markdown",
+	"JSON",
+}
+
+// Sink is where the outcome of a run is sent to. Exactly one of Slack, Webhook
+// and SMTP is set.
+type Sink struct {
+	// On is when the sink is notified. It is OnFailure if empty.
+	On      Trigger  `yaml:"on"`
+	Slack   *Slack   `yaml:"slack"`
+	Webhook *Webhook `yaml:"webhook"`
+	SMTP    *SMTP    `yaml:"smtp"`
+}
+
+// sender sends the notification rendered with its templates.
+type sender interface {
+	name() string
+	send(ctx context.Context, data secrets.Secrets) error
+	validate() error
+	templates() []string
+}
+
+func (s *Sink) sender() (sender, error) {
+	var senders []sender
+	if s.Slack != nil {
+		senders = append(senders, s.Slack)
+	}
+	if s.Webhook != nil {
+		senders = append(senders, s.Webhook)
+	}
+	if s.SMTP != nil {
+		senders = append(senders, s.SMTP)
+	}
+	if len(senders) != 1 {
+		return nil, errors.New("exactly one of slack, webhook and smtp must be set")
+	}
+	return senders[0], nil
+}
+
+// Validate returns an error if the sink is missing a required field or its
+// templates refer to an unknown value.
+func (s *Sink) Validate() error {
+	switch s.On {
+	case "", OnFailure, OnSuccess, Always:
+	default:
+		return errors.Errorf("unsupported trigger %q. Only %q, %q or %q are available", s.On, OnFailure, OnSuccess, Always)
+	}
+	snd, err := s.sender()
+	if err != nil {
+		return err
+	}
+	if err := snd.validate(); err != nil {
+		return errors.Wrap(err, snd.name())
+	}
+	for _, tmpl := range snd.templates() {
+		if err := secrets.ValidateTemplate(tmpl, Keys); err != nil {
+			return errors.Wrap(err, snd.name())
+		}
+	}
+	return nil
+}
+
+// triggered returns true if the sink is notified of the outcome.
+func (s *Sink) triggered(failed bool) bool {
+	switch s.On {
+	case Always:
+		return true
+	case OnSuccess:
+		return !failed
+	default:
+		return failed
+	}
+}
+
+// Notification is the outcome of a run to be sent to the sinks.
+type Notification struct {
+	Failed bool
+	Data   secrets.Secrets
+}
+
+// NewNotification builds the notification of the completed run reported by r,
+// with the summary of its failures. The report is redacted, so that it holds
+// no secrets.
+func NewNotification(r *reporting.R, summary string) (*Notification, error) {
+	result := r.Result()
+	n := &Notification{
+		Failed: r.Failed(),
+		Data: secrets.Secrets{
+			"Status":   reporting.Success,
+			"Headline": strings.TrimSuffix(strings.SplitN(summary, "\n", 2)[0], ":"),
+			"Summary":  summary,
+			"DryRun":   strconv.FormatBool(result.DryRun),
+		},
+	}
+	if n.Failed {
+		n.Data["Status"] = reporting.Error
+	}
+	var failed []string
+	for _, rn := range result.Children {
+		if outcome := rn.Outcome(); outcome == reporting.Error || outcome == reporting.Cancelled {
+			failed = append(failed, rn.Name)
+		}
+	}
+	n.Data["Failed"] = strings.Join(failed, ", ")
+
+	for key, format := range map[string]reporting.Format{
+		"Table":    reporting.Table,
+		"Markdown": reporting.Markdown,
+		"JSON":     reporting.JSON,
+	} {
+		var b strings.Builder
+		if err := r.Report(&b, format); err != nil {
+			return nil, err
+		}
+		n.Data[key] = b.String()
+	}
+	for key, v := range n.Data {
+		n.Data[key] = secrets.Redact(v)
+	}
+	return n, nil
+}
+
+// Send sends the notification to the sinks triggered by its outcome. It tries
+// every sink, and returns the errors of the ones which failed.
+func Send(ctx context.Context, sinks []*Sink, n *Notification) error {
+	var msgs []string
+	for i, s := range sinks {
+		if !s.triggered(n.Failed) {
+			continue
+		}
+		snd, err := s.sender()
+		if err == nil {
+			err = snd.send(ctx, n.Data)
+		}
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("notifications[%d]: %s", i, secrets.Redact(err.Error())))
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// render executes the template with the values of the notification.
+func render(tmpl string, data secrets.Secrets) (string, error) {
+	return secrets.Render(tmpl, data)
+}
+
+// orDefault returns the template, or def if it is empty.
+func orDefault(tmpl, def string) string {
+	if tmpl == "" {
+		return def
+	}
+	return tmpl
+}
+
+// expand replaces ${VAR} in the value with the environment variable, so that
+// credentials like a webhook URL needn't be written in the configuration.
+func expand(v string) string {
+	return os.ExpandEnv(v)
+}
@@ -0,0 +1,279 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/secrets"
+)
+
+func testNotification(t *testing.T, failed bool) *Notification {
+	t.Helper()
+	secrets.Register(secrets.Secrets{"KEY": "notify-test-secret"})
+	var root *reporting.R
+	reporting.Run(func(r *reporting.R) {
+		root = r
+		r.Run("Rotation", func(r *reporting.R) {
+			r.Run("From/Mock", func(r *reporting.R) {
+				r.SetStatus(reporting.Created)
+			})
+			r.Run("To/Mock", func(r *reporting.R) {
+				if failed {
+					r.Fail(errors.New("rejected notify-test-secret"))
+					return
+				}
+				r.SetStatus(reporting.Updated)
+			})
+		})
+	}, reporting.WithReport(io.Discard, reporting.JSON))
+
+	summary := "All 1 rotations succeeded\n"
+	if failed {
+		summary = "1 of 1 rotations failed:\n  Rotation: To/Mock failed: rejected notify-test-secret\n"
+	}
+	n, err := NewNotification(root, summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestNewNotification(t *testing.T) {
+	n := testNotification(t, true)
+	if !n.Failed {
+		t.Error("Failed = false, want true")
+	}
+	want := map[string]string{
+		"Status":   reporting.Error,
+		"Headline": "1 of 1 rotations failed",
+		"Failed":   "Rotation",
+		"DryRun":   "false",
+	}
+	for k, v := range want {
+		if n.Data[k] != v {
+			t.Errorf("%s = %q, want %q", k, n.Data[k], v)
+		}
+	}
+	for _, k := range Keys {
+		if _, ok := n.Data[k]; !ok {
+			t.Errorf("%s is missing", k)
+		}
+		if strings.Contains(n.Data[k], "notify-test-secret") {
+			t.Errorf("%s = %q, which contains the secret", k, n.Data[k])
+		}
+	}
+}
+
+func TestSend_Webhooks(t *testing.T) {
+	type request struct {
+		path, auth, contentType, body string
+	}
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		requests <- request{r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Content-Type"), string(b)}
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("NOTIFY_TEST_TOKEN", "token")
+
+	sinks := []*Sink{
+		{Slack: &Slack{WebhookURL: srv.URL + "/slack", Text: "{{ .Status }}: {{ .Failed }}"}},
+		{On: Always, Webhook: &Webhook{
+			URL:     srv.URL + "/hook",
+			Headers: map[string]string{"Authorization": "Bearer ${NOTIFY_TEST_TOKEN}"},
+			Body:    `{"status": {{ toJson .Status }}}`,
+		}},
+		{On: OnSuccess, Webhook: &Webhook{URL: srv.URL + "/success"}},
+	}
+	if err := Send(context.Background(), sinks, testNotification(t, true)); err != nil {
+		t.Fatal(err)
+	}
+	close(requests)
+
+	var got []request
+	for r := range requests {
+		got = append(got, r)
+	}
+	if len(got) != 2 {
+		t.Fatalf("requests = %v, want 2 requests", got)
+	}
+	var slack map[string]string
+	if err := json.Unmarshal([]byte(got[0].body), &slack); err != nil {
+		t.Fatal(err)
+	}
+	if got[0].path != "/slack" || slack["text"] != "ERROR: Rotation" {
+		t.Errorf("slack request = %+v", got[0])
+	}
+	want := request{"/hook", "Bearer token", "application/json", `{"status": "ERROR"}`}
+	if got[1] != want {
+		t.Errorf("webhook request = %+v, want %+v", got[1], want)
+	}
+
+	// The default body of a webhook is the JSON report.
+	requests = make(chan request, 10)
+	sinks = []*Sink{{On: OnSuccess, Webhook: &Webhook{URL: srv.URL + "/success"}}}
+	if err := Send(context.Background(), sinks, testNotification(t, false)); err != nil {
+		t.Fatal(err)
+	}
+	r := <-requests
+	var report struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(r.body), &report); err != nil || report.Status != reporting.Success {
+		t.Errorf("webhook body = %s, %v", r.body, err)
+	}
+
+	// A sink which fails doesn't stop the others.
+	sinks = []*Sink{
+		{Webhook: &Webhook{URL: srv.URL + "/broken"}},
+		{Webhook: &Webhook{URL: srv.URL + "/hook"}},
+	}
+	err := Send(context.Background(), sinks, testNotification(t, true))
+	if wantErr := "notifications[0]: POST request failed: 400 Bad Request"; err == nil || err.Error() != wantErr {
+		t.Errorf("Send() error = %v, want %s", err, wantErr)
+	}
+	if r := <-requests; r.path != "/broken" {
+		t.Errorf("path = %s, want /broken", r.path)
+	}
+	if r := <-requests; r.path != "/hook" {
+		t.Errorf("path = %s, want /hook", r.path)
+	}
+}
+
+// smtpServer accepts an email and sends the message to the channel.
+func smtpServer(t *testing.T) (string, int, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+	})
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 localhost")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 localhost")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				b, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				messages <- string(b)
+				_ = tp.PrintfLine("250 OK")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				return
+			default:
+				_ = tp.PrintfLine("250 OK")
+			}
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p, messages
+}
+
+func TestSend_SMTP(t *testing.T) {
+	host, port, messages := smtpServer(t)
+	sinks := []*Sink{{SMTP: &SMTP{
+		Host: host,
+		Port: port,
+		From: "revolver@example.com",
+		To:   []string{"oncall@example.com"},
+		Body: "{{ .Summary }}",
+	}}}
+	if err := Send(context.Background(), sinks, testNotification(t, true)); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-messages
+	for _, want := range []string{
+		"From: revolver@example.com\n",
+		"To: oncall@example.com\n",
+		"Subject: [revolver] 1 of 1 rotations failed\n",
+		"\n\n1 of 1 rotations failed:\n  Rotation: To/Mock failed: rejected [REDACTED]\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message = %q, want to contain %q", msg, want)
+		}
+	}
+}
+
+func TestSink_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sink    *Sink
+		wantErr string
+	}{
+		{
+			name: "Valid sink",
+			sink: &Sink{On: Always, Slack: &Slack{WebhookURL: "http://localhost", Text: "{{ .Headline }}"}},
+		},
+		{
+			name:    "Two sinks",
+			sink:    &Sink{Slack: &Slack{WebhookURL: "http://localhost"}, Webhook: &Webhook{URL: "http://localhost"}},
+			wantErr: "exactly one of slack, webhook and smtp must be set",
+		},
+		{
+			name:    "Missing required field",
+			sink:    &Sink{SMTP: &SMTP{Host: "localhost", From: "revolver@example.com"}},
+			wantErr: "smtp: to is required",
+		},
+		{
+			name:    "Invalid template",
+			sink:    &Sink{Webhook: &Webhook{URL: "http://localhost", Body: "{{ .Status"}},
+			wantErr: "webhook: template: :1: unclosed action",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sink.Validate()
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("Validate() error = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSMTP_Message(t *testing.T) {
+	s := &SMTP{From: "a@example.com", To: []string{"b@example.com", "c@example.com"}}
+	got := s.message("multi\nline", "body\nline")
+	want := "From: a@example.com\r\nTo: b@example.com, c@example.com\r\nSubject: multi line\r\n" +
+		"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\nbody\r\nline"
+	if !bytes.Equal(got, []byte(want)) {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"github.com/grezar/revolver/secrets"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSMTPPort    = 587
+	defaultSMTPSubject = "[revolver] {{ .Headline }}"
+	defaultSMTPBody    = "{{ .Summary }}\n{{ .Table }}"
+)
+
+// SMTP sends the notification by email. The connection is upgraded with
+// STARTTLS if the server supports it. Unlike the webhooks, sending an email
+// isn't interrupted when the context is done.
+type SMTP struct {
+	Host string `yaml:"host"`
+	// Port is 587 if zero.
+	Port int `yaml:"port"`
+	// Username and Password authenticate with PLAIN if Username is set. ${VAR}
+	// in Password is replaced with the environment variable.
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	// Subject and Body are the templates of the email.
+	Subject string `yaml:"subject"`
+	Body    string `yaml:"body"`
+}
+
+func (s *SMTP) name() string {
+	return "smtp"
+}
+
+func (s *SMTP) validate() error {
+	switch {
+	case s.Host == "":
+		return errors.New("host is required")
+	case s.From == "":
+		return errors.New("from is required")
+	case len(s.To) == 0:
+		return errors.New("to is required")
+	}
+	return nil
+}
+
+func (s *SMTP) templates() []string {
+	return []string{s.Subject, s.Body}
+}
+
+func (s *SMTP) send(ctx context.Context, data secrets.Secrets) error {
+	subject, err := render(orDefault(s.Subject, defaultSMTPSubject), data)
+	if err != nil {
+		return err
+	}
+	body, err := render(orDefault(s.Body, defaultSMTPBody), data)
+	if err != nil {
+		return err
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, expand(s.Password), s.Host)
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+	return smtp.SendMail(addr, auth, s.From, s.To, s.message(subject, body))
+}
+
+// message returns the email with its headers. The subject is folded into one
+// line.
+func (s *SMTP) message(subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.Join(strings.Fields(subject), " "))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
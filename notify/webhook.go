@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/grezar/revolver/retry"
+	"github.com/grezar/revolver/secrets"
+	"github.com/pkg/errors"
+)
+
+const defaultSlackText = "{{ .Headline }}\n```\n{{ .Table }}```"
+
+// Slack posts the notification to a Slack incoming webhook.
+type Slack struct {
+	// WebhookURL is the URL of the incoming webhook. ${VAR} is replaced with
+	// the environment variable.
+	WebhookURL string `yaml:"webhookURL"`
+	// Text is the template of the message.
+	Text string `yaml:"text"`
+}
+
+func (s *Slack) name() string {
+	return "slack"
+}
+
+func (s *Slack) validate() error {
+	if s.WebhookURL == "" {
+		return errors.New("webhookURL is required")
+	}
+	return nil
+}
+
+func (s *Slack) templates() []string {
+	return []string{s.Text}
+}
+
+func (s *Slack) send(ctx context.Context, data secrets.Secrets) error {
+	text, err := render(orDefault(s.Text, defaultSlackText), data)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return post(ctx, http.MethodPost, expand(s.WebhookURL), http.Header{"Content-Type": {"application/json"}}, body)
+}
+
+// Webhook sends the notification to an HTTP endpoint.
+type Webhook struct {
+	// URL is the URL of the endpoint. ${VAR} is replaced with the environment
+	// variable, as in the values of Headers.
+	URL    string `yaml:"url"`
+	Method string `yaml:"method"`
+	// Headers are added to the request, e.g. for authorization. The
+	// Content-Type is application/json unless given.
+	Headers map[string]string `yaml:"headers"`
+	// Body is the template of the body, which is the JSON report by default.
+	Body string `yaml:"body"`
+}
+
+func (w *Webhook) name() string {
+	return "webhook"
+}
+
+func (w *Webhook) validate() error {
+	if w.URL == "" {
+		return errors.New("url is required")
+	}
+	return nil
+}
+
+func (w *Webhook) templates() []string {
+	return []string{w.Body}
+}
+
+func (w *Webhook) send(ctx context.Context, data secrets.Secrets) error {
+	body, err := render(orDefault(w.Body, "{{ .JSON }}"), data)
+	if err != nil {
+		return err
+	}
+	headers := http.Header{"Content-Type": {"application/json"}}
+	for k, v := range w.Headers {
+		headers.Set(k, expand(v))
+	}
+	method := w.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	return post(ctx, method, expand(w.URL), headers, []byte(body))
+}
+
+var httpClient = &http.Client{Transport: &retry.Transport{}}
+
+// post sends the request, which is retried if it was rate limited, or failed
+// with a server error and its method is idempotent. The errors don't show the
+// URL, as the one of a Slack webhook is a credential.
+func post(ctx context.Context, method, rawURL string, headers http.Header, body []byte) error {
+	err := request(ctx, method, rawURL, headers, body)
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err
+	}
+	return err
+}
+
+func request(ctx context.Context, method, rawURL string, headers http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = headers
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s request failed: %s", method, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package revolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grezar/revolver/plan"
+	fromprovider "github.com/grezar/revolver/provider/from"
+	toprovider "github.com/grezar/revolver/provider/to"
+	"github.com/grezar/revolver/schema"
+	str2duration "github.com/xhit/go-str2duration/v2"
+)
+
+// Plan returns the changes the rotations would make without making them.
+func (r *Runner) Plan(ctx context.Context) (*plan.Plan, error) {
+	p := &plan.Plan{
+		Version:      plan.Version,
+		CreatedAt:    time.Now(),
+		Config:       r.config,
+		ConfigDigest: r.configDigest,
+	}
+	for _, rn := range r.rotations {
+		pr, err := r.planRotation(ctx, rn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan %s: %w", rn.Name, err)
+		}
+		p.Rotations = append(p.Rotations, pr)
+	}
+	return p, nil
+}
+
+// CheckPlan returns an error if the configuration or the live state has
+// drifted since the plan was made, so that the rotations make exactly the
+// planned changes.
+func (r *Runner) CheckPlan(ctx context.Context, p *plan.Plan) error {
+	if p.ConfigDigest != r.configDigest {
+		return errors.New("the configuration has changed since the plan was made")
+	}
+	live, err := r.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	if diffs := plan.Diff(p, live); len(diffs) > 0 {
+		return fmt.Errorf("the live state has drifted since the plan was made:\n%s", strings.Join(diffs, "\n"))
+	}
+	return nil
+}
+
+func (r *Runner) planRotation(ctx context.Context, rn *schema.Rotation) (*plan.Rotation, error) {
+	pr := &plan.Rotation{Name: rn.Name}
+
+	changes, err := r.planRetirement(rn)
+	if err != nil {
+		return nil, err
+	}
+	from := &plan.Step{
+		Provider: fmt.Sprintf("From/%s", rn.From.Provider),
+		Changes:  changes,
+	}
+	if p, ok := rn.From.Spec.Operator.(fromprovider.Planner); ok {
+		step, err := p.Plan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		from.Planned = true
+		from.Changes = append(from.Changes, step.Changes...)
+		from.Observed = step.Observed
+		for _, c := range step.Changes {
+			if c.Action == plan.Create {
+				pr.Due = true
+			}
+		}
+	} else {
+		// A provider which cannot be planned may always issue a new secret.
+		pr.Due = true
+	}
+	pr.Steps = append(pr.Steps, from)
+
+	if !pr.Due {
+		return pr, nil
+	}
+
+	for _, to := range rn.To {
+		step := &plan.Step{}
+		if p, ok := to.Spec.Operator.(toprovider.Planner); ok {
+			step, err = p.Plan(ctx)
+			if err != nil {
+				return nil, err
+			}
+			step.Planned = true
+		}
+		step.Provider = fmt.Sprintf("To/%s", to.Provider)
+		pr.Steps = append(pr.Steps, step)
+	}
+	return pr, nil
+}
+
+// planRetirement returns the changes retire would make to the secrets being
+// retired, and tells the from provider which secrets are still being retired
+// as retire does.
+func (r *Runner) planRetirement(rn *schema.Rotation) ([]*plan.Change, error) {
+	if rn.GracePeriod == "" {
+		return nil, nil
+	}
+	stager, isStager := rn.From.Spec.Operator.(fromprovider.Stager)
+	if r.state == nil {
+		return nil, errors.New("staged rotations require a state file")
+	}
+	if !isStager {
+		return nil, fmt.Errorf("%s provider doesn't support staged rotations", rn.From.Provider)
+	}
+
+	gracePeriod, err := str2duration.ParseDuration(rn.GracePeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := r.state.Load(rn.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		changes  []*plan.Change
+		retiring []string
+	)
+	for _, secret := range rs.RetiringSecrets {
+		action := retiringAction(secret, gracePeriod)
+		if action != "" {
+			changes = append(changes, &plan.Change{Action: action, Resource: secret.ID})
+		}
+		if action != plan.Delete {
+			retiring = append(retiring, secret.ID)
+		}
+	}
+	stager.SetRetiring(retiring)
+	return changes, nil
+}
@@ -0,0 +1,153 @@
+// Package plan describes the changes a run of rotations would make, so that
+// they can be reviewed before being applied.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the version of the plan file format.
+const Version = 1
+
+// Actions of a change.
+const (
+	Create     = "create"
+	Update     = "update"
+	Delete     = "delete"
+	Deactivate = "deactivate"
+	Replace    = "replace"
+	Write      = "write"
+)
+
+// Plan is the changes a run of rotations would make.
+type Plan struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Config is the path of the configuration the plan was made from.
+	Config string `json:"config"`
+	// ConfigDigest is the SHA-256 digest of the configuration.
+	ConfigDigest string      `json:"configDigest"`
+	Rotations    []*Rotation `json:"rotations"`
+}
+
+// Rotation is the changes a rotation would make.
+type Rotation struct {
+	Name string `json:"name"`
+	// Due is true if the rotation would issue a new secret. The destinations
+	// are planned only if the rotation is due.
+	Due   bool    `json:"due"`
+	Steps []*Step `json:"steps"`
+}
+
+// Step is the changes a provider would make.
+type Step struct {
+	Provider string `json:"provider"`
+	// Planned is false if the provider cannot tell its changes in advance.
+	Planned bool      `json:"planned"`
+	Changes []*Change `json:"changes,omitempty"`
+	// Observed is the live state the changes are based on, like the access
+	// keys a user has. It must not contain any secret.
+	Observed map[string]string `json:"observed,omitempty"`
+}
+
+// Change is a change to a resource.
+type Change struct {
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+	// Value is the new value of the resource, which is usually a secret. It
+	// is never saved to a plan file and must be masked when shown.
+	Value string `json:"-"`
+}
+
+func (c *Change) String() string {
+	return fmt.Sprintf("%s %s", c.Action, c.Resource)
+}
+
+func (s *Step) String() string {
+	if !s.Planned {
+		return "unknown"
+	}
+	var parts []string
+	for _, c := range s.Changes {
+		parts = append(parts, c.String())
+	}
+	keys := make([]string, 0, len(s.Observed))
+	for k := range s.Observed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, s.Observed[k]))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Save writes the plan to w in JSON so that it can be reviewed as it is.
+func Save(w io.Writer, p *Plan) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(p)
+}
+
+// Load reads a plan written by Save.
+func Load(r io.Reader) (*Plan, error) {
+	var p Plan
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to decode the plan: %w", err)
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("unsupported plan version: %d", p.Version)
+	}
+	return &p, nil
+}
+
+// Diff returns the differences between the rotations of the planned and the
+// live plan, if any.
+func Diff(planned, live *Plan) []string {
+	var diffs []string
+	liveRotations := make(map[string]*Rotation)
+	for _, rn := range live.Rotations {
+		liveRotations[rn.Name] = rn
+	}
+
+	for _, prn := range planned.Rotations {
+		lrn, ok := liveRotations[prn.Name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: no longer configured", prn.Name))
+			continue
+		}
+		delete(liveRotations, prn.Name)
+
+		if prn.Due != lrn.Due {
+			diffs = append(diffs, fmt.Sprintf("%s: due was %t, now %t", prn.Name, prn.Due, lrn.Due))
+			continue
+		}
+		if len(prn.Steps) != len(lrn.Steps) {
+			diffs = append(diffs, fmt.Sprintf("%s: %d steps were planned, now %d", prn.Name, len(prn.Steps), len(lrn.Steps)))
+			continue
+		}
+		for i, ps := range prn.Steps {
+			ls := lrn.Steps[i]
+			// Compared in their string forms not to differ in nil and empty
+			// values after a round trip through JSON.
+			if ps.Provider != ls.Provider || ps.String() != ls.String() {
+				diffs = append(diffs, fmt.Sprintf("%s: %s was planned as %q, now %s %q", prn.Name, ps.Provider, ps, ls.Provider, ls))
+			}
+		}
+	}
+
+	for _, rn := range live.Rotations {
+		if _, ok := liveRotations[rn.Name]; ok {
+			diffs = append(diffs, fmt.Sprintf("%s: not in the plan", rn.Name))
+		}
+	}
+	return diffs
+}
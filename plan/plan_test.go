@@ -0,0 +1,131 @@
+package plan
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	p := &Plan{
+		Version:      Version,
+		CreatedAt:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Config:       "rotations.yaml",
+		ConfigDigest: "digest",
+		Rotations: []*Rotation{
+			{
+				Name: "rotation1",
+				Due:  true,
+				Steps: []*Step{
+					{
+						Provider: "From/AWSIAMUser",
+						Planned:  true,
+						Changes:  []*Change{{Action: Create, Resource: "access key of user1"}},
+						Observed: map[string]string{"accessKeys": "AAA (Active)"},
+					},
+					{
+						Provider: "To/Stdout",
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Save(&buf, p); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	got, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("Load() = %v, want %v", got, p)
+	}
+
+	if _, err := Load(strings.NewReader(`{"version": 0}`)); err == nil {
+		t.Errorf("Load() error = nil, want error for an unsupported version")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	planned := func() *Plan {
+		return &Plan{
+			Rotations: []*Rotation{
+				{
+					Name: "rotation1",
+					Due:  true,
+					Steps: []*Step{
+						{
+							Provider: "From/AWSIAMUser",
+							Planned:  true,
+							Changes:  []*Change{{Action: Create, Resource: "access key of user1"}},
+							Observed: map[string]string{"accessKeys": "AAA (Active)"},
+						},
+						{
+							Provider: "To/Tfe",
+							Planned:  true,
+							Changes:  []*Change{{Action: Update, Resource: "env variable KEY in org/ws"}},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		drift  func(p *Plan)
+		wantOK bool
+	}{
+		{
+			name:   "No drift",
+			drift:  func(p *Plan) {},
+			wantOK: true,
+		},
+		{
+			name: "Nil and empty values are not a drift",
+			drift: func(p *Plan) {
+				p.Rotations[0].Steps[1].Observed = map[string]string{}
+			},
+			wantOK: true,
+		},
+		{
+			name: "The access keys have changed",
+			drift: func(p *Plan) {
+				p.Rotations[0].Steps[0].Observed["accessKeys"] = "AAA (Active), BBB (Active)"
+			},
+		},
+		{
+			name: "A variable has been created in the meantime",
+			drift: func(p *Plan) {
+				p.Rotations[0].Steps[1].Changes[0].Action = Create
+			},
+		},
+		{
+			name: "The rotation is no longer due",
+			drift: func(p *Plan) {
+				p.Rotations[0].Due = false
+				p.Rotations[0].Steps = p.Rotations[0].Steps[:1]
+			},
+		},
+		{
+			name: "A rotation has been added",
+			drift: func(p *Plan) {
+				p.Rotations = append(p.Rotations, &Rotation{Name: "rotation2"})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := planned()
+			tt.drift(live)
+			diffs := Diff(planned(), live)
+			if (len(diffs) == 0) != tt.wantOK {
+				t.Errorf("Diff() = %v, wantOK %v", diffs, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package revolver
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/grezar/revolver/plan"
+	mockedfp "github.com/grezar/revolver/provider/from/mocks"
+	mockedtp "github.com/grezar/revolver/provider/to/mocks"
+	"github.com/grezar/revolver/schema"
+)
+
+type mockedPlannableFromOperator struct {
+	*mockedfp.MockOperator
+	*mockedfp.MockPlanner
+}
+
+type mockedPlannableToOperator struct {
+	*mockedtp.MockOperator
+	*mockedtp.MockPlanner
+}
+
+func TestRunner_Plan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+
+	mockedFromOperator := mockedPlannableFromOperator{
+		MockOperator: mockedfp.NewMockOperator(ctrl),
+		MockPlanner:  mockedfp.NewMockPlanner(ctrl),
+	}
+	mockedToOperator1 := mockedPlannableToOperator{
+		MockOperator: mockedtp.NewMockOperator(ctrl),
+		MockPlanner:  mockedtp.NewMockPlanner(ctrl),
+	}
+	mockedToOperator2 := mockedtp.NewMockOperator(ctrl)
+
+	fromStep := func(observed string) *plan.Step {
+		return &plan.Step{
+			Changes: []*plan.Change{
+				{Action: plan.Create, Resource: "key"},
+				{Action: plan.Delete, Resource: "key1"},
+			},
+			Observed: map[string]string{"keys": observed},
+		}
+	}
+	toStep := func() *plan.Step {
+		return &plan.Step{
+			Changes: []*plan.Change{{Action: plan.Update, Resource: "variable"}},
+		}
+	}
+	gomock.InOrder(
+		mockedFromOperator.MockPlanner.EXPECT().Plan(ctx).Return(fromStep("key1"), nil),
+		mockedToOperator1.MockPlanner.EXPECT().Plan(ctx).Return(toStep(), nil),
+		// The live state has drifted when the plan is checked.
+		mockedFromOperator.MockPlanner.EXPECT().Plan(ctx).Return(fromStep("key1, key2"), nil),
+		mockedToOperator1.MockPlanner.EXPECT().Plan(ctx).Return(toStep(), nil),
+	)
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Provider: "Mock",
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Provider: "Mock1",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator1,
+						},
+					},
+					{
+						Provider: "Mock2",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator2,
+						},
+					},
+				},
+			},
+		},
+		config:       "rotations.yaml",
+		configDigest: "digest",
+	}
+
+	p, err := r.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Runner.Plan() error = %v", err)
+	}
+	want := []*plan.Rotation{
+		{
+			Name: "Mocked Rotation",
+			Due:  true,
+			Steps: []*plan.Step{
+				{
+					Provider: "From/Mock",
+					Planned:  true,
+					Changes:  fromStep("key1").Changes,
+					Observed: fromStep("key1").Observed,
+				},
+				{
+					Provider: "To/Mock1",
+					Planned:  true,
+					Changes:  toStep().Changes,
+				},
+				{
+					Provider: "To/Mock2",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(p.Rotations, want) {
+		t.Errorf("Runner.Plan() = %v, want %v", p.Rotations, want)
+	}
+	if p.Config != "rotations.yaml" || p.ConfigDigest != "digest" {
+		t.Errorf("Runner.Plan() config = %s (%s), want rotations.yaml (digest)", p.Config, p.ConfigDigest)
+	}
+
+	if err := r.CheckPlan(ctx, p); err == nil {
+		t.Errorf("Runner.CheckPlan() error = nil, want an error for the drift")
+	}
+
+	p.ConfigDigest = "changed"
+	if err := r.CheckPlan(ctx, p); err == nil {
+		t.Errorf("Runner.CheckPlan() error = nil, want an error for the changed configuration")
+	}
+}
@@ -3,7 +3,11 @@ package awsiamuser
 import (
 	"context"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/grezar/revolver/retry"
 )
 
 type IAMAccessKeyAPI interface {
@@ -16,16 +20,52 @@ type IAMAccessKeyAPI interface {
 	DeleteAccessKey(ctx context.Context,
 		params *iam.DeleteAccessKeyInput,
 		optFns ...func(*iam.Options)) (*iam.DeleteAccessKeyOutput, error)
+	UpdateAccessKey(ctx context.Context,
+		params *iam.UpdateAccessKeyInput,
+		optFns ...func(*iam.Options)) (*iam.UpdateAccessKeyOutput, error)
 }
 
 func ListAccessKeys(c context.Context, api IAMAccessKeyAPI, input *iam.ListAccessKeysInput) (*iam.ListAccessKeysOutput, error) {
-	return api.ListAccessKeys(c, input)
+	output, err := api.ListAccessKeys(c, input)
+	return output, transient(err)
 }
 
+// CreateAccessKey creates an access key. Unlike the other requests, it is
+// retried only if it was throttled, since a key might have been created
+// otherwise.
 func CreateAccessKey(ctx context.Context, api IAMAccessKeyAPI, input *iam.CreateAccessKeyInput) (*iam.CreateAccessKeyOutput, error) {
-	return api.CreateAccessKey(ctx, input)
+	output, err := api.CreateAccessKey(ctx, input)
+	if err != nil && awsretry.IsErrorThrottles(awsretry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		return output, retry.Transient(err)
+	}
+	return output, err
 }
 
 func DeleteAccessKey(ctx context.Context, api IAMAccessKeyAPI, input *iam.DeleteAccessKeyInput) (*iam.DeleteAccessKeyOutput, error) {
-	return api.DeleteAccessKey(ctx, input)
+	output, err := api.DeleteAccessKey(ctx, input)
+	return output, transient(err)
+}
+
+func UpdateAccessKey(ctx context.Context, api IAMAccessKeyAPI, input *iam.UpdateAccessKeyInput) (*iam.UpdateAccessKeyOutput, error) {
+	output, err := api.UpdateAccessKey(ctx, input)
+	return output, transient(err)
+}
+
+type STSGetCallerIdentityAPI interface {
+	GetCallerIdentity(ctx context.Context,
+		params *sts.GetCallerIdentityInput,
+		optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+func GetCallerIdentity(ctx context.Context, api STSGetCallerIdentityAPI, input *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return api.GetCallerIdentity(ctx, input)
+}
+
+// transient marks the errors the AWS SDK considers worth retrying, like
+// throttling, so that the request is retried.
+func transient(err error) error {
+	if err != nil && awsretry.IsErrorRetryables(awsretry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary {
+		return retry.Transient(err)
+	}
+	return err
 }
@@ -10,6 +10,7 @@ type MockIAMAccessKeyParams struct {
 	ListAccessKeysOutput  *iam.ListAccessKeysOutput
 	CreateAccessKeyOutput *iam.CreateAccessKeyOutput
 	DeleteAccessKeyOutput *iam.DeleteAccessKeyOutput
+	UpdateAccessKeyOutput *iam.UpdateAccessKeyOutput
 }
 
 // MockACMAPI is a struct that represents an ACM client.
@@ -17,6 +18,7 @@ type MockIAMAccessKeyAPI struct {
 	ListAccessKeysAPI  MockListAccessKeys
 	CreateAccessKeyAPI MockCreateAccessKey
 	DeleteAccessKeyAPI MockDeleteAccessKey
+	UpdateAccessKeyAPI MockUpdateAccessKey
 }
 
 // MockListAccessKeys is a type that represents a function that mock IAM's ListAccessKeys.
@@ -28,6 +30,9 @@ type MockCreateAccessKey func(ctx context.Context, params *iam.CreateAccessKeyIn
 // MockDeleteAccessKey is a type that represents a function that mock IAM's ListAccessKeys.
 type MockDeleteAccessKey func(ctx context.Context, params *iam.DeleteAccessKeyInput, optFns ...func(*iam.Options)) (*iam.DeleteAccessKeyOutput, error)
 
+// MockUpdateAccessKey is a type that represents a function that mock IAM's UpdateAccessKey.
+type MockUpdateAccessKey func(ctx context.Context, params *iam.UpdateAccessKeyInput, optFns ...func(*iam.Options)) (*iam.UpdateAccessKeyOutput, error)
+
 // ListAccessKeys returns a function that mock original of IAM ListAccessKeys.
 func (m MockIAMAccessKeyAPI) ListAccessKeys(ctx context.Context, params *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error) {
 	return m.ListAccessKeysAPI(ctx, params, optFns...)
@@ -42,3 +47,8 @@ func (m MockIAMAccessKeyAPI) CreateAccessKey(ctx context.Context, params *iam.Cr
 func (m MockIAMAccessKeyAPI) DeleteAccessKey(ctx context.Context, params *iam.DeleteAccessKeyInput, optFns ...func(*iam.Options)) (*iam.DeleteAccessKeyOutput, error) {
 	return m.DeleteAccessKeyAPI(ctx, params, optFns...)
 }
+
+// UpdateAccessKey returns a function that mock original of IAM UpdateAccessKey.
+func (m MockIAMAccessKeyAPI) UpdateAccessKey(ctx context.Context, params *iam.UpdateAccessKeyInput, optFns ...func(*iam.Options)) (*iam.UpdateAccessKeyOutput, error) {
+	return m.UpdateAccessKeyAPI(ctx, params, optFns...)
+}
@@ -14,6 +14,7 @@ func NewMockIAMAccessKeyAPI(mockParams MockIAMAccessKeyParams) MockIAMAccessKeyA
 		ListAccessKeysAPI:  NewMockListAccessKeysAPI(),
 		CreateAccessKeyAPI: NewMockCreateAccessKeyAPI(),
 		DeleteAccessKeyAPI: NewMockDeleteAccessKeyAPI(),
+		UpdateAccessKeyAPI: NewMockUpdateAccessKeyAPI(),
 	}
 }
 
@@ -47,3 +48,9 @@ func NewMockDeleteAccessKeyAPI() MockDeleteAccessKey {
 		return &iam.DeleteAccessKeyOutput{}, nil
 	})
 }
+
+func NewMockUpdateAccessKeyAPI() MockUpdateAccessKey {
+	return MockUpdateAccessKey(func(ctx context.Context, params *iam.UpdateAccessKeyInput, optFns ...func(*iam.Options)) (*iam.UpdateAccessKeyOutput, error) {
+		return &iam.UpdateAccessKeyOutput{}, nil
+	})
+}
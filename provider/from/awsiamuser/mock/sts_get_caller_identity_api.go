@@ -0,0 +1,20 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// MockSTSGetCallerIdentityAPI is a struct that represents a STS client.
+type MockSTSGetCallerIdentityAPI struct {
+	GetCallerIdentityAPI MockGetCallerIdentity
+}
+
+// MockGetCallerIdentity is a type that represents a function that mock STS's GetCallerIdentity.
+type MockGetCallerIdentity func(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+
+// GetCallerIdentity returns a function that mock original of STS GetCallerIdentity.
+func (m MockSTSGetCallerIdentityAPI) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return m.GetCallerIdentityAPI(ctx, params, optFns...)
+}
@@ -2,16 +2,25 @@ package awsiamuser
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/goccy/go-yaml"
+	"github.com/grezar/revolver/plan"
 	fromprovider "github.com/grezar/revolver/provider/from"
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/retry"
 	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/validation"
 	str2duration "github.com/xhit/go-str2duration/v2"
 	"go.uber.org/ratelimit"
 )
@@ -21,11 +30,16 @@ const (
 	keyAWSAccessKeyID     = "AWSAccessKeyID"
 	keyAWSSecretAccessKey = "AWSSecretAccessKey"
 	awsDefaultRegion      = "us-east-1"
+	// A new key may take a while to be usable due to the eventual consistency
+	// of IAM.
+	verifyMaxAttempts = 10
 	// Though the value is not officially documented, enough small to avoid
 	// rate limiting errors.
 	apiRateLimit = 3
 )
 
+var verifyInterval = 3 * time.Second
+
 func init() {
 	fromprovider.Register(&AWSIAMUser{
 		RateLimit: ratelimit.New(apiRateLimit),
@@ -41,6 +55,10 @@ func (u *AWSIAMUser) Name() string {
 	return name
 }
 
+func (u *AWSIAMUser) SecretKeys() []string {
+	return []string{keyAWSAccessKeyID, keyAWSSecretAccessKey}
+}
+
 func (u *AWSIAMUser) UnmarshalSpec(bytes []byte) (fromprovider.Operator, error) {
 	var s Spec
 	if err := yaml.Unmarshal(bytes, &s); err != nil {
@@ -61,7 +79,51 @@ type Spec struct {
 	Expiration                string `yaml:"expiration"`
 	ForceDeleteAllExpiredKeys bool   `yaml:"forceDeleteAllExpiredKeys"`
 	Client                    IAMAccessKeyAPI
+	STSClient                 STSGetCallerIdentityAPI
 	RateLimit                 ratelimit.Limiter
+	// deletableKeys holds the expired keys found in Do which are deleted
+	// in Cleanup.
+	deletableKeys []types.AccessKey
+	// retiring holds the IDs of the keys being retired in a staged rotation.
+	retiring map[string]bool
+	// steps holds the calls to the API made by the last Do or Cleanup.
+	steps []*reporting.Step
+	// changes holds the changes made by the last Do and Cleanup.
+	changes []*plan.Change
+	retrier retry.Retrier
+}
+
+// Changes implements fromprovider.Differ interface
+func (s *Spec) Changes() []*plan.Change {
+	return s.changes
+}
+
+// Steps implements fromprovider.Stepper interface
+func (s *Spec) Steps() []*reporting.Step {
+	return s.steps
+}
+
+// SetRateLimiter implements fromprovider.RateLimited interface
+func (s *Spec) SetRateLimiter(l ratelimit.Limiter) {
+	s.RateLimit = l
+}
+
+// SetRetryPolicy implements fromprovider.Retrier interface
+func (s *Spec) SetRetryPolicy(p retry.Policy) {
+	s.retrier.SetRetryPolicy(p)
+}
+
+// Retries implements fromprovider.Retrier interface
+func (s *Spec) Retries() int {
+	return s.retrier.Retries()
+}
+
+// Validate implements fromprovider.Validator interface
+func (s *Spec) Validate() []*validation.FieldError {
+	if _, err := str2duration.ParseDuration(s.Expiration); err != nil {
+		return []*validation.FieldError{{Field: "expiration", Message: err.Error()}}
+	}
+	return nil
 }
 
 func (s *Spec) Summary() string {
@@ -72,65 +134,88 @@ func (s *Spec) buildClient(ctx context.Context) (IAMAccessKeyAPI, error) {
 	if s.Client != nil {
 		return s.Client, nil
 	}
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(awsDefaultRegion))
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(awsDefaultRegion),
+		// Throttling and server errors are retried with the retry policy of
+		// the provider.
+		config.WithRetryer(func() aws.Retryer {
+			return aws.NopRetryer{}
+		}),
+	)
 	if err != nil {
 		return nil, err
 	}
 	return iam.NewFromConfig(cfg), nil
 }
 
-func (s *Spec) Do(ctx context.Context, dryRun bool) (_ secrets.Secrets, doErr error) {
-	client, doErr := s.buildClient(ctx)
-	if doErr != nil {
-		return nil, doErr
+func (s *Spec) buildSTSClient(ctx context.Context, ss secrets.Secrets) (STSGetCallerIdentityAPI, error) {
+	if s.STSClient != nil {
+		return s.STSClient, nil
 	}
-
-	inpt := &iam.ListAccessKeysInput{
-		UserName: aws.String(s.Username),
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(awsDefaultRegion),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(ss[keyAWSAccessKeyID], ss[keyAWSSecretAccessKey], "")),
+	)
+	if err != nil {
+		return nil, err
 	}
-	s.RateLimit.Take()
-	keys, doErr := ListAccessKeys(ctx, client, inpt)
-	if doErr != nil {
-		return nil, doErr
+	return sts.NewFromConfig(cfg), nil
+}
+
+// keyPlan is what a run does with the access keys of the user.
+type keyPlan struct {
+	// forceDeleted is the expired keys deleted before a new key is created.
+	forceDeleted []types.AccessKey
+	// deletable is the expired key deleted by Cleanup.
+	deletable []types.AccessKey
+	create    bool
+}
+
+func (s *Spec) planKeys(keys []types.AccessKeyMetadata) (*keyPlan, error) {
+	expiration, err := s.ExpiresAfter()
+	if err != nil {
+		return nil, err
 	}
 
-	expiration, doErr := str2duration.ParseDuration(s.Expiration)
-	if doErr != nil {
-		return nil, doErr
+	// The keys being retired are no longer current keys but still occupy the
+	// slots of the user.
+	var currentKeys []types.AccessKeyMetadata
+	for _, key := range keys {
+		if !s.retiring[aws.ToString(key.AccessKeyId)] {
+			currentKeys = append(currentKeys, key)
+		}
 	}
+	remainingKeys := len(keys)
 
-	switch len(keys.AccessKeyMetadata) {
+	kp := &keyPlan{}
+	switch len(currentKeys) {
 	case 0:
 		// Only to proceed to the next step.
 	case 1:
-		if expiration <= time.Since(aws.ToTime(keys.AccessKeyMetadata[0].CreateDate)) {
-			defer func() {
-				doErr = s.cleanup(ctx, dryRun, types.AccessKey{
-					AccessKeyId: keys.AccessKeyMetadata[0].AccessKeyId,
-					UserName:    keys.AccessKeyMetadata[0].UserName,
-				})
-			}()
+		if expiration <= time.Since(aws.ToTime(currentKeys[0].CreateDate)) {
+			kp.deletable = append(kp.deletable, types.AccessKey{
+				AccessKeyId: currentKeys[0].AccessKeyId,
+				UserName:    currentKeys[0].UserName,
+			})
 		} else {
-			return nil, nil
+			return kp, nil
 		}
 	case 2:
-		var deletedAtLeastOne bool
 		if s.ForceDeleteAllExpiredKeys {
-			for _, key := range keys.AccessKeyMetadata {
+			// A user can only have two keys, so the expired keys must be
+			// deleted before a new one can be created.
+			for _, key := range currentKeys {
 				if expiration <= time.Since(aws.ToTime(key.CreateDate)) {
-					doErr = s.cleanup(ctx, dryRun, types.AccessKey{
+					kp.forceDeleted = append(kp.forceDeleted, types.AccessKey{
 						AccessKeyId: key.AccessKeyId,
 						UserName:    key.UserName,
 					})
-					if doErr != nil {
-						return nil, doErr
-					}
-					deletedAtLeastOne = true
+					remainingKeys--
 				}
 			}
 			// Skip following steps if not delete any of the keys.
-			if !deletedAtLeastOne {
-				return nil, nil
+			if len(kp.forceDeleted) == 0 {
+				return kp, nil
 			}
 		} else {
 			return nil, fmt.Errorf(`The user "%s" already has two access keys. Revolver cannot create a new key and cannot continue with the key rotation process. Please delete at least one of the existing keys and try again or you can delete all of expired keys with "forceDeleteAllExpiredKeys" option enabled.`, s.Username)
@@ -139,15 +224,79 @@ func (s *Spec) Do(ctx context.Context, dryRun bool) (_ secrets.Secrets, doErr er
 		panic("never reach here")
 	}
 
+	if remainingKeys >= 2 {
+		return nil, fmt.Errorf(`The user "%s" already has two access keys and one of them is still being retired. Revolver cannot create a new key until the grace period of the retiring key has passed.`, s.Username)
+	}
+	kp.create = true
+	return kp, nil
+}
+
+func (s *Spec) listKeys(ctx context.Context, client IAMAccessKeyAPI) ([]types.AccessKeyMetadata, error) {
+	inpt := &iam.ListAccessKeysInput{
+		UserName: aws.String(s.Username),
+	}
+	var keys *iam.ListAccessKeysOutput
+	err := s.retrier.Do(ctx, func() error {
+		s.RateLimit.Take()
+		var err error
+		keys, err = ListAccessKeys(ctx, client, inpt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys.AccessKeyMetadata, nil
+}
+
+// Do implements fromprovider.Operator interface. The expired key is left in
+// place and deleted by Cleanup.
+func (s *Spec) Do(ctx context.Context, dryRun bool) (secrets.Secrets, error) {
+	s.deletableKeys = nil
+	s.steps = nil
+	s.changes = nil
+
+	client, err := s.buildClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.listKeys(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, err := s.planKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range kp.forceDeleted {
+		if err := s.deleteKey(ctx, dryRun, key); err != nil {
+			return nil, err
+		}
+	}
+	if !kp.create {
+		return nil, nil
+	}
+	s.deletableKeys = kp.deletable
+	s.changes = append(s.changes, s.createKeyChange())
+
 	input := &iam.CreateAccessKeyInput{
 		UserName: aws.String(s.Username),
 	}
 
 	if !dryRun {
-		s.RateLimit.Take()
-		output, doErr := CreateAccessKey(ctx, client, input)
-		if doErr != nil {
-			return nil, doErr
+		var output *iam.CreateAccessKeyOutput
+		step, err := reporting.TimeStep(s.createKeyChange().String(), func() error {
+			return s.retrier.Do(ctx, func() error {
+				s.RateLimit.Take()
+				var err error
+				output, err = CreateAccessKey(ctx, client, input)
+				return err
+			})
+		})
+		s.steps = append(s.steps, step)
+		if err != nil {
+			return nil, err
 		}
 
 		return secrets.Secrets{
@@ -159,7 +308,175 @@ func (s *Spec) Do(ctx context.Context, dryRun bool) (_ secrets.Secrets, doErr er
 	return nil, nil
 }
 
-func (s *Spec) cleanup(ctx context.Context, dryRun bool, deletableKey types.AccessKey) error {
+// Plan implements fromprovider.Planner interface. The access keys of the user
+// are observed so that a change in them is detected as a drift.
+func (s *Spec) Plan(ctx context.Context) (*plan.Step, error) {
+	client, err := s.buildClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.listKeys(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	kp, err := s.planKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var observed []string
+	for _, key := range keys {
+		observed = append(observed, fmt.Sprintf("%s (%s)", aws.ToString(key.AccessKeyId), key.Status))
+	}
+	sort.Strings(observed)
+
+	step := &plan.Step{
+		Observed: map[string]string{
+			"accessKeys": strings.Join(observed, ", "),
+		},
+	}
+	for _, key := range kp.forceDeleted {
+		step.Changes = append(step.Changes, deleteKeyChange(key.AccessKeyId))
+	}
+	if kp.create {
+		step.Changes = append(step.Changes, s.createKeyChange())
+	}
+	for _, key := range kp.deletable {
+		step.Changes = append(step.Changes, deleteKeyChange(key.AccessKeyId))
+	}
+	return step, nil
+}
+
+func (s *Spec) createKeyChange() *plan.Change {
+	return &plan.Change{Action: plan.Create, Resource: fmt.Sprintf("access key of %s", s.Username)}
+}
+
+func deleteKeyChange(id *string) *plan.Change {
+	return &plan.Change{Action: plan.Delete, Resource: fmt.Sprintf("access key %s", aws.ToString(id))}
+}
+
+// Cleanup implements fromprovider.Cleaner interface. It deletes the expired
+// key found in the last Do.
+func (s *Spec) Cleanup(ctx context.Context, dryRun bool) error {
+	s.steps = nil
+	for _, key := range s.deletableKeys {
+		if err := s.deleteKey(ctx, dryRun, key); err != nil {
+			return err
+		}
+	}
+	s.deletableKeys = nil
+	return nil
+}
+
+// Revoke implements fromprovider.Revoker interface. It deletes the key issued
+// by Do.
+func (s *Spec) Revoke(ctx context.Context, ss secrets.Secrets) error {
+	accessKeyID, ok := ss[keyAWSAccessKeyID]
+	if !ok {
+		return fmt.Errorf("%s is not found in the secrets to revoke", keyAWSAccessKeyID)
+	}
+	return s.deleteKey(ctx, false, types.AccessKey{
+		AccessKeyId: aws.String(accessKeyID),
+		UserName:    aws.String(s.Username),
+	})
+}
+
+// SecretID implements fromprovider.Identifier interface
+func (s *Spec) SecretID(ss secrets.Secrets) string {
+	return ss[keyAWSAccessKeyID]
+}
+
+// ExpiresAfter implements fromprovider.Expirer interface
+func (s *Spec) ExpiresAfter() (time.Duration, error) {
+	return str2duration.ParseDuration(s.Expiration)
+}
+
+// Superseded implements fromprovider.Stager interface
+func (s *Spec) Superseded() []string {
+	var ids []string
+	for _, key := range s.deletableKeys {
+		ids = append(ids, aws.ToString(key.AccessKeyId))
+	}
+	return ids
+}
+
+// SetRetiring implements fromprovider.Stager interface
+func (s *Spec) SetRetiring(ids []string) {
+	s.retiring = make(map[string]bool)
+	for _, id := range ids {
+		s.retiring[id] = true
+	}
+}
+
+// Deactivate implements fromprovider.Stager interface
+func (s *Spec) Deactivate(ctx context.Context, dryRun bool, id string) error {
+	client, err := s.buildClient(ctx)
+	if err != nil {
+		return err
+	}
+	input := &iam.UpdateAccessKeyInput{
+		AccessKeyId: aws.String(id),
+		UserName:    aws.String(s.Username),
+		Status:      types.StatusTypeInactive,
+	}
+	if !dryRun {
+		err := s.retrier.Do(ctx, func() error {
+			s.RateLimit.Take()
+			_, err := UpdateAccessKey(ctx, client, input)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete implements fromprovider.Stager interface
+func (s *Spec) Delete(ctx context.Context, dryRun bool, id string) error {
+	return s.deleteKey(ctx, dryRun, types.AccessKey{
+		AccessKeyId: aws.String(id),
+		UserName:    aws.String(s.Username),
+	})
+}
+
+// Verify implements fromprovider.Verifier interface. It calls
+// GetCallerIdentity with the new key until it succeeds, and checks the key
+// belongs to the user.
+func (s *Spec) Verify(ctx context.Context, ss secrets.Secrets) error {
+	client, err := s.buildSTSClient(ctx, ss)
+	if err != nil {
+		return err
+	}
+
+	var output *sts.GetCallerIdentityOutput
+	for attempt := 1; ; attempt++ {
+		output, err = GetCallerIdentity(ctx, client, &sts.GetCallerIdentityInput{})
+		if err == nil {
+			break
+		}
+		if attempt >= verifyMaxAttempts {
+			return fmt.Errorf("the new key could not be verified after %d attempts: %w", attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(verifyInterval):
+		}
+	}
+
+	if aws.ToString(output.Account) != s.AccountID {
+		return fmt.Errorf("the new key belongs to the account %s, not %s", aws.ToString(output.Account), s.AccountID)
+	}
+	if !strings.HasSuffix(aws.ToString(output.Arn), fmt.Sprintf(":user/%s", s.Username)) {
+		return fmt.Errorf("the new key belongs to %s, not the user %s", aws.ToString(output.Arn), s.Username)
+	}
+	return nil
+}
+
+func (s *Spec) deleteKey(ctx context.Context, dryRun bool, deletableKey types.AccessKey) error {
 	client, err := s.buildClient(ctx)
 	if err != nil {
 		return err
@@ -168,9 +485,24 @@ func (s *Spec) cleanup(ctx context.Context, dryRun bool, deletableKey types.Acce
 		AccessKeyId: deletableKey.AccessKeyId,
 		UserName:    deletableKey.UserName,
 	}
+	change := deleteKeyChange(deletableKey.AccessKeyId)
+	s.changes = append(s.changes, change)
 	if !dryRun {
-		s.RateLimit.Take()
-		_, err := DeleteAccessKey(ctx, client, input)
+		step, err := reporting.TimeStep(change.String(), func() error {
+			retried := false
+			return s.retrier.Do(ctx, func() error {
+				s.RateLimit.Take()
+				_, err := DeleteAccessKey(ctx, client, input)
+				// The key was deleted by the request which was retried.
+				var notFound *types.NoSuchEntityException
+				if retried && errors.As(err, &notFound) {
+					return nil
+				}
+				retried = true
+				return err
+			})
+		})
+		s.steps = append(s.steps, step)
 		if err != nil {
 			return err
 		}
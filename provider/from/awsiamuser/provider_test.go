@@ -2,6 +2,7 @@ package awsiamuser
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -10,7 +11,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/grezar/revolver/plan"
 	"github.com/grezar/revolver/provider/from/awsiamuser/mock"
+	"github.com/grezar/revolver/retry"
 	"github.com/grezar/revolver/secrets"
 	"go.uber.org/ratelimit"
 )
@@ -21,6 +25,7 @@ func TestSpec_Do(t *testing.T) {
 		Username                  string
 		Expiration                string
 		ForceDeleteAllExpiredKeys bool
+		Retiring                  []string
 		MockIAMAccessKeyAPI       mock.MockIAMAccessKeyAPI
 		dryRun                    bool
 	}
@@ -190,6 +195,66 @@ func TestSpec_Do(t *testing.T) {
 			},
 			want: nil,
 		},
+		{
+			name: "Do nothing, the current key isn't expired and the other one is being retired",
+			fields: fields{
+				AccountID:  "0123456789",
+				Username:   "test-iam-user",
+				Expiration: "90d",
+				Retiring:   []string{"RETIRING1"},
+				MockIAMAccessKeyAPI: mock.MockIAMAccessKeyAPI{
+					ListAccessKeysAPI: mock.MockListAccessKeys(
+						func(ctx context.Context, params *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error) {
+							return &iam.ListAccessKeysOutput{
+								AccessKeyMetadata: []types.AccessKeyMetadata{
+									{
+										AccessKeyId: aws.String("RETIRING1"),
+										CreateDate:  aws.Time(time.Now().Add(-24 * 91 * time.Hour)),
+										UserName:    aws.String("test-iam-user"),
+									},
+									{
+										AccessKeyId: aws.String("NOTEXPIRED1"),
+										CreateDate:  aws.Time(time.Now().Add(-24 * time.Hour)),
+										UserName:    aws.String("test-iam-user"),
+									},
+								},
+							}, nil
+						},
+					),
+				},
+			},
+			want: nil,
+		},
+		{
+			name: "The current key is expired but the other one is still being retired",
+			fields: fields{
+				AccountID:  "0123456789",
+				Username:   "test-iam-user",
+				Expiration: "1d",
+				Retiring:   []string{"RETIRING1"},
+				MockIAMAccessKeyAPI: mock.MockIAMAccessKeyAPI{
+					ListAccessKeysAPI: mock.MockListAccessKeys(
+						func(ctx context.Context, params *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error) {
+							return &iam.ListAccessKeysOutput{
+								AccessKeyMetadata: []types.AccessKeyMetadata{
+									{
+										AccessKeyId: aws.String("RETIRING1"),
+										CreateDate:  aws.Time(time.Now().Add(-24 * 91 * time.Hour)),
+										UserName:    aws.String("test-iam-user"),
+									},
+									{
+										AccessKeyId: aws.String("EXPIRED1"),
+										CreateDate:  aws.Time(time.Now().Add(-24 * 2 * time.Hour)),
+										UserName:    aws.String("test-iam-user"),
+									},
+								},
+							}, nil
+						},
+					),
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -201,6 +266,7 @@ func TestSpec_Do(t *testing.T) {
 				Client:                    tt.fields.MockIAMAccessKeyAPI,
 				RateLimit:                 ratelimit.New(apiRateLimit),
 			}
+			s.SetRetiring(tt.fields.Retiring)
 			ctx := context.Background()
 			got, err := s.Do(ctx, tt.fields.dryRun)
 			if (err != nil) != tt.wantErr {
@@ -213,3 +279,348 @@ func TestSpec_Do(t *testing.T) {
 		})
 	}
 }
+
+func TestSpec_Cleanup(t *testing.T) {
+	tests := []struct {
+		name        string
+		dryRun      bool
+		wantDeleted []string
+	}{
+		{
+			name:        "Delete the expired key found in Do",
+			wantDeleted: []string{"AAAAAAAAAAAA"},
+		},
+		{
+			name:   "It doesn't delete the expired key in dry-run mode",
+			dryRun: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			s := &Spec{
+				AccountID:  "0123456789",
+				Username:   "test-iam-user",
+				Expiration: "15m",
+				Client: mock.MockIAMAccessKeyAPI{
+					ListAccessKeysAPI:  mock.NewMockListAccessKeysAPI(),
+					CreateAccessKeyAPI: mock.NewMockCreateAccessKeyAPI(),
+					DeleteAccessKeyAPI: mock.MockDeleteAccessKey(
+						func(ctx context.Context, params *iam.DeleteAccessKeyInput, optFns ...func(*iam.Options)) (*iam.DeleteAccessKeyOutput, error) {
+							deleted = append(deleted, aws.ToString(params.AccessKeyId))
+							return &iam.DeleteAccessKeyOutput{}, nil
+						},
+					),
+				},
+				RateLimit: ratelimit.New(apiRateLimit),
+			}
+			ctx := context.Background()
+			if _, err := s.Do(ctx, tt.dryRun); err != nil {
+				t.Fatalf("Spec.Do() error = %v", err)
+			}
+			if len(deleted) != 0 {
+				t.Fatalf("Spec.Do() deleted %v before Cleanup", deleted)
+			}
+			if err := s.Cleanup(ctx, tt.dryRun); err != nil {
+				t.Errorf("Spec.Cleanup() error = %v", err)
+			}
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("Spec.Cleanup() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+			wantChanges := []*plan.Change{
+				{Action: plan.Create, Resource: "access key of test-iam-user"},
+				{Action: plan.Delete, Resource: "access key AAAAAAAAAAAA"},
+			}
+			if !reflect.DeepEqual(s.Changes(), wantChanges) {
+				t.Errorf("Spec.Changes() = %v, want %v", s.Changes(), wantChanges)
+			}
+		})
+	}
+}
+
+func TestSpec_Plan(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiration string
+		want       *plan.Step
+	}{
+		{
+			name:       "Plan to replace the expired key",
+			expiration: "15m",
+			want: &plan.Step{
+				Changes: []*plan.Change{
+					{Action: plan.Create, Resource: "access key of test-iam-user"},
+					{Action: plan.Delete, Resource: "access key AAAAAAAAAAAA"},
+				},
+				Observed: map[string]string{"accessKeys": "AAAAAAAAAAAA (Active)"},
+			},
+		},
+		{
+			name:       "Plan no changes if the key has not expired",
+			expiration: "90d",
+			want: &plan.Step{
+				Observed: map[string]string{"accessKeys": "AAAAAAAAAAAA (Active)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Spec{
+				AccountID:  "0123456789",
+				Username:   "test-iam-user",
+				Expiration: tt.expiration,
+				Client: mock.MockIAMAccessKeyAPI{
+					ListAccessKeysAPI: mock.MockListAccessKeys(
+						func(ctx context.Context, params *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error) {
+							return &iam.ListAccessKeysOutput{
+								AccessKeyMetadata: []types.AccessKeyMetadata{
+									{
+										AccessKeyId: aws.String("AAAAAAAAAAAA"),
+										CreateDate:  aws.Time(time.Now().Add(-24 * time.Hour)),
+										Status:      types.StatusTypeActive,
+										UserName:    aws.String("test-iam-user"),
+									},
+								},
+							}, nil
+						},
+					),
+				},
+				RateLimit: ratelimit.New(apiRateLimit),
+			}
+			got, err := s.Plan(context.Background())
+			if err != nil {
+				t.Fatalf("Spec.Plan() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Spec.Plan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpec_Revoke(t *testing.T) {
+	tests := []struct {
+		name        string
+		secrets     secrets.Secrets
+		wantDeleted []string
+		wantErr     bool
+	}{
+		{
+			name: "Delete the key issued by Do",
+			secrets: secrets.Secrets{
+				"AWSAccessKeyID":     "BBBBBBBBBBBB",
+				"AWSSecretAccessKey": "CCCCCCCCCCCC",
+			},
+			wantDeleted: []string{"BBBBBBBBBBBB"},
+		},
+		{
+			name:    "Access key ID is missing",
+			secrets: secrets.Secrets{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			s := &Spec{
+				AccountID: "0123456789",
+				Username:  "test-iam-user",
+				Client: mock.MockIAMAccessKeyAPI{
+					DeleteAccessKeyAPI: mock.MockDeleteAccessKey(
+						func(ctx context.Context, params *iam.DeleteAccessKeyInput, optFns ...func(*iam.Options)) (*iam.DeleteAccessKeyOutput, error) {
+							deleted = append(deleted, aws.ToString(params.AccessKeyId))
+							return &iam.DeleteAccessKeyOutput{}, nil
+						},
+					),
+				},
+				RateLimit: ratelimit.New(apiRateLimit),
+			}
+			err := s.Revoke(context.Background(), tt.secrets)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Spec.Revoke() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("Spec.Revoke() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestSpec_Verify(t *testing.T) {
+	verifyInterval = 0
+
+	type fields struct {
+		AccountID                   string
+		Username                    string
+		MockSTSGetCallerIdentityAPI func(attempts *int) mock.MockSTSGetCallerIdentityAPI
+	}
+	tests := []struct {
+		name         string
+		fields       fields
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name: "The new key works after IAM becomes consistent",
+			fields: fields{
+				AccountID: "0123456789",
+				Username:  "test-iam-user",
+				MockSTSGetCallerIdentityAPI: func(attempts *int) mock.MockSTSGetCallerIdentityAPI {
+					return mock.MockSTSGetCallerIdentityAPI{
+						GetCallerIdentityAPI: func(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+							*attempts++
+							if *attempts < 3 {
+								return nil, errors.New("InvalidClientTokenId")
+							}
+							return &sts.GetCallerIdentityOutput{
+								Account: aws.String("0123456789"),
+								Arn:     aws.String("arn:aws:iam::0123456789:user/test-iam-user"),
+							}, nil
+						},
+					}
+				},
+			},
+			wantAttempts: 3,
+		},
+		{
+			name: "The new key never works",
+			fields: fields{
+				AccountID: "0123456789",
+				Username:  "test-iam-user",
+				MockSTSGetCallerIdentityAPI: func(attempts *int) mock.MockSTSGetCallerIdentityAPI {
+					return mock.MockSTSGetCallerIdentityAPI{
+						GetCallerIdentityAPI: func(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+							*attempts++
+							return nil, errors.New("InvalidClientTokenId")
+						},
+					}
+				},
+			},
+			wantAttempts: verifyMaxAttempts,
+			wantErr:      true,
+		},
+		{
+			name: "The new key belongs to another user",
+			fields: fields{
+				AccountID: "0123456789",
+				Username:  "test-iam-user",
+				MockSTSGetCallerIdentityAPI: func(attempts *int) mock.MockSTSGetCallerIdentityAPI {
+					return mock.MockSTSGetCallerIdentityAPI{
+						GetCallerIdentityAPI: func(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+							*attempts++
+							return &sts.GetCallerIdentityOutput{
+								Account: aws.String("0123456789"),
+								Arn:     aws.String("arn:aws:iam::0123456789:user/another-iam-user"),
+							}, nil
+						},
+					}
+				},
+			},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			s := &Spec{
+				AccountID: tt.fields.AccountID,
+				Username:  tt.fields.Username,
+				STSClient: tt.fields.MockSTSGetCallerIdentityAPI(&attempts),
+				RateLimit: ratelimit.New(apiRateLimit),
+			}
+			err := s.Verify(context.Background(), secrets.Secrets{
+				"AWSAccessKeyID":     "BBBBBBBBBBBB",
+				"AWSSecretAccessKey": "CCCCCCCCCCCC",
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Spec.Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("Spec.Verify() attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+// apiError is an error of the AWS API with the code.
+type apiError string
+
+func (e apiError) Error() string {
+	return string(e)
+}
+
+func (e apiError) ErrorCode() string {
+	return string(e)
+}
+
+func TestSpec_Do_Retry(t *testing.T) {
+	tests := []struct {
+		name        string
+		createErr   error
+		wantErr     bool
+		wantCreates int
+		wantRetries int
+	}{
+		{
+			name:        "A throttled request to create a key is retried",
+			createErr:   apiError("Throttling"),
+			wantCreates: 2,
+			wantRetries: 2,
+		},
+		{
+			name:        "A request to create a key which might have succeeded is not retried",
+			createErr:   apiError("RequestTimeout"),
+			wantErr:     true,
+			wantCreates: 1,
+			wantRetries: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lists, creates int
+			listKeys := mock.NewMockListAccessKeysAPI()
+			createKey := mock.NewMockCreateAccessKeyAPI()
+			s := &Spec{
+				AccountID:  "0123456789",
+				Username:   "test-iam-user",
+				Expiration: "15m",
+				Client: mock.MockIAMAccessKeyAPI{
+					// Listing the keys fails once, which is always retried.
+					ListAccessKeysAPI: mock.MockListAccessKeys(
+						func(ctx context.Context, params *iam.ListAccessKeysInput, optFns ...func(*iam.Options)) (*iam.ListAccessKeysOutput, error) {
+							lists++
+							if lists == 1 {
+								return nil, apiError("RequestTimeout")
+							}
+							return listKeys(ctx, params, optFns...)
+						},
+					),
+					CreateAccessKeyAPI: mock.MockCreateAccessKey(
+						func(ctx context.Context, params *iam.CreateAccessKeyInput, optFns ...func(*iam.Options)) (*iam.CreateAccessKeyOutput, error) {
+							creates++
+							if creates == 1 {
+								return nil, tt.createErr
+							}
+							return createKey(ctx, params, optFns...)
+						},
+					),
+					DeleteAccessKeyAPI: mock.NewMockDeleteAccessKeyAPI(),
+				},
+				RateLimit: ratelimit.New(apiRateLimit),
+			}
+			s.SetRetryPolicy(retry.Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+			_, err := s.Do(context.Background(), false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Spec.Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if creates != tt.wantCreates {
+				t.Errorf("CreateAccessKey was called %d times, want %d", creates, tt.wantCreates)
+			}
+			if got := s.Retries(); got != tt.wantRetries {
+				t.Errorf("Spec.Retries() = %d, want %d", got, tt.wantRetries)
+			}
+		})
+	}
+}
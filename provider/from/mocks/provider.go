@@ -7,10 +7,16 @@ package mock_fromprovider
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	plan "github.com/grezar/revolver/plan"
 	fromprovider "github.com/grezar/revolver/provider/from"
+	reporting "github.com/grezar/revolver/reporting"
+	retry "github.com/grezar/revolver/retry"
 	secrets "github.com/grezar/revolver/secrets"
+	validation "github.com/grezar/revolver/validation"
+	ratelimit "go.uber.org/ratelimit"
 )
 
 // MockProvider is a mock of Provider interface.
@@ -50,6 +56,20 @@ func (mr *MockProviderMockRecorder) Name() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockProvider)(nil).Name))
 }
 
+// SecretKeys mocks base method.
+func (m *MockProvider) SecretKeys() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SecretKeys")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// SecretKeys indicates an expected call of SecretKeys.
+func (mr *MockProviderMockRecorder) SecretKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SecretKeys", reflect.TypeOf((*MockProvider)(nil).SecretKeys))
+}
+
 // UnmarshalSpec mocks base method.
 func (m *MockProvider) UnmarshalSpec(bytes []byte) (fromprovider.Operator, error) {
 	m.ctrl.T.Helper()
@@ -116,3 +136,499 @@ func (mr *MockOperatorMockRecorder) Summary() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Summary", reflect.TypeOf((*MockOperator)(nil).Summary))
 }
+
+// MockCleaner is a mock of Cleaner interface.
+type MockCleaner struct {
+	ctrl     *gomock.Controller
+	recorder *MockCleanerMockRecorder
+}
+
+// MockCleanerMockRecorder is the mock recorder for MockCleaner.
+type MockCleanerMockRecorder struct {
+	mock *MockCleaner
+}
+
+// NewMockCleaner creates a new mock instance.
+func NewMockCleaner(ctrl *gomock.Controller) *MockCleaner {
+	mock := &MockCleaner{ctrl: ctrl}
+	mock.recorder = &MockCleanerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCleaner) EXPECT() *MockCleanerMockRecorder {
+	return m.recorder
+}
+
+// Cleanup mocks base method.
+func (m *MockCleaner) Cleanup(ctx context.Context, dryRun bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cleanup", ctx, dryRun)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Cleanup indicates an expected call of Cleanup.
+func (mr *MockCleanerMockRecorder) Cleanup(ctx, dryRun interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cleanup", reflect.TypeOf((*MockCleaner)(nil).Cleanup), ctx, dryRun)
+}
+
+// MockRevoker is a mock of Revoker interface.
+type MockRevoker struct {
+	ctrl     *gomock.Controller
+	recorder *MockRevokerMockRecorder
+}
+
+// MockRevokerMockRecorder is the mock recorder for MockRevoker.
+type MockRevokerMockRecorder struct {
+	mock *MockRevoker
+}
+
+// NewMockRevoker creates a new mock instance.
+func NewMockRevoker(ctrl *gomock.Controller) *MockRevoker {
+	mock := &MockRevoker{ctrl: ctrl}
+	mock.recorder = &MockRevokerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRevoker) EXPECT() *MockRevokerMockRecorder {
+	return m.recorder
+}
+
+// Revoke mocks base method.
+func (m *MockRevoker) Revoke(ctx context.Context, s secrets.Secrets) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockRevokerMockRecorder) Revoke(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockRevoker)(nil).Revoke), ctx, s)
+}
+
+// MockStager is a mock of Stager interface.
+type MockStager struct {
+	ctrl     *gomock.Controller
+	recorder *MockStagerMockRecorder
+}
+
+// MockStagerMockRecorder is the mock recorder for MockStager.
+type MockStagerMockRecorder struct {
+	mock *MockStager
+}
+
+// NewMockStager creates a new mock instance.
+func NewMockStager(ctrl *gomock.Controller) *MockStager {
+	mock := &MockStager{ctrl: ctrl}
+	mock.recorder = &MockStagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStager) EXPECT() *MockStagerMockRecorder {
+	return m.recorder
+}
+
+// Deactivate mocks base method.
+func (m *MockStager) Deactivate(ctx context.Context, dryRun bool, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deactivate", ctx, dryRun, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Deactivate indicates an expected call of Deactivate.
+func (mr *MockStagerMockRecorder) Deactivate(ctx, dryRun, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deactivate", reflect.TypeOf((*MockStager)(nil).Deactivate), ctx, dryRun, id)
+}
+
+// Delete mocks base method.
+func (m *MockStager) Delete(ctx context.Context, dryRun bool, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, dryRun, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockStagerMockRecorder) Delete(ctx, dryRun, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockStager)(nil).Delete), ctx, dryRun, id)
+}
+
+// SetRetiring mocks base method.
+func (m *MockStager) SetRetiring(ids []string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRetiring", ids)
+}
+
+// SetRetiring indicates an expected call of SetRetiring.
+func (mr *MockStagerMockRecorder) SetRetiring(ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRetiring", reflect.TypeOf((*MockStager)(nil).SetRetiring), ids)
+}
+
+// Superseded mocks base method.
+func (m *MockStager) Superseded() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Superseded")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Superseded indicates an expected call of Superseded.
+func (mr *MockStagerMockRecorder) Superseded() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Superseded", reflect.TypeOf((*MockStager)(nil).Superseded))
+}
+
+// MockExpirer is a mock of Expirer interface.
+type MockExpirer struct {
+	ctrl     *gomock.Controller
+	recorder *MockExpirerMockRecorder
+}
+
+// MockExpirerMockRecorder is the mock recorder for MockExpirer.
+type MockExpirerMockRecorder struct {
+	mock *MockExpirer
+}
+
+// NewMockExpirer creates a new mock instance.
+func NewMockExpirer(ctrl *gomock.Controller) *MockExpirer {
+	mock := &MockExpirer{ctrl: ctrl}
+	mock.recorder = &MockExpirerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockExpirer) EXPECT() *MockExpirerMockRecorder {
+	return m.recorder
+}
+
+// ExpiresAfter mocks base method.
+func (m *MockExpirer) ExpiresAfter() (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpiresAfter")
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpiresAfter indicates an expected call of ExpiresAfter.
+func (mr *MockExpirerMockRecorder) ExpiresAfter() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpiresAfter", reflect.TypeOf((*MockExpirer)(nil).ExpiresAfter))
+}
+
+// MockVerifier is a mock of Verifier interface.
+type MockVerifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockVerifierMockRecorder
+}
+
+// MockVerifierMockRecorder is the mock recorder for MockVerifier.
+type MockVerifierMockRecorder struct {
+	mock *MockVerifier
+}
+
+// NewMockVerifier creates a new mock instance.
+func NewMockVerifier(ctrl *gomock.Controller) *MockVerifier {
+	mock := &MockVerifier{ctrl: ctrl}
+	mock.recorder = &MockVerifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockVerifier) EXPECT() *MockVerifierMockRecorder {
+	return m.recorder
+}
+
+// Verify mocks base method.
+func (m *MockVerifier) Verify(ctx context.Context, s secrets.Secrets) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockVerifierMockRecorder) Verify(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockVerifier)(nil).Verify), ctx, s)
+}
+
+// MockIdentifier is a mock of Identifier interface.
+type MockIdentifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdentifierMockRecorder
+}
+
+// MockIdentifierMockRecorder is the mock recorder for MockIdentifier.
+type MockIdentifierMockRecorder struct {
+	mock *MockIdentifier
+}
+
+// NewMockIdentifier creates a new mock instance.
+func NewMockIdentifier(ctrl *gomock.Controller) *MockIdentifier {
+	mock := &MockIdentifier{ctrl: ctrl}
+	mock.recorder = &MockIdentifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdentifier) EXPECT() *MockIdentifierMockRecorder {
+	return m.recorder
+}
+
+// SecretID mocks base method.
+func (m *MockIdentifier) SecretID(s secrets.Secrets) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SecretID", s)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// SecretID indicates an expected call of SecretID.
+func (mr *MockIdentifierMockRecorder) SecretID(s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SecretID", reflect.TypeOf((*MockIdentifier)(nil).SecretID), s)
+}
+
+// MockPlanner is a mock of Planner interface.
+type MockPlanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockPlannerMockRecorder
+}
+
+// MockPlannerMockRecorder is the mock recorder for MockPlanner.
+type MockPlannerMockRecorder struct {
+	mock *MockPlanner
+}
+
+// NewMockPlanner creates a new mock instance.
+func NewMockPlanner(ctrl *gomock.Controller) *MockPlanner {
+	mock := &MockPlanner{ctrl: ctrl}
+	mock.recorder = &MockPlannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPlanner) EXPECT() *MockPlannerMockRecorder {
+	return m.recorder
+}
+
+// Plan mocks base method.
+func (m *MockPlanner) Plan(ctx context.Context) (*plan.Step, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Plan", ctx)
+	ret0, _ := ret[0].(*plan.Step)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Plan indicates an expected call of Plan.
+func (mr *MockPlannerMockRecorder) Plan(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Plan", reflect.TypeOf((*MockPlanner)(nil).Plan), ctx)
+}
+
+// MockDiffer is a mock of Differ interface.
+type MockDiffer struct {
+	ctrl     *gomock.Controller
+	recorder *MockDifferMockRecorder
+}
+
+// MockDifferMockRecorder is the mock recorder for MockDiffer.
+type MockDifferMockRecorder struct {
+	mock *MockDiffer
+}
+
+// NewMockDiffer creates a new mock instance.
+func NewMockDiffer(ctrl *gomock.Controller) *MockDiffer {
+	mock := &MockDiffer{ctrl: ctrl}
+	mock.recorder = &MockDifferMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDiffer) EXPECT() *MockDifferMockRecorder {
+	return m.recorder
+}
+
+// Changes mocks base method.
+func (m *MockDiffer) Changes() []*plan.Change {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Changes")
+	ret0, _ := ret[0].([]*plan.Change)
+	return ret0
+}
+
+// Changes indicates an expected call of Changes.
+func (mr *MockDifferMockRecorder) Changes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Changes", reflect.TypeOf((*MockDiffer)(nil).Changes))
+}
+
+// MockRateLimited is a mock of RateLimited interface.
+type MockRateLimited struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimitedMockRecorder
+}
+
+// MockRateLimitedMockRecorder is the mock recorder for MockRateLimited.
+type MockRateLimitedMockRecorder struct {
+	mock *MockRateLimited
+}
+
+// NewMockRateLimited creates a new mock instance.
+func NewMockRateLimited(ctrl *gomock.Controller) *MockRateLimited {
+	mock := &MockRateLimited{ctrl: ctrl}
+	mock.recorder = &MockRateLimitedMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateLimited) EXPECT() *MockRateLimitedMockRecorder {
+	return m.recorder
+}
+
+// SetRateLimiter mocks base method.
+func (m *MockRateLimited) SetRateLimiter(l ratelimit.Limiter) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRateLimiter", l)
+}
+
+// SetRateLimiter indicates an expected call of SetRateLimiter.
+func (mr *MockRateLimitedMockRecorder) SetRateLimiter(l interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRateLimiter", reflect.TypeOf((*MockRateLimited)(nil).SetRateLimiter), l)
+}
+
+// MockRetrier is a mock of Retrier interface.
+type MockRetrier struct {
+	ctrl     *gomock.Controller
+	recorder *MockRetrierMockRecorder
+}
+
+// MockRetrierMockRecorder is the mock recorder for MockRetrier.
+type MockRetrierMockRecorder struct {
+	mock *MockRetrier
+}
+
+// NewMockRetrier creates a new mock instance.
+func NewMockRetrier(ctrl *gomock.Controller) *MockRetrier {
+	mock := &MockRetrier{ctrl: ctrl}
+	mock.recorder = &MockRetrierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRetrier) EXPECT() *MockRetrierMockRecorder {
+	return m.recorder
+}
+
+// Retries mocks base method.
+func (m *MockRetrier) Retries() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Retries")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Retries indicates an expected call of Retries.
+func (mr *MockRetrierMockRecorder) Retries() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retries", reflect.TypeOf((*MockRetrier)(nil).Retries))
+}
+
+// SetRetryPolicy mocks base method.
+func (m *MockRetrier) SetRetryPolicy(p retry.Policy) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRetryPolicy", p)
+}
+
+// SetRetryPolicy indicates an expected call of SetRetryPolicy.
+func (mr *MockRetrierMockRecorder) SetRetryPolicy(p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRetryPolicy", reflect.TypeOf((*MockRetrier)(nil).SetRetryPolicy), p)
+}
+
+// MockValidator is a mock of Validator interface.
+type MockValidator struct {
+	ctrl     *gomock.Controller
+	recorder *MockValidatorMockRecorder
+}
+
+// MockValidatorMockRecorder is the mock recorder for MockValidator.
+type MockValidatorMockRecorder struct {
+	mock *MockValidator
+}
+
+// NewMockValidator creates a new mock instance.
+func NewMockValidator(ctrl *gomock.Controller) *MockValidator {
+	mock := &MockValidator{ctrl: ctrl}
+	mock.recorder = &MockValidatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockValidator) EXPECT() *MockValidatorMockRecorder {
+	return m.recorder
+}
+
+// Validate mocks base method.
+func (m *MockValidator) Validate() []*validation.FieldError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate")
+	ret0, _ := ret[0].([]*validation.FieldError)
+	return ret0
+}
+
+// Validate indicates an expected call of Validate.
+func (mr *MockValidatorMockRecorder) Validate() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockValidator)(nil).Validate))
+}
+
+// MockStepper is a mock of Stepper interface.
+type MockStepper struct {
+	ctrl     *gomock.Controller
+	recorder *MockStepperMockRecorder
+}
+
+// MockStepperMockRecorder is the mock recorder for MockStepper.
+type MockStepperMockRecorder struct {
+	mock *MockStepper
+}
+
+// NewMockStepper creates a new mock instance.
+func NewMockStepper(ctrl *gomock.Controller) *MockStepper {
+	mock := &MockStepper{ctrl: ctrl}
+	mock.recorder = &MockStepperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStepper) EXPECT() *MockStepperMockRecorder {
+	return m.recorder
+}
+
+// Steps mocks base method.
+func (m *MockStepper) Steps() []*reporting.Step {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Steps")
+	ret0, _ := ret[0].([]*reporting.Step)
+	return ret0
+}
+
+// Steps indicates an expected call of Steps.
+func (mr *MockStepperMockRecorder) Steps() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Steps", reflect.TypeOf((*MockStepper)(nil).Steps))
+}
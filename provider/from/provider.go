@@ -3,8 +3,14 @@ package fromprovider
 
 import (
 	"context"
+	"time"
 
+	"github.com/grezar/revolver/plan"
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/retry"
 	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/validation"
+	"go.uber.org/ratelimit"
 )
 
 var (
@@ -21,6 +27,9 @@ func Get(providerType string) Provider {
 
 type Provider interface {
 	Name() string
+	// SecretKeys returns the keys of the secrets which the operators issue, so
+	// that the templates of the to providers can be checked against them.
+	SecretKeys() []string
 	UnmarshalSpec(bytes []byte) (Operator, error)
 }
 
@@ -28,3 +37,93 @@ type Operator interface {
 	Summary() string
 	Do(ctx context.Context, dryRun bool) (secrets.Secrets, error)
 }
+
+// Cleaner is implemented by operators that leave the previous secret in place
+// in Do and remove it in a separate step, so that the removal can be postponed
+// until the new secret has been distributed.
+type Cleaner interface {
+	Cleanup(ctx context.Context, dryRun bool) error
+}
+
+// Revoker is implemented by operators that can revoke the secret issued by Do
+// when the rotation is rolled back.
+type Revoker interface {
+	Revoke(ctx context.Context, s secrets.Secrets) error
+}
+
+// Stager is implemented by operators that support staged rotations, in which
+// the previous secret is deactivated after a grace period and deleted in a
+// later run instead of being removed by Cleanup.
+type Stager interface {
+	// Superseded returns the IDs of the previous secrets replaced by the
+	// secrets issued in the last Do.
+	Superseded() []string
+	// SetRetiring tells the IDs of the secrets being retired so that Do
+	// doesn't treat them as current secrets.
+	SetRetiring(ids []string)
+	Deactivate(ctx context.Context, dryRun bool, id string) error
+	Delete(ctx context.Context, dryRun bool, id string) error
+}
+
+// Expirer is implemented by operators whose secrets are rotated once they
+// reach an age, which the grace period of a staged rotation must be shorter
+// than.
+type Expirer interface {
+	// ExpiresAfter returns the age at which the secrets are rotated.
+	ExpiresAfter() (time.Duration, error)
+}
+
+// Verifier is implemented by operators that can check the secrets issued by Do
+// actually work before the previous secret is removed.
+type Verifier interface {
+	Verify(ctx context.Context, s secrets.Secrets) error
+}
+
+// Identifier is implemented by operators whose secrets have an identifier
+// that is not sensitive, like an access key ID, so that it can be recorded.
+type Identifier interface {
+	SecretID(s secrets.Secrets) string
+}
+
+// Planner is implemented by operators that can tell the changes Do would make
+// without making them, along with the live state the changes are based on.
+type Planner interface {
+	Plan(ctx context.Context) (*plan.Step, error)
+}
+
+// Differ is implemented by operators that can tell the changes made by the
+// last calls to Do and Cleanup, or which would have been made in dry-run mode,
+// so that a secret which is not due can be told from a new one.
+type Differ interface {
+	Changes() []*plan.Change
+}
+
+// RateLimited is implemented by operators that limit the rate of requests to
+// the API of the provider.
+type RateLimited interface {
+	// SetRateLimiter replaces the limiter the operator shares with the other
+	// operators of the provider.
+	SetRateLimiter(l ratelimit.Limiter)
+}
+
+// Retrier is implemented by operators that retry their API requests which are
+// safe to repeat, rather than having the whole call retried.
+type Retrier interface {
+	// SetRetryPolicy sets the policy the requests are retried with.
+	SetRetryPolicy(p retry.Policy)
+	// Retries returns the number of requests retried so far.
+	Retries() int
+}
+
+// Validator is implemented by operators that check their spec beyond the
+// validate tags of its fields, like the values which must be one of a few.
+type Validator interface {
+	Validate() []*validation.FieldError
+}
+
+// Stepper is implemented by operators that time the steps of the last call to
+// Do and Cleanup, like updating each variable, so that they are shown under the
+// provider in the report.
+type Stepper interface {
+	Steps() []*reporting.Step
+}
@@ -28,6 +28,10 @@ func (u *Stdin) Name() string {
 	return name
 }
 
+func (u *Stdin) SecretKeys() []string {
+	return []string{keyInput}
+}
+
 func (s *Spec) Summary() string {
 	return "input from stdin"
 }
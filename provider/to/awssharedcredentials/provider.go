@@ -3,12 +3,17 @@ package awssharedcredentials
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"sort"
 
 	"github.com/goccy/go-yaml"
+	"github.com/grezar/revolver/plan"
 	toprovider "github.com/grezar/revolver/provider/to"
 	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/validation"
 	"gopkg.in/ini.v1"
 )
 
@@ -43,9 +48,34 @@ func (a *AWSSharedCredentials) UnmarshalSpec(bytes []byte) (toprovider.Operator,
 
 // toprovider.Operator
 type Spec struct {
-	Path    string `yaml:"path"`
-	Profile string `yaml:"profile"`
+	Path    string `yaml:"path" validate:"required"`
+	Profile string `yaml:"profile" validate:"required"`
 	Secrets map[string]string
+	// snapshot holds the content of the file before Do, and absent tells the
+	// file didn't exist.
+	snapshot []byte
+	absent   bool
+	// changes holds the changes made by the last Do.
+	changes []*plan.Change
+}
+
+// Validate implements toprovider.Validator interface
+func (s *Spec) Validate(keys []string) []*validation.FieldError {
+	names := make([]string, 0, len(s.Secrets))
+	for k := range s.Secrets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var errs []*validation.FieldError
+	for _, k := range names {
+		if err := secrets.ValidateTemplate(s.Secrets[k], keys); err != nil {
+			errs = append(errs, &validation.FieldError{
+				Message: fmt.Sprintf("%s: %v", k, err),
+			})
+		}
+	}
+	return errs
 }
 
 func (s *Spec) Summary() string {
@@ -54,6 +84,8 @@ func (s *Spec) Summary() string {
 
 // Do implements toprovider.Operator interface
 func (s *Spec) Do(ctx context.Context, dryRun bool) error {
+	s.changes = nil
+
 	c, err := ini.Load(s.Path)
 	if err != nil {
 		return err
@@ -66,6 +98,10 @@ func (s *Spec) Do(ctx context.Context, dryRun bool) error {
 		}
 		c.Section(s.Profile).Key(k).SetValue(secret)
 	}
+	s.changes = append(s.changes, &plan.Change{
+		Action:   plan.Write,
+		Resource: s.resource(),
+	})
 
 	if !dryRun {
 		f, err := os.Create(s.Path)
@@ -87,3 +123,53 @@ func (s *Spec) Do(ctx context.Context, dryRun bool) error {
 
 	return nil
 }
+
+// Plan implements toprovider.Planner interface
+func (s *Spec) Plan(ctx context.Context) (*plan.Step, error) {
+	if _, err := ini.Load(s.Path); err != nil {
+		return nil, err
+	}
+	return &plan.Step{
+		Changes: []*plan.Change{
+			{Action: plan.Write, Resource: s.resource()},
+		},
+	}, nil
+}
+
+// Changes implements toprovider.Differ interface
+func (s *Spec) Changes() []*plan.Change {
+	return s.changes
+}
+
+func (s *Spec) resource() string {
+	return fmt.Sprintf("profile %s in %s", s.Profile, s.Path)
+}
+
+// Snapshot implements toprovider.Restorer interface
+func (s *Spec) Snapshot(ctx context.Context) error {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.snapshot, s.absent = nil, true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.snapshot, s.absent = b, false
+	return nil
+}
+
+// Restore implements toprovider.Restorer interface. A file which didn't exist
+// before Do is removed.
+func (s *Spec) Restore(ctx context.Context) error {
+	if s.absent {
+		if err := os.Remove(s.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if s.snapshot == nil {
+		return errors.New("no snapshot to restore")
+	}
+	return os.WriteFile(s.Path, s.snapshot, 0600)
+}
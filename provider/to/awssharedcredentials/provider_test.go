@@ -0,0 +1,66 @@
+package awssharedcredentials
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpec_Restore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("existing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "credentials")
+		if err := os.WriteFile(path, []byte("[default]\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		s := &Spec{Path: path}
+		if err := s.Snapshot(ctx); err != nil {
+			t.Fatalf("Spec.Snapshot() error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte("[default]\naws_access_key_id = new\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Restore(ctx); err != nil {
+			t.Fatalf("Spec.Restore() error = %v", err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), "[default]\n"; got != want {
+			t.Errorf("content = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("absent file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "credentials")
+		s := &Spec{Path: path}
+		if err := s.Snapshot(ctx); err != nil {
+			t.Fatalf("Spec.Snapshot() error = %v", err)
+		}
+		// Do didn't create the file.
+		if err := s.Restore(ctx); err != nil {
+			t.Fatalf("Spec.Restore() error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte("[default]\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Restore(ctx); err != nil {
+			t.Fatalf("Spec.Restore() error = %v", err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("os.Stat() error = %v, want %v", err, fs.ErrNotExist)
+		}
+	})
+
+	t.Run("no snapshot", func(t *testing.T) {
+		s := &Spec{Path: filepath.Join(t.TempDir(), "credentials")}
+		if err := s.Restore(ctx); err == nil {
+			t.Error("Spec.Restore() error = nil, want error")
+		}
+	})
+}
@@ -2,14 +2,20 @@ package circleci
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
 	"github.com/goccy/go-yaml"
 	"github.com/grezar/go-circleci"
+	"github.com/grezar/revolver/plan"
 	toprovider "github.com/grezar/revolver/provider/to"
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/retry"
 	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/validation"
 	"go.uber.org/ratelimit"
 )
 
@@ -50,26 +56,72 @@ func (t *CircleCI) UnmarshalSpec(bytes []byte) (toprovider.Operator, error) {
 
 // toprovider.Operator
 type Spec struct {
-	Owner            string             `yaml:"owner"`
-	ProjectVariables []*ProjectVariable `yaml:"projectVariables"`
-	Contexts         []*Context         `yaml:"contexts"`
+	Owner            string             `yaml:"owner" validate:"required"`
+	ProjectVariables []*ProjectVariable `yaml:"projectVariables" validate:"dive"`
+	Contexts         []*Context         `yaml:"contexts" validate:"dive"`
 	Client           *circleci.Client
 	RateLimit        ratelimit.Limiter
+	// projectSnapshot and contextSnapshot hold the names of the variables
+	// which existed before Do, keyed by the project and the context name.
+	projectSnapshot map[string]map[string]bool
+	contextSnapshot map[string]map[string]bool
+	// changes holds the changes made by the last Do.
+	changes []*plan.Change
+	// steps holds the calls to the API made by the last Do.
+	steps   []*reporting.Step
+	retrier retry.Retrier
 }
 
 type ProjectVariable struct {
-	Project   string      `yaml:"project"`
-	Variables []*Variable `yaml:"variables"`
+	Project   string      `yaml:"project" validate:"required"`
+	Variables []*Variable `yaml:"variables" validate:"dive"`
 }
 
 type Context struct {
-	Name      string      `yaml:"name"`
-	Variables []*Variable `yaml:"variables"`
+	Name      string      `yaml:"name" validate:"required"`
+	Variables []*Variable `yaml:"variables" validate:"dive"`
 }
 
 type Variable struct {
-	Name  string `yaml:"name"`
-	Value string `yaml:"value"`
+	Name  string `yaml:"name" validate:"required"`
+	Value string `yaml:"value" validate:"required"`
+}
+
+// SetRateLimiter implements toprovider.RateLimited interface
+func (s *Spec) SetRateLimiter(l ratelimit.Limiter) {
+	s.RateLimit = l
+}
+
+// SetRetryPolicy implements toprovider.Retrier interface
+func (s *Spec) SetRetryPolicy(p retry.Policy) {
+	s.retrier.SetRetryPolicy(p)
+}
+
+// Retries implements toprovider.Retrier interface
+func (s *Spec) Retries() int {
+	return s.retrier.Retries()
+}
+
+// Validate implements toprovider.Validator interface
+func (s *Spec) Validate(keys []string) []*validation.FieldError {
+	var errs []*validation.FieldError
+	validateVariables := func(parent string, variables []*Variable) {
+		for i, v := range variables {
+			if err := secrets.ValidateTemplate(v.Value, keys); err != nil {
+				errs = append(errs, &validation.FieldError{
+					Field:   fmt.Sprintf("%s.variables[%d].value", parent, i),
+					Message: err.Error(),
+				})
+			}
+		}
+	}
+	for i, pv := range s.ProjectVariables {
+		validateVariables(fmt.Sprintf("projectVariables[%d]", i), pv.Variables)
+	}
+	for i, c := range s.Contexts {
+		validateVariables(fmt.Sprintf("contexts[%d]", i), c.Variables)
+	}
+	return errs
 }
 
 func (s *Spec) Summary() string {
@@ -95,8 +147,16 @@ func (s *Spec) Summary() string {
 }
 
 func (s *Spec) buildClient() (*circleci.Client, error) {
+	if s.Client != nil {
+		return s.Client, nil
+	}
+
 	config := &circleci.Config{
 		Token: os.Getenv(revolverCircleCITokenKey),
+		// Requests rejected by rate limiting, and the idempotent ones which
+		// failed with server errors, are retried with the retry policy of the
+		// provider.
+		HTTPClient: &http.Client{Transport: &retry.Transport{Retrier: &s.retrier}},
 	}
 	client, err := circleci.NewClient(config)
 	if err != nil {
@@ -108,6 +168,9 @@ func (s *Spec) buildClient() (*circleci.Client, error) {
 
 // Do implements toprovider.Operator interface
 func (s *Spec) Do(ctx context.Context, dryRun bool) error {
+	s.changes = nil
+	s.steps = nil
+
 	api, err := s.buildClient()
 	if err != nil {
 		return err
@@ -146,11 +209,17 @@ func (s *Spec) UpdateProjectVariables(ctx context.Context, dryRun bool, api *cir
 		}
 
 		for _, v := range pv.Variables {
+			action := plan.Create
 			// if the project variable already exists with the same name, delete it before creating a new one
 			if projectVariableList[v.Name] != nil {
+				action = plan.Replace
 				if !dryRun {
 					ratelimit.Take()
-					err := api.Projects.DeleteVariable(ctx, pv.Project, v.Name)
+					deletion := &plan.Change{Action: plan.Delete, Resource: projectVariableResource(pv.Project, v.Name)}
+					step, err := reporting.TimeStep(deletion.String(), func() error {
+						return api.Projects.DeleteVariable(ctx, pv.Project, v.Name)
+					})
+					s.steps = append(s.steps, step)
 					if err != nil {
 						return err
 					}
@@ -161,13 +230,24 @@ func (s *Spec) UpdateProjectVariables(ctx context.Context, dryRun bool, api *cir
 			if err != nil {
 				return err
 			}
+			change := &plan.Change{
+				Action:   action,
+				Resource: projectVariableResource(pv.Project, v.Name),
+				Value:    variableValue,
+			}
+			s.changes = append(s.changes, change)
 
 			if !dryRun {
 				ratelimit.Take()
-				_, err = api.Projects.CreateVariable(ctx, pv.Project, circleci.ProjectCreateVariableOptions{
-					Name:  circleci.String(v.Name),
-					Value: circleci.String(variableValue),
+				creation := &plan.Change{Action: plan.Create, Resource: change.Resource}
+				step, err := reporting.TimeStep(creation.String(), func() error {
+					_, err := api.Projects.CreateVariable(ctx, pv.Project, circleci.ProjectCreateVariableOptions{
+						Name:  circleci.String(v.Name),
+						Value: circleci.String(variableValue),
+					})
+					return err
 				})
+				s.steps = append(s.steps, step)
 				if err != nil {
 					return err
 				}
@@ -179,32 +259,9 @@ func (s *Spec) UpdateProjectVariables(ctx context.Context, dryRun bool, api *cir
 }
 
 func (s *Spec) UpdateContexts(ctx context.Context, dryRun bool, api *circleci.Client, ratelimit ratelimit.Limiter) error {
-	var contexts []*circleci.Context
-	var err error
-	cl := &circleci.ContextList{
-		NextPageToken: "",
-	}
-
-	for {
-		ratelimit.Take()
-		cl, err = api.Contexts.List(ctx, circleci.ContextListOptions{
-			OwnerSlug: circleci.String(s.Owner),
-			PageToken: circleci.String(cl.NextPageToken),
-		})
-		if err != nil {
-			return err
-		}
-
-		contexts = append(contexts, cl.Items...)
-
-		if cl.NextPageToken == "" {
-			break
-		}
-	}
-
-	contextList := make(map[string]*circleci.Context)
-	for _, c := range contexts {
-		contextList[c.Name] = c
+	contextList, err := s.listContexts(ctx, api, ratelimit)
+	if err != nil {
+		return err
 	}
 
 	for _, c := range s.Contexts {
@@ -220,12 +277,24 @@ func (s *Spec) UpdateContexts(ctx context.Context, dryRun bool, api *circleci.Cl
 			if err != nil {
 				return err
 			}
+			// The variable is added or updated in one call, so whether it
+			// exists is not known here.
+			change := &plan.Change{
+				Action:   plan.Write,
+				Resource: contextVariableResource(c.Name, v.Name),
+				Value:    variableValue,
+			}
+			s.changes = append(s.changes, change)
 
 			if !dryRun {
 				ratelimit.Take()
-				_, err = api.Contexts.AddOrUpdateVariable(ctx, contextID, v.Name, circleci.ContextAddOrUpdateVariableOptions{
-					Value: circleci.String(variableValue),
+				step, err := reporting.TimeStep(change.String(), func() error {
+					_, err := api.Contexts.AddOrUpdateVariable(ctx, contextID, v.Name, circleci.ContextAddOrUpdateVariableOptions{
+						Value: circleci.String(variableValue),
+					})
+					return err
 				})
+				s.steps = append(s.steps, step)
 				if err != nil {
 					return err
 				}
@@ -235,3 +304,241 @@ func (s *Spec) UpdateContexts(ctx context.Context, dryRun bool, api *circleci.Cl
 
 	return nil
 }
+
+// Snapshot implements toprovider.Restorer interface. CircleCI never returns
+// the value of a variable, so only the names of the existing variables are
+// recorded, and Snapshot fails if one of the variables exists, before anything
+// is changed.
+func (s *Spec) Snapshot(ctx context.Context) error {
+	api, err := s.buildClient()
+	if err != nil {
+		return err
+	}
+
+	projects, contexts, err := s.existingVariables(ctx, api)
+	if err != nil {
+		return err
+	}
+
+	var unrestorable []string
+	for _, pv := range s.ProjectVariables {
+		for _, v := range pv.Variables {
+			if projects[pv.Project][v.Name] {
+				unrestorable = append(unrestorable, fmt.Sprintf("%s/%s", pv.Project, v.Name))
+			}
+		}
+	}
+	for _, c := range s.Contexts {
+		for _, v := range c.Variables {
+			if contexts[c.Name][v.Name] {
+				unrestorable = append(unrestorable, fmt.Sprintf("%s/%s", c.Name, v.Name))
+			}
+		}
+	}
+	if len(unrestorable) > 0 {
+		return fmt.Errorf("existing variables cannot be restored: %s", strings.Join(unrestorable, ", "))
+	}
+	s.projectSnapshot, s.contextSnapshot = projects, contexts
+	return nil
+}
+
+// Plan implements toprovider.Planner interface
+func (s *Spec) Plan(ctx context.Context) (*plan.Step, error) {
+	api, err := s.buildClient()
+	if err != nil {
+		return nil, err
+	}
+
+	projects, contexts, err := s.existingVariables(ctx, api)
+	if err != nil {
+		return nil, err
+	}
+
+	step := &plan.Step{}
+	for _, pv := range s.ProjectVariables {
+		for _, v := range pv.Variables {
+			action := plan.Create
+			if projects[pv.Project][v.Name] {
+				action = plan.Replace
+			}
+			step.Changes = append(step.Changes, &plan.Change{
+				Action:   action,
+				Resource: projectVariableResource(pv.Project, v.Name),
+			})
+		}
+	}
+	for _, c := range s.Contexts {
+		for _, v := range c.Variables {
+			action := plan.Create
+			if contexts[c.Name][v.Name] {
+				action = plan.Update
+			}
+			step.Changes = append(step.Changes, &plan.Change{
+				Action:   action,
+				Resource: contextVariableResource(c.Name, v.Name),
+			})
+		}
+	}
+	return step, nil
+}
+
+// Changes implements toprovider.Differ interface
+func (s *Spec) Changes() []*plan.Change {
+	return s.changes
+}
+
+// Steps implements toprovider.Stepper interface
+func (s *Spec) Steps() []*reporting.Step {
+	return s.steps
+}
+
+func projectVariableResource(project, name string) string {
+	return fmt.Sprintf("project variable %s in %s", name, project)
+}
+
+func contextVariableResource(contextName, name string) string {
+	return fmt.Sprintf("context variable %s in %s", name, contextName)
+}
+
+// existingVariables returns the names of the existing variables keyed by the
+// project and the context name.
+func (s *Spec) existingVariables(ctx context.Context, api *circleci.Client) (map[string]map[string]bool, map[string]map[string]bool, error) {
+	projects := make(map[string]map[string]bool)
+	for _, pv := range s.ProjectVariables {
+		s.RateLimit.Take()
+		pvl, err := api.Projects.ListVariables(ctx, pv.Project)
+		if err != nil {
+			return nil, nil, err
+		}
+		projects[pv.Project] = make(map[string]bool)
+		for _, v := range pvl.Items {
+			projects[pv.Project][v.Name] = true
+		}
+	}
+
+	contexts := make(map[string]map[string]bool)
+	if len(s.Contexts) > 0 {
+		contextList, err := s.listContexts(ctx, api, s.RateLimit)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range s.Contexts {
+			cc, ok := contextList[c.Name]
+			if !ok {
+				return nil, nil, fmt.Errorf("circleci context not found: %s", c.Name)
+			}
+			s.RateLimit.Take()
+			cvl, err := api.Contexts.ListVariables(ctx, cc.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			contexts[c.Name] = make(map[string]bool)
+			for _, v := range cvl.Items {
+				contexts[c.Name][v.Variable] = true
+			}
+		}
+	}
+
+	return projects, contexts, nil
+}
+
+// Restore implements toprovider.Restorer interface. Variables created by Do
+// are deleted. Variables which existed before cannot be restored since their
+// previous value is unknown.
+func (s *Spec) Restore(ctx context.Context) error {
+	if s.projectSnapshot == nil || s.contextSnapshot == nil {
+		return errors.New("no snapshot to restore")
+	}
+
+	api, err := s.buildClient()
+	if err != nil {
+		return err
+	}
+
+	projects, contexts, err := s.existingVariables(ctx, api)
+	if err != nil {
+		return err
+	}
+
+	var unrestorable []string
+
+	for _, pv := range s.ProjectVariables {
+		for _, v := range pv.Variables {
+			if s.projectSnapshot[pv.Project][v.Name] {
+				unrestorable = append(unrestorable, fmt.Sprintf("%s/%s", pv.Project, v.Name))
+				continue
+			}
+			if !projects[pv.Project][v.Name] {
+				// Do didn't reach this variable.
+				continue
+			}
+			s.RateLimit.Take()
+			if err := api.Projects.DeleteVariable(ctx, pv.Project, v.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(s.Contexts) > 0 {
+		contextList, err := s.listContexts(ctx, api, s.RateLimit)
+		if err != nil {
+			return err
+		}
+		for _, c := range s.Contexts {
+			cc, ok := contextList[c.Name]
+			if !ok {
+				return fmt.Errorf("circleci context not found: %s", c.Name)
+			}
+			for _, v := range c.Variables {
+				if s.contextSnapshot[c.Name][v.Name] {
+					unrestorable = append(unrestorable, fmt.Sprintf("%s/%s", c.Name, v.Name))
+					continue
+				}
+				if !contexts[c.Name][v.Name] {
+					// Do didn't reach this variable.
+					continue
+				}
+				s.RateLimit.Take()
+				if err := api.Contexts.RemoveVariable(ctx, cc.ID, v.Name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if len(unrestorable) > 0 {
+		return fmt.Errorf("existing variables cannot be restored: %s", strings.Join(unrestorable, ", "))
+	}
+	return nil
+}
+
+func (s *Spec) listContexts(ctx context.Context, api *circleci.Client, ratelimit ratelimit.Limiter) (map[string]*circleci.Context, error) {
+	var contexts []*circleci.Context
+	var err error
+	cl := &circleci.ContextList{
+		NextPageToken: "",
+	}
+
+	for {
+		ratelimit.Take()
+		cl, err = api.Contexts.List(ctx, circleci.ContextListOptions{
+			OwnerSlug: circleci.String(s.Owner),
+			PageToken: circleci.String(cl.NextPageToken),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		contexts = append(contexts, cl.Items...)
+
+		if cl.NextPageToken == "" {
+			break
+		}
+	}
+
+	contextList := make(map[string]*circleci.Context)
+	for _, c := range contexts {
+		contextList[c.Name] = c
+	}
+	return contextList, nil
+}
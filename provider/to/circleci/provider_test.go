@@ -3,11 +3,13 @@ package circleci
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	"github.com/grezar/go-circleci"
 	mock "github.com/grezar/go-circleci/mocks"
+	"github.com/grezar/revolver/plan"
 	"go.uber.org/ratelimit"
 )
 
@@ -492,3 +494,186 @@ func TestSpec_UpdateiContexts(t *testing.T) {
 		})
 	}
 }
+
+func TestSpec_Plan(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	projects := mock.NewMockProjects(ctrl)
+	projects.EXPECT().ListVariables(ctx, "gh/org1/repo1").Return(&circleci.ProjectVariableList{
+		Items: []*circleci.ProjectVariable{
+			{Name: "SECRET1"},
+		},
+	}, nil)
+	contexts := mock.NewMockContexts(ctrl)
+	contexts.EXPECT().List(ctx, circleci.ContextListOptions{
+		OwnerSlug: circleci.String("org1"),
+		PageToken: circleci.String(""),
+	}).Return(&circleci.ContextList{
+		Items: []*circleci.Context{
+			{
+				ID:   "ctx-1",
+				Name: "ctx1",
+			},
+		},
+	}, nil)
+	contexts.EXPECT().ListVariables(ctx, "ctx-1").Return(&circleci.ContextVariableList{
+		Items: []*circleci.ContextVariable{
+			{Variable: "SECRET4"},
+		},
+	}, nil)
+
+	s := &Spec{
+		Owner: "org1",
+		ProjectVariables: []*ProjectVariable{
+			{
+				Project: "gh/org1/repo1",
+				Variables: []*Variable{
+					{Name: "SECRET1", Value: "111"},
+					{Name: "SECRET2", Value: "222"},
+				},
+			},
+		},
+		Contexts: []*Context{
+			{
+				Name: "ctx1",
+				Variables: []*Variable{
+					{Name: "SECRET3", Value: "333"},
+					{Name: "SECRET4", Value: "444"},
+				},
+			},
+		},
+		Client: &circleci.Client{
+			Projects: projects,
+			Contexts: contexts,
+		},
+		RateLimit: ratelimit.New(apiRateLimit),
+	}
+
+	got, err := s.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Spec.Plan() error = %v", err)
+	}
+	want := &plan.Step{
+		Changes: []*plan.Change{
+			{Action: plan.Replace, Resource: "project variable SECRET1 in gh/org1/repo1"},
+			{Action: plan.Create, Resource: "project variable SECRET2 in gh/org1/repo1"},
+			{Action: plan.Create, Resource: "context variable SECRET3 in ctx1"},
+			{Action: plan.Update, Resource: "context variable SECRET4 in ctx1"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Spec.Plan() = %v, want %v", got, want)
+	}
+}
+
+func TestSpec_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	projects := mock.NewMockProjects(ctrl)
+	projects.EXPECT().ListVariables(ctx, "gh/org1/repo1").Return(&circleci.ProjectVariableList{
+		Items: []*circleci.ProjectVariable{
+			{Name: "SECRET1"},
+		},
+	}, nil).Times(2)
+
+	s := &Spec{
+		Owner: "org1",
+		ProjectVariables: []*ProjectVariable{
+			{
+				Project: "gh/org1/repo1",
+				Variables: []*Variable{
+					{Name: "SECRET2", Value: "222"},
+				},
+			},
+		},
+		Client: &circleci.Client{
+			Projects: projects,
+		},
+		RateLimit: ratelimit.New(apiRateLimit),
+	}
+
+	if err := s.Snapshot(ctx); err != nil {
+		t.Fatalf("Spec.Snapshot() error = %v", err)
+	}
+
+	// The previous value of an existing variable cannot be restored.
+	s.ProjectVariables[0].Variables = append(s.ProjectVariables[0].Variables, &Variable{Name: "SECRET1", Value: "111"})
+	err := s.Snapshot(ctx)
+	if want := "existing variables cannot be restored: gh/org1/repo1/SECRET1"; err == nil || err.Error() != want {
+		t.Errorf("Spec.Snapshot() error = %v, want %s", err, want)
+	}
+}
+
+func TestSpec_Restore(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+
+	projects := mock.NewMockProjects(ctrl)
+	contexts := mock.NewMockContexts(ctrl)
+	contexts.EXPECT().List(ctx, circleci.ContextListOptions{
+		OwnerSlug: circleci.String("org1"),
+		PageToken: circleci.String(""),
+	}).Return(&circleci.ContextList{
+		Items: []*circleci.Context{
+			{
+				ID:   "ctx-1",
+				Name: "ctx1",
+			},
+		},
+	}, nil).Times(3)
+	gomock.InOrder(
+		// Snapshot
+		projects.EXPECT().ListVariables(ctx, "gh/org1/repo1").Return(&circleci.ProjectVariableList{}, nil),
+		contexts.EXPECT().ListVariables(ctx, "ctx-1").Return(&circleci.ContextVariableList{}, nil),
+		// Restore
+		projects.EXPECT().ListVariables(ctx, "gh/org1/repo1").Return(&circleci.ProjectVariableList{
+			Items: []*circleci.ProjectVariable{
+				{Name: "SECRET1"},
+			},
+		}, nil),
+		contexts.EXPECT().ListVariables(ctx, "ctx-1").Return(&circleci.ContextVariableList{
+			Items: []*circleci.ContextVariable{
+				{Variable: "SECRET3"},
+			},
+		}, nil),
+		projects.EXPECT().DeleteVariable(ctx, "gh/org1/repo1", "SECRET1").Return(nil),
+		contexts.EXPECT().RemoveVariable(ctx, "ctx-1", "SECRET3").Return(nil),
+	)
+
+	// Do failed before it created SECRET2 and SECRET4, which are left as is.
+	s := &Spec{
+		Owner: "org1",
+		ProjectVariables: []*ProjectVariable{
+			{
+				Project: "gh/org1/repo1",
+				Variables: []*Variable{
+					{Name: "SECRET1", Value: "111"},
+					{Name: "SECRET2", Value: "222"},
+				},
+			},
+		},
+		Contexts: []*Context{
+			{
+				Name: "ctx1",
+				Variables: []*Variable{
+					{Name: "SECRET3", Value: "333"},
+					{Name: "SECRET4", Value: "444"},
+				},
+			},
+		},
+		Client: &circleci.Client{
+			Projects: projects,
+			Contexts: contexts,
+		},
+		RateLimit: ratelimit.New(apiRateLimit),
+	}
+
+	if err := s.Snapshot(ctx); err != nil {
+		t.Fatalf("Spec.Snapshot() error = %v", err)
+	}
+	if err := s.Restore(ctx); err != nil {
+		t.Errorf("Spec.Restore() error = %v", err)
+	}
+}
@@ -9,7 +9,12 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	plan "github.com/grezar/revolver/plan"
 	toprovider "github.com/grezar/revolver/provider/to"
+	reporting "github.com/grezar/revolver/reporting"
+	retry "github.com/grezar/revolver/retry"
+	validation "github.com/grezar/revolver/validation"
+	ratelimit "go.uber.org/ratelimit"
 )
 
 // MockProvider is a mock of Provider interface.
@@ -114,3 +119,287 @@ func (mr *MockOperatorMockRecorder) Summary() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Summary", reflect.TypeOf((*MockOperator)(nil).Summary))
 }
+
+// MockRestorer is a mock of Restorer interface.
+type MockRestorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockRestorerMockRecorder
+}
+
+// MockRestorerMockRecorder is the mock recorder for MockRestorer.
+type MockRestorerMockRecorder struct {
+	mock *MockRestorer
+}
+
+// NewMockRestorer creates a new mock instance.
+func NewMockRestorer(ctrl *gomock.Controller) *MockRestorer {
+	mock := &MockRestorer{ctrl: ctrl}
+	mock.recorder = &MockRestorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRestorer) EXPECT() *MockRestorerMockRecorder {
+	return m.recorder
+}
+
+// Restore mocks base method.
+func (m *MockRestorer) Restore(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockRestorerMockRecorder) Restore(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockRestorer)(nil).Restore), ctx)
+}
+
+// Snapshot mocks base method.
+func (m *MockRestorer) Snapshot(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Snapshot", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Snapshot indicates an expected call of Snapshot.
+func (mr *MockRestorerMockRecorder) Snapshot(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Snapshot", reflect.TypeOf((*MockRestorer)(nil).Snapshot), ctx)
+}
+
+// MockPlanner is a mock of Planner interface.
+type MockPlanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockPlannerMockRecorder
+}
+
+// MockPlannerMockRecorder is the mock recorder for MockPlanner.
+type MockPlannerMockRecorder struct {
+	mock *MockPlanner
+}
+
+// NewMockPlanner creates a new mock instance.
+func NewMockPlanner(ctrl *gomock.Controller) *MockPlanner {
+	mock := &MockPlanner{ctrl: ctrl}
+	mock.recorder = &MockPlannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPlanner) EXPECT() *MockPlannerMockRecorder {
+	return m.recorder
+}
+
+// Plan mocks base method.
+func (m *MockPlanner) Plan(ctx context.Context) (*plan.Step, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Plan", ctx)
+	ret0, _ := ret[0].(*plan.Step)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Plan indicates an expected call of Plan.
+func (mr *MockPlannerMockRecorder) Plan(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Plan", reflect.TypeOf((*MockPlanner)(nil).Plan), ctx)
+}
+
+// MockDiffer is a mock of Differ interface.
+type MockDiffer struct {
+	ctrl     *gomock.Controller
+	recorder *MockDifferMockRecorder
+}
+
+// MockDifferMockRecorder is the mock recorder for MockDiffer.
+type MockDifferMockRecorder struct {
+	mock *MockDiffer
+}
+
+// NewMockDiffer creates a new mock instance.
+func NewMockDiffer(ctrl *gomock.Controller) *MockDiffer {
+	mock := &MockDiffer{ctrl: ctrl}
+	mock.recorder = &MockDifferMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDiffer) EXPECT() *MockDifferMockRecorder {
+	return m.recorder
+}
+
+// Changes mocks base method.
+func (m *MockDiffer) Changes() []*plan.Change {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Changes")
+	ret0, _ := ret[0].([]*plan.Change)
+	return ret0
+}
+
+// Changes indicates an expected call of Changes.
+func (mr *MockDifferMockRecorder) Changes() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Changes", reflect.TypeOf((*MockDiffer)(nil).Changes))
+}
+
+// MockRateLimited is a mock of RateLimited interface.
+type MockRateLimited struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimitedMockRecorder
+}
+
+// MockRateLimitedMockRecorder is the mock recorder for MockRateLimited.
+type MockRateLimitedMockRecorder struct {
+	mock *MockRateLimited
+}
+
+// NewMockRateLimited creates a new mock instance.
+func NewMockRateLimited(ctrl *gomock.Controller) *MockRateLimited {
+	mock := &MockRateLimited{ctrl: ctrl}
+	mock.recorder = &MockRateLimitedMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateLimited) EXPECT() *MockRateLimitedMockRecorder {
+	return m.recorder
+}
+
+// SetRateLimiter mocks base method.
+func (m *MockRateLimited) SetRateLimiter(l ratelimit.Limiter) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRateLimiter", l)
+}
+
+// SetRateLimiter indicates an expected call of SetRateLimiter.
+func (mr *MockRateLimitedMockRecorder) SetRateLimiter(l interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRateLimiter", reflect.TypeOf((*MockRateLimited)(nil).SetRateLimiter), l)
+}
+
+// MockRetrier is a mock of Retrier interface.
+type MockRetrier struct {
+	ctrl     *gomock.Controller
+	recorder *MockRetrierMockRecorder
+}
+
+// MockRetrierMockRecorder is the mock recorder for MockRetrier.
+type MockRetrierMockRecorder struct {
+	mock *MockRetrier
+}
+
+// NewMockRetrier creates a new mock instance.
+func NewMockRetrier(ctrl *gomock.Controller) *MockRetrier {
+	mock := &MockRetrier{ctrl: ctrl}
+	mock.recorder = &MockRetrierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRetrier) EXPECT() *MockRetrierMockRecorder {
+	return m.recorder
+}
+
+// Retries mocks base method.
+func (m *MockRetrier) Retries() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Retries")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Retries indicates an expected call of Retries.
+func (mr *MockRetrierMockRecorder) Retries() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retries", reflect.TypeOf((*MockRetrier)(nil).Retries))
+}
+
+// SetRetryPolicy mocks base method.
+func (m *MockRetrier) SetRetryPolicy(p retry.Policy) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRetryPolicy", p)
+}
+
+// SetRetryPolicy indicates an expected call of SetRetryPolicy.
+func (mr *MockRetrierMockRecorder) SetRetryPolicy(p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRetryPolicy", reflect.TypeOf((*MockRetrier)(nil).SetRetryPolicy), p)
+}
+
+// MockValidator is a mock of Validator interface.
+type MockValidator struct {
+	ctrl     *gomock.Controller
+	recorder *MockValidatorMockRecorder
+}
+
+// MockValidatorMockRecorder is the mock recorder for MockValidator.
+type MockValidatorMockRecorder struct {
+	mock *MockValidator
+}
+
+// NewMockValidator creates a new mock instance.
+func NewMockValidator(ctrl *gomock.Controller) *MockValidator {
+	mock := &MockValidator{ctrl: ctrl}
+	mock.recorder = &MockValidatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockValidator) EXPECT() *MockValidatorMockRecorder {
+	return m.recorder
+}
+
+// Validate mocks base method.
+func (m *MockValidator) Validate(keys []string) []*validation.FieldError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", keys)
+	ret0, _ := ret[0].([]*validation.FieldError)
+	return ret0
+}
+
+// Validate indicates an expected call of Validate.
+func (mr *MockValidatorMockRecorder) Validate(keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockValidator)(nil).Validate), keys)
+}
+
+// MockStepper is a mock of Stepper interface.
+type MockStepper struct {
+	ctrl     *gomock.Controller
+	recorder *MockStepperMockRecorder
+}
+
+// MockStepperMockRecorder is the mock recorder for MockStepper.
+type MockStepperMockRecorder struct {
+	mock *MockStepper
+}
+
+// NewMockStepper creates a new mock instance.
+func NewMockStepper(ctrl *gomock.Controller) *MockStepper {
+	mock := &MockStepper{ctrl: ctrl}
+	mock.recorder = &MockStepperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStepper) EXPECT() *MockStepperMockRecorder {
+	return m.recorder
+}
+
+// Steps mocks base method.
+func (m *MockStepper) Steps() []*reporting.Step {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Steps")
+	ret0, _ := ret[0].([]*reporting.Step)
+	return ret0
+}
+
+// Steps indicates an expected call of Steps.
+func (mr *MockStepperMockRecorder) Steps() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Steps", reflect.TypeOf((*MockStepper)(nil).Steps))
+}
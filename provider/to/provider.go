@@ -1,7 +1,15 @@
 //go:generate mockgen -source=$GOFILE -destination=mocks/$GOFILE
 package toprovider
 
-import "context"
+import (
+	"context"
+
+	"github.com/grezar/revolver/plan"
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/retry"
+	"github.com/grezar/revolver/validation"
+	"go.uber.org/ratelimit"
+)
 
 var (
 	registry = map[string]Provider{}
@@ -24,3 +32,55 @@ type Operator interface {
 	Summary() string
 	Do(ctx context.Context, dryRun bool) error
 }
+
+// Restorer is implemented by operators that can take a snapshot of the values
+// they are about to overwrite and put them back when the rotation is rolled
+// back.
+type Restorer interface {
+	Snapshot(ctx context.Context) error
+	Restore(ctx context.Context) error
+}
+
+// Planner is implemented by operators that can tell the changes Do would make
+// without making them, along with the live state the changes are based on.
+type Planner interface {
+	Plan(ctx context.Context) (*plan.Step, error)
+}
+
+// Differ is implemented by operators that can tell the changes the last Do
+// made, or would have made in dry-run mode.
+type Differ interface {
+	Changes() []*plan.Change
+}
+
+// RateLimited is implemented by operators that limit the rate of requests to
+// the API of the provider.
+type RateLimited interface {
+	// SetRateLimiter replaces the limiter the operator shares with the other
+	// operators of the provider.
+	SetRateLimiter(l ratelimit.Limiter)
+}
+
+// Retrier is implemented by operators that retry their API requests which are
+// safe to repeat, rather than having the whole call retried.
+type Retrier interface {
+	// SetRetryPolicy sets the policy the requests are retried with.
+	SetRetryPolicy(p retry.Policy)
+	// Retries returns the number of requests retried so far.
+	Retries() int
+}
+
+// Validator is implemented by operators that check their spec beyond the
+// validate tags of its fields, like the values which must be one of a few.
+type Validator interface {
+	// Validate checks the spec, given the keys of the secrets which the from
+	// provider issues.
+	Validate(keys []string) []*validation.FieldError
+}
+
+// Stepper is implemented by operators that time the steps of the last call to
+// Do, like updating each variable, so that they are shown under the
+// provider in the report.
+type Stepper interface {
+	Steps() []*reporting.Step
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/goccy/go-yaml"
 	toprovider "github.com/grezar/revolver/provider/to"
 	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/validation"
 )
 
 const (
@@ -39,6 +40,14 @@ type Spec struct {
 	Output string `yaml:"output"`
 }
 
+// Validate implements toprovider.Validator interface
+func (s *Spec) Validate(keys []string) []*validation.FieldError {
+	if err := secrets.ValidateTemplate(s.Output, keys); err != nil {
+		return []*validation.FieldError{{Field: "output", Message: err.Error()}}
+	}
+	return nil
+}
+
 func (s *Spec) Summary() string {
 	return "output to stdout"
 }
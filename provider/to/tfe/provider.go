@@ -4,11 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/goccy/go-yaml"
+	"github.com/grezar/revolver/plan"
 	toprovider "github.com/grezar/revolver/provider/to"
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/retry"
 	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/validation"
 	tfe "github.com/hashicorp/go-tfe"
 	"go.uber.org/ratelimit"
 )
@@ -54,11 +60,19 @@ func (t *Tfe) UnmarshalSpec(bytes []byte) (toprovider.Operator, error) {
 
 // toprovider.Operator
 type Spec struct {
-	Organization string `yaml:"organization" validate:"required"`
-	Workspace    string `yaml:"workspace" validate:"required"`
-	Secrets      []Secret
+	Organization string   `yaml:"organization" validate:"required"`
+	Workspace    string   `yaml:"workspace" validate:"required"`
+	Secrets      []Secret `validate:"dive"`
 	Client       *tfe.Client
 	RateLimit    ratelimit.Limiter
+	// snapshot holds the variables overwritten by Do keyed by the secret
+	// name. A nil value means the variable didn't exist.
+	snapshot map[string]*tfe.Variable
+	// changes holds the changes made by the last Do.
+	changes []*plan.Change
+	// steps holds the calls to the API made by the last Do.
+	steps   []*reporting.Step
+	retrier retry.Retrier
 }
 
 type Secret struct {
@@ -68,6 +82,41 @@ type Secret struct {
 	Sensitive bool   `yaml:"sensitive"`
 }
 
+// SetRateLimiter implements toprovider.RateLimited interface
+func (s *Spec) SetRateLimiter(l ratelimit.Limiter) {
+	s.RateLimit = l
+}
+
+// SetRetryPolicy implements toprovider.Retrier interface
+func (s *Spec) SetRetryPolicy(p retry.Policy) {
+	s.retrier.SetRetryPolicy(p)
+}
+
+// Retries implements toprovider.Retrier interface
+func (s *Spec) Retries() int {
+	return s.retrier.Retries()
+}
+
+// Validate implements toprovider.Validator interface
+func (s *Spec) Validate(keys []string) []*validation.FieldError {
+	var errs []*validation.FieldError
+	for i, secret := range s.Secrets {
+		if categoryTypes[secret.Category] == "" {
+			errs = append(errs, &validation.FieldError{
+				Field:   fmt.Sprintf("secrets[%d].category", i),
+				Message: fmt.Sprintf("unsupported category %q. Only \"env\" or \"terraform\" are available", secret.Category),
+			})
+		}
+		if err := secrets.ValidateTemplate(secret.Value, keys); err != nil {
+			errs = append(errs, &validation.FieldError{
+				Field:   fmt.Sprintf("secrets[%d].value", i),
+				Message: err.Error(),
+			})
+		}
+	}
+	return errs
+}
+
 func (s *Spec) Summary() string {
 	return fmt.Sprintf("organization: %s, workspace: %s", s.Organization, s.Workspace)
 }
@@ -79,6 +128,10 @@ func (s *Spec) buildClient() (*tfe.Client, error) {
 
 	config := &tfe.Config{
 		Token: os.Getenv(revolverTfeTokenKey),
+		// Requests rejected by rate limiting, and the idempotent ones which
+		// failed with server errors, are retried with the retry policy of the
+		// provider.
+		HTTPClient: &http.Client{Transport: &retry.Transport{Retrier: &s.retrier}},
 	}
 
 	client, err := tfe.NewClient(config)
@@ -90,43 +143,24 @@ func (s *Spec) buildClient() (*tfe.Client, error) {
 
 // Do implements toprovider.Operator interface
 func (s *Spec) Do(ctx context.Context, dryRun bool) error {
+	s.changes = nil
+	s.steps = nil
+
 	api, err := s.buildClient()
 	if err != nil {
 		return err
 	}
 
-	ws, err := api.Workspaces.List(ctx, s.Organization, tfe.WorkspaceListOptions{
-		Search: tfe.String(s.Workspace),
-	})
+	workspaceID, err := s.findWorkspaceID(ctx, api)
 	if err != nil {
 		return err
 	}
 
-	var workspaceID string
-
-	for _, w := range ws.Items {
-		if w.Name == s.Workspace {
-			workspaceID = w.ID
-		}
-	}
-
-	if workspaceID == "" {
-		return fmt.Errorf("Exactly matching workspace with the name %s was not found", s.Workspace)
-	}
-
-	workspaceVariables, err := listWorkspaceVariables(ctx, api, s.RateLimit, workspaceID)
+	workspaceVariableList, err := s.workspaceVariableList(ctx, api, workspaceID)
 	if err != nil {
 		return err
 	}
 
-	workspaceVariableList := make(map[string]*tfe.Variable)
-
-	for _, wv := range workspaceVariables {
-		for _, item := range wv.Items {
-			workspaceVariableList[item.Key] = item
-		}
-	}
-
 	for _, secret := range s.Secrets {
 		categoryType := categoryTypes[secret.Category]
 		if categoryType == "" {
@@ -140,26 +174,46 @@ func (s *Spec) Do(ctx context.Context, dryRun bool) error {
 
 		wv := workspaceVariableList[secret.Name]
 		if wv != nil && (categoryType == wv.Category) {
+			change := &plan.Change{
+				Action:   plan.Update,
+				Resource: s.variableResource(secret, categoryType),
+				Value:    secretValue,
+			}
+			s.changes = append(s.changes, change)
 			if !dryRun {
 				s.RateLimit.Take()
-				_, err := api.Variables.Update(ctx, workspaceID, wv.ID, tfe.VariableUpdateOptions{
-					Key:       tfe.String(secret.Name),
-					Value:     tfe.String(secretValue),
-					Sensitive: tfe.Bool(secret.Sensitive),
+				step, err := reporting.TimeStep(change.String(), func() error {
+					_, err := api.Variables.Update(ctx, workspaceID, wv.ID, tfe.VariableUpdateOptions{
+						Key:       tfe.String(secret.Name),
+						Value:     tfe.String(secretValue),
+						Sensitive: tfe.Bool(secret.Sensitive),
+					})
+					return err
 				})
+				s.steps = append(s.steps, step)
 				if err != nil {
 					return err
 				}
 			}
 		} else {
+			change := &plan.Change{
+				Action:   plan.Create,
+				Resource: s.variableResource(secret, categoryType),
+				Value:    secretValue,
+			}
+			s.changes = append(s.changes, change)
 			if !dryRun {
 				s.RateLimit.Take()
-				_, err := api.Variables.Create(ctx, workspaceID, tfe.VariableCreateOptions{
-					Key:       tfe.String(secret.Name),
-					Value:     tfe.String(secretValue),
-					Category:  tfe.Category(categoryType),
-					Sensitive: tfe.Bool(secret.Sensitive),
+				step, err := reporting.TimeStep(change.String(), func() error {
+					_, err := api.Variables.Create(ctx, workspaceID, tfe.VariableCreateOptions{
+						Key:       tfe.String(secret.Name),
+						Value:     tfe.String(secretValue),
+						Category:  tfe.Category(categoryType),
+						Sensitive: tfe.Bool(secret.Sensitive),
+					})
+					return err
 				})
+				s.steps = append(s.steps, step)
 				if err != nil {
 					return err
 				}
@@ -170,6 +224,204 @@ func (s *Spec) Do(ctx context.Context, dryRun bool) error {
 	return nil
 }
 
+// Plan implements toprovider.Planner interface
+func (s *Spec) Plan(ctx context.Context) (*plan.Step, error) {
+	api, err := s.buildClient()
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceID, err := s.findWorkspaceID(ctx, api)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceVariableList, err := s.workspaceVariableList(ctx, api, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	step := &plan.Step{}
+	for _, secret := range s.Secrets {
+		categoryType := categoryTypes[secret.Category]
+		if categoryType == "" {
+			return nil, errors.New("Unsupported category specified. Only \"env\" or \"terraform\" are available")
+		}
+
+		action := plan.Create
+		if wv := workspaceVariableList[secret.Name]; wv != nil && categoryType == wv.Category {
+			action = plan.Update
+		}
+		step.Changes = append(step.Changes, &plan.Change{
+			Action:   action,
+			Resource: s.variableResource(secret, categoryType),
+		})
+	}
+	return step, nil
+}
+
+// Changes implements toprovider.Differ interface
+func (s *Spec) Changes() []*plan.Change {
+	return s.changes
+}
+
+// Steps implements toprovider.Stepper interface
+func (s *Spec) Steps() []*reporting.Step {
+	return s.steps
+}
+
+func (s *Spec) variableResource(secret Secret, categoryType tfe.CategoryType) string {
+	resource := fmt.Sprintf("%s variable %s in %s/%s", categoryType, secret.Name, s.Organization, s.Workspace)
+	if secret.Sensitive {
+		resource = "sensitive " + resource
+	}
+	return resource
+}
+
+// Snapshot implements toprovider.Restorer interface. The value of a sensitive
+// variable cannot be read from the API, so Snapshot fails if one of the
+// variables exists and is sensitive, before anything is changed.
+func (s *Spec) Snapshot(ctx context.Context) error {
+	api, err := s.buildClient()
+	if err != nil {
+		return err
+	}
+
+	workspaceID, err := s.findWorkspaceID(ctx, api)
+	if err != nil {
+		return err
+	}
+
+	workspaceVariableList, err := s.workspaceVariableList(ctx, api, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	snapshot := make(map[string]*tfe.Variable)
+	var unrestorable []string
+	for _, secret := range s.Secrets {
+		wv := workspaceVariableList[secret.Name]
+		if wv == nil || categoryTypes[secret.Category] != wv.Category {
+			snapshot[secret.Name] = nil
+			continue
+		}
+		if wv.Sensitive {
+			unrestorable = append(unrestorable, secret.Name)
+		}
+		snapshot[secret.Name] = wv
+	}
+	if len(unrestorable) > 0 {
+		return fmt.Errorf("sensitive variables cannot be restored: %s", strings.Join(unrestorable, ", "))
+	}
+	s.snapshot = snapshot
+	return nil
+}
+
+// Restore implements toprovider.Restorer interface. Variables created by Do
+// are deleted and updated ones are put back to their previous value. The
+// value of a sensitive variable cannot be read from the API, so it cannot be
+// restored.
+func (s *Spec) Restore(ctx context.Context) error {
+	if s.snapshot == nil {
+		return errors.New("no snapshot to restore")
+	}
+
+	api, err := s.buildClient()
+	if err != nil {
+		return err
+	}
+
+	workspaceID, err := s.findWorkspaceID(ctx, api)
+	if err != nil {
+		return err
+	}
+
+	workspaceVariableList, err := s.workspaceVariableList(ctx, api, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	var unrestorable []string
+	for _, secret := range s.Secrets {
+		prev, ok := s.snapshot[secret.Name]
+		if !ok {
+			continue
+		}
+		wv := workspaceVariableList[secret.Name]
+		if wv == nil || wv.Category != categoryTypes[secret.Category] {
+			// Do didn't reach this variable.
+			continue
+		}
+
+		if prev == nil {
+			s.RateLimit.Take()
+			if err := api.Variables.Delete(ctx, workspaceID, wv.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if prev.Sensitive {
+			unrestorable = append(unrestorable, secret.Name)
+			continue
+		}
+
+		s.RateLimit.Take()
+		_, err := api.Variables.Update(ctx, workspaceID, wv.ID, tfe.VariableUpdateOptions{
+			Key:       tfe.String(prev.Key),
+			Value:     tfe.String(prev.Value),
+			Sensitive: tfe.Bool(prev.Sensitive),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(unrestorable) > 0 {
+		return fmt.Errorf("sensitive variables cannot be restored: %s", strings.Join(unrestorable, ", "))
+	}
+	return nil
+}
+
+func (s *Spec) findWorkspaceID(ctx context.Context, api *tfe.Client) (string, error) {
+	s.RateLimit.Take()
+	ws, err := api.Workspaces.List(ctx, s.Organization, tfe.WorkspaceListOptions{
+		Search: tfe.String(s.Workspace),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var workspaceID string
+
+	for _, w := range ws.Items {
+		if w.Name == s.Workspace {
+			workspaceID = w.ID
+		}
+	}
+
+	if workspaceID == "" {
+		return "", fmt.Errorf("Exactly matching workspace with the name %s was not found", s.Workspace)
+	}
+	return workspaceID, nil
+}
+
+func (s *Spec) workspaceVariableList(ctx context.Context, api *tfe.Client, workspaceID string) (map[string]*tfe.Variable, error) {
+	workspaceVariables, err := listWorkspaceVariables(ctx, api, s.RateLimit, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceVariableList := make(map[string]*tfe.Variable)
+
+	for _, wv := range workspaceVariables {
+		for _, item := range wv.Items {
+			workspaceVariableList[item.Key] = item
+		}
+	}
+	return workspaceVariableList, nil
+}
+
 func listWorkspaceVariables(ctx context.Context, api *tfe.Client, ratelimit ratelimit.Limiter, workspaceID string) ([]*tfe.VariableList, error) {
 	var workspaceVariables []*tfe.VariableList
 
@@ -2,9 +2,13 @@ package tfe
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/grezar/revolver/plan"
+	"github.com/grezar/revolver/secrets"
 	tfe "github.com/hashicorp/go-tfe"
 	"github.com/hashicorp/go-tfe/mocks"
 	"go.uber.org/ratelimit"
@@ -469,3 +473,277 @@ func TestSpec_Do(t *testing.T) {
 		})
 	}
 }
+
+func TestSpec_Steps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	workspaceID := "ws-1"
+
+	variables := mocks.NewMockVariables(ctrl)
+	variables.EXPECT().List(ctx, workspaceID, tfe.VariableListOptions{}).Return(&tfe.VariableList{
+		Pagination: &tfe.Pagination{},
+		Items: []*tfe.Variable{
+			{ID: "var-1", Key: "SECRET1", Category: categoryEnv},
+		},
+	}, nil)
+	variables.EXPECT().Update(ctx, workspaceID, "var-1", gomock.Any()).Return(&tfe.Variable{}, nil)
+	variables.EXPECT().Create(ctx, workspaceID, gomock.Any()).Return(nil, errors.New("forbidden"))
+
+	s := &Spec{
+		Organization: "org1",
+		Workspace:    "ws1",
+		Secrets: []Secret{
+			{Name: "SECRET1", Value: "111", Category: "env"},
+			{Name: "SECRET2", Value: "222", Category: "terraform"},
+		},
+		Client: &tfe.Client{
+			Variables:  variables,
+			Workspaces: defaultWorkspaces(t, ctrl, "org1", "ws1", workspaceID),
+		},
+		RateLimit: ratelimit.New(apiRateLimit),
+	}
+	if err := s.Do(ctx, false); err == nil {
+		t.Fatal("Spec.Do() succeeded unexpectedly")
+	}
+
+	steps := s.Steps()
+	if len(steps) != 2 {
+		t.Fatalf("len(steps) = %d, want 2", len(steps))
+	}
+	if got, want := steps[0].Name, "update env variable SECRET1 in org1/ws1"; got != want || steps[0].Err != nil {
+		t.Errorf("steps[0] = {%s, %v}, want {%s, <nil>}", got, steps[0].Err, want)
+	}
+	if got, want := steps[1].Name, "create terraform variable SECRET2 in org1/ws1"; got != want || steps[1].Err == nil {
+		t.Errorf("steps[1] = {%s, %v}, want {%s, forbidden}", got, steps[1].Err, want)
+	}
+}
+
+func TestSpec_Plan(t *testing.T) {
+	ctx := context.Background()
+	workspaceID := "ws-1"
+
+	ctrl := gomock.NewController(t)
+	mockTfeAPI := &tfe.Client{}
+	mockTfeAPI.Workspaces = defaultWorkspaces(t, ctrl, "org1", "ws1", workspaceID)
+	mockTfeAPI.Variables = mocks.NewMockVariables(ctrl)
+	mockTfeAPI.Variables.(*mocks.MockVariables).EXPECT().
+		List(ctx, workspaceID, tfe.VariableListOptions{}).
+		Return(&tfe.VariableList{
+			Pagination: &tfe.Pagination{},
+			Items: []*tfe.Variable{
+				{ID: "var-1", Key: "UPDATED", Category: categoryEnv},
+				{ID: "var-2", Key: "OTHER_CATEGORY", Category: categoryTerraform},
+			},
+		}, nil)
+
+	s := &Spec{
+		Organization: "org1",
+		Workspace:    "ws1",
+		Secrets: []Secret{
+			{Name: "UPDATED", Value: "{{ .Key }}", Category: "env"},
+			{Name: "OTHER_CATEGORY", Value: "{{ .Key }}", Category: "env"},
+		},
+		Client:    mockTfeAPI,
+		RateLimit: ratelimit.New(apiRateLimit),
+	}
+
+	got, err := s.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Spec.Plan() error = %v", err)
+	}
+	want := &plan.Step{
+		Changes: []*plan.Change{
+			{Action: plan.Update, Resource: "env variable UPDATED in org1/ws1"},
+			{Action: plan.Create, Resource: "env variable OTHER_CATEGORY in org1/ws1"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Spec.Plan() = %v, want %v", got, want)
+	}
+}
+
+func TestSpec_Changes(t *testing.T) {
+	ctx := secrets.WithSecrets(context.Background(), secrets.Secrets{"Key": "value"})
+	workspaceID := "ws-1"
+
+	ctrl := gomock.NewController(t)
+	mockTfeAPI := &tfe.Client{}
+	mockTfeAPI.Workspaces = mocks.NewMockWorkspaces(ctrl)
+	mockTfeAPI.Workspaces.(*mocks.MockWorkspaces).EXPECT().
+		List(ctx, "org1", tfe.WorkspaceListOptions{
+			Search: tfe.String("ws1"),
+		}).
+		Return(&tfe.WorkspaceList{
+			Items: []*tfe.Workspace{
+				{
+					ID:   workspaceID,
+					Name: "ws1",
+				},
+			},
+		}, nil)
+	mockTfeAPI.Variables = mocks.NewMockVariables(ctrl)
+	mockTfeAPI.Variables.(*mocks.MockVariables).EXPECT().
+		List(ctx, workspaceID, tfe.VariableListOptions{}).
+		Return(&tfe.VariableList{
+			Pagination: &tfe.Pagination{},
+			Items: []*tfe.Variable{
+				{ID: "var-1", Key: "UPDATED", Category: categoryEnv, Sensitive: true},
+			},
+		}, nil)
+
+	s := &Spec{
+		Organization: "org1",
+		Workspace:    "ws1",
+		Secrets: []Secret{
+			{Name: "UPDATED", Value: "{{ .Key }}", Category: "env", Sensitive: true},
+			{Name: "CREATED", Value: "{{ .Key }}", Category: "terraform"},
+		},
+		Client:    mockTfeAPI,
+		RateLimit: ratelimit.New(apiRateLimit),
+	}
+
+	if err := s.Do(ctx, true); err != nil {
+		t.Fatalf("Spec.Do() error = %v", err)
+	}
+	want := []*plan.Change{
+		{Action: plan.Update, Resource: "sensitive env variable UPDATED in org1/ws1", Value: "value"},
+		{Action: plan.Create, Resource: "terraform variable CREATED in org1/ws1", Value: "value"},
+	}
+	if got := s.Changes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Spec.Changes() = %v, want %v", got, want)
+	}
+}
+
+func TestSpec_Restore(t *testing.T) {
+	ctx := context.Background()
+	workspaceID := "ws-1"
+	secrets := []Secret{
+		{
+			Name:     "CREATED",
+			Value:    "NEWVALUE",
+			Category: "env",
+		},
+		{
+			Name:     "UPDATED",
+			Value:    "NEWVALUE",
+			Category: "env",
+		},
+		{
+			Name:      "SENSITIVE",
+			Value:     "NEWVALUE",
+			Category:  "env",
+			Sensitive: true,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	mockTfeAPI := &tfe.Client{}
+	mockTfeAPI.Workspaces = mocks.NewMockWorkspaces(ctrl)
+	mockTfeAPI.Workspaces.(*mocks.MockWorkspaces).EXPECT().
+		List(ctx, "org1", tfe.WorkspaceListOptions{
+			Search: tfe.String("ws1"),
+		}).
+		Return(&tfe.WorkspaceList{
+			Items: []*tfe.Workspace{
+				{
+					ID:   workspaceID,
+					Name: "ws1",
+				},
+			},
+		}, nil).
+		Times(2)
+
+	variables := mocks.NewMockVariables(ctrl)
+	mockTfeAPI.Variables = variables
+	gomock.InOrder(
+		// Snapshot
+		variables.EXPECT().List(ctx, workspaceID, tfe.VariableListOptions{}).Return(&tfe.VariableList{
+			Pagination: &tfe.Pagination{},
+			Items: []*tfe.Variable{
+				{ID: "var-2", Key: "UPDATED", Value: "OLDVALUE", Category: categoryEnv},
+			},
+		}, nil),
+		// Restore
+		variables.EXPECT().List(ctx, workspaceID, tfe.VariableListOptions{}).Return(&tfe.VariableList{
+			Pagination: &tfe.Pagination{},
+			Items: []*tfe.Variable{
+				{ID: "var-1", Key: "CREATED", Value: "NEWVALUE", Category: categoryEnv},
+				{ID: "var-2", Key: "UPDATED", Value: "NEWVALUE", Category: categoryEnv},
+				{ID: "var-3", Key: "SENSITIVE", Category: categoryEnv, Sensitive: true},
+			},
+		}, nil),
+		variables.EXPECT().Delete(ctx, workspaceID, "var-1").Return(nil),
+		variables.EXPECT().Update(ctx, workspaceID, "var-2", tfe.VariableUpdateOptions{
+			Key:       tfe.String("UPDATED"),
+			Value:     tfe.String("OLDVALUE"),
+			Sensitive: tfe.Bool(false),
+		}).Return(&tfe.Variable{}, nil),
+		variables.EXPECT().Delete(ctx, workspaceID, "var-3").Return(nil),
+	)
+
+	s := &Spec{
+		Organization: "org1",
+		Workspace:    "ws1",
+		Secrets:      secrets,
+		Client:       mockTfeAPI,
+		RateLimit:    ratelimit.New(apiRateLimit),
+	}
+
+	if err := s.Snapshot(ctx); err != nil {
+		t.Fatalf("Spec.Snapshot() error = %v", err)
+	}
+	if err := s.Restore(ctx); err != nil {
+		t.Errorf("Spec.Restore() error = %v", err)
+	}
+}
+
+func TestSpec_Snapshot_Sensitive(t *testing.T) {
+	ctx := context.Background()
+	workspaceID := "ws-1"
+
+	ctrl := gomock.NewController(t)
+	mockTfeAPI := &tfe.Client{}
+	mockTfeAPI.Workspaces = mocks.NewMockWorkspaces(ctrl)
+	mockTfeAPI.Workspaces.(*mocks.MockWorkspaces).EXPECT().
+		List(ctx, "org1", tfe.WorkspaceListOptions{
+			Search: tfe.String("ws1"),
+		}).
+		Return(&tfe.WorkspaceList{
+			Items: []*tfe.Workspace{
+				{
+					ID:   workspaceID,
+					Name: "ws1",
+				},
+			},
+		}, nil)
+
+	variables := mocks.NewMockVariables(ctrl)
+	mockTfeAPI.Variables = variables
+	variables.EXPECT().List(ctx, workspaceID, tfe.VariableListOptions{}).Return(&tfe.VariableList{
+		Pagination: &tfe.Pagination{},
+		Items: []*tfe.Variable{
+			{ID: "var-1", Key: "SENSITIVE", Category: categoryEnv, Sensitive: true},
+		},
+	}, nil)
+
+	s := &Spec{
+		Organization: "org1",
+		Workspace:    "ws1",
+		Secrets: []Secret{
+			{
+				Name:      "SENSITIVE",
+				Value:     "NEWVALUE",
+				Category:  "env",
+				Sensitive: true,
+			},
+		},
+		Client:    mockTfeAPI,
+		RateLimit: ratelimit.New(apiRateLimit),
+	}
+
+	// The previous value of the sensitive variable cannot be restored.
+	err := s.Snapshot(ctx)
+	if want := "sensitive variables cannot be restored: SENSITIVE"; err == nil || err.Error() != want {
+		t.Errorf("Spec.Snapshot() error = %v, want %s", err, want)
+	}
+}
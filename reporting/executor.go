@@ -0,0 +1,95 @@
+package reporting
+
+import (
+	"sync"
+)
+
+// DefaultParallelism is the number of reports run at the same time unless
+// WithParallelism is given.
+const DefaultParallelism = 10
+
+// executor runs the parallel sub reports on a fixed number of workers in the
+// order they are started.
+type executor struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*task
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// task is a parallel sub report waiting for a worker.
+type task struct {
+	r       *R
+	f       func(r *R)
+	mu      sync.Mutex
+	claimed bool
+	done    chan struct{}
+}
+
+func newExecutor(parallelism int) *executor {
+	e := &executor{}
+	e.cond = sync.NewCond(&e.mu)
+	e.wg.Add(parallelism)
+	for i := 0; i < parallelism; i++ {
+		go e.work()
+	}
+	return e
+}
+
+func (e *executor) submit(t *task) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.queue = append(e.queue, t)
+	e.cond.Signal()
+}
+
+func (e *executor) work() {
+	defer e.wg.Done()
+	for {
+		e.mu.Lock()
+		for len(e.queue) == 0 && !e.closed {
+			e.cond.Wait()
+		}
+		if len(e.queue) == 0 {
+			e.mu.Unlock()
+			return
+		}
+		t := e.queue[0]
+		e.queue[0] = nil
+		e.queue = e.queue[1:]
+		e.mu.Unlock()
+		t.run()
+	}
+}
+
+// close stops the workers once the queue is empty.
+func (e *executor) close() {
+	e.mu.Lock()
+	e.closed = true
+	e.cond.Broadcast()
+	e.mu.Unlock()
+	e.wg.Wait()
+}
+
+// claim returns true if the task hasn't been run by a worker or the report
+// waiting for it.
+func (t *task) claim() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.claimed {
+		return false
+	}
+	t.claimed = true
+	return true
+}
+
+// run runs the task unless it has been claimed.
+func (t *task) run() {
+	if !t.claim() {
+		return
+	}
+	defer close(t.done)
+	t.r.onWorker = true
+	t.r.run(t.f)
+}
@@ -0,0 +1,129 @@
+package reporting
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRun_Parallelism(t *testing.T) {
+	for _, parallelism := range []int{1, 3} {
+		t.Run(fmt.Sprint(parallelism), func(t *testing.T) {
+			var (
+				mu            sync.Mutex
+				running, peak int
+			)
+			call := func() {
+				mu.Lock()
+				running++
+				if running > peak {
+					peak = running
+				}
+				mu.Unlock()
+				time.Sleep(5 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+			}
+
+			var result *Result
+			Run(func(r *R) {
+				r.Cleanup(func() {
+					result = r.Result()
+				})
+				for i := 0; i < 5; i++ {
+					r.Go(fmt.Sprintf("rotation %d", i), func(r *R) {
+						call()
+						// The sub reports of a report waiting for them
+						// don't need another worker.
+						for j := 0; j < 3; j++ {
+							r.Go(fmt.Sprintf("to %d", j), func(r *R) {
+								call()
+								r.Success()
+							})
+						}
+						r.Wait()
+					})
+				}
+			}, WithParallelism(parallelism), WithReport(&bytes.Buffer{}, JSON))
+
+			if peak > parallelism {
+				t.Errorf("peak = %d, want at most %d", peak, parallelism)
+			}
+			for i, rn := range result.Children {
+				if want := fmt.Sprintf("rotation %d", i); rn.Name != want {
+					t.Errorf("rotation = %s, want %s", rn.Name, want)
+				}
+				for j, to := range rn.Children {
+					if want := fmt.Sprintf("to %d", j); to.Name != want || to.Status != Success {
+						t.Errorf("sub report = %s %s, want %s %s", to.Name, to.Status, want, Success)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestR_Cleanup_AfterParallel(t *testing.T) {
+	var order []string
+	var mu sync.Mutex
+	add := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, s)
+	}
+	Run(func(r *R) {
+		r.Run("rotation", func(r *R) {
+			r.Cleanup(func() {
+				add("cleanup")
+			})
+			r.Go("to", func(r *R) {
+				time.Sleep(5 * time.Millisecond)
+				add("to")
+			})
+		})
+	}, WithParallelism(2), WithReport(&bytes.Buffer{}, JSON))
+
+	if want := []string{"to", "cleanup"}; fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestR_ResetChildren(t *testing.T) {
+	ok := Run(func(r *R) {
+		r.Go("failed", func(r *R) {
+			r.Run("from", func(r *R) {
+				r.Fail(fmt.Errorf("fake error"))
+			})
+		})
+		r.Go("reset", func(r *R) {
+			r.Run("to", func(r *R) {
+				r.Fail(fmt.Errorf("dry run error"))
+			})
+			r.ResetChildren()
+			r.Run("to", func(r *R) {
+				r.SetStatus(Updated)
+			})
+		})
+	}, WithParallelism(2), WithReport(&bytes.Buffer{}, JSON))
+	if ok {
+		t.Fatal("the run succeeded, want the failure of the other report to be kept")
+	}
+
+	ok = Run(func(r *R) {
+		r.Go("reset", func(r *R) {
+			r.Run("to", func(r *R) {
+				r.Fail(fmt.Errorf("dry run error"))
+			})
+			r.ResetChildren()
+			r.Run("to", func(r *R) {
+				r.SetStatus(Updated)
+			})
+		})
+	}, WithReport(&bytes.Buffer{}, JSON))
+	if !ok {
+		t.Error("the run failed after the failed sub report was reset")
+	}
+}
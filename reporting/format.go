@@ -0,0 +1,329 @@
+package reporting
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/grezar/revolver/secrets"
+)
+
+// Format is a format of reports.
+type Format string
+
+// Formats of reports.
+const (
+	Table    Format = "table"
+	JSON     Format = "json"
+	JUnit    Format = "junit"
+	Markdown Format = "markdown"
+)
+
+// Formats are the supported formats of reports.
+var Formats = []Format{Table, JSON, JUnit, Markdown}
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown report format %q. Only %s are available", s, strings.Join(names, ", "))
+}
+
+// Report writes the report of the rotations to w in the format. It should be
+// called once the report has completed.
+func (r *R) Report(w io.Writer, format Format) error {
+	switch format {
+	case Table:
+		r.renderTable(w)
+		return nil
+	case JSON:
+		return writeJSON(w, r.Result())
+	case JUnit:
+		return writeJUnit(w, r.Result())
+	case Markdown:
+		return writeMarkdown(w, r.Result())
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+type jsonReport struct {
+	DryRun     bool            `json:"dryRun"`
+	Status     string          `json:"status"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt time.Time       `json:"finishedAt"`
+	Duration   float64         `json:"durationSeconds"`
+	Rotations  []*jsonRotation `json:"rotations"`
+}
+
+type jsonRotation struct {
+	Name       string          `json:"name"`
+	Status     string          `json:"status"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt time.Time       `json:"finishedAt"`
+	Duration   float64         `json:"durationSeconds"`
+	Providers  []*jsonProvider `json:"providers"`
+}
+
+type jsonProvider struct {
+	Name       string        `json:"name"`
+	Status     string        `json:"status"`
+	Summary    string        `json:"summary,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Attempts   int           `json:"attempts"`
+	Changes    []*jsonChange `json:"changes,omitempty"`
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	Duration   float64       `json:"durationSeconds"`
+	// Steps are the steps inside the provider, like updating each variable.
+	Steps []*jsonProvider `json:"steps,omitempty"`
+}
+
+// jsonChange is a change without its value, which is usually a secret.
+type jsonChange struct {
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+}
+
+func writeJSON(w io.Writer, result *Result) error {
+	report := &jsonReport{
+		DryRun:     result.DryRun,
+		Status:     runStatus(result),
+		StartedAt:  result.StartedAt,
+		FinishedAt: result.FinishedAt,
+		Duration:   result.Duration().Seconds(),
+		Rotations:  []*jsonRotation{},
+	}
+	for _, rn := range result.Children {
+		rotation := &jsonRotation{
+			Name:       rn.Name,
+			Status:     rn.Outcome(),
+			StartedAt:  rn.StartedAt,
+			FinishedAt: rn.FinishedAt,
+			Duration:   rn.Duration().Seconds(),
+			Providers:  []*jsonProvider{},
+		}
+		for _, p := range rn.Children {
+			rotation.Providers = append(rotation.Providers, newJSONProvider(p))
+		}
+		report.Rotations = append(report.Rotations, rotation)
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(report)
+}
+
+func newJSONProvider(result *Result) *jsonProvider {
+	p := &jsonProvider{
+		Name:       result.Name,
+		Status:     result.Status,
+		Summary:    result.Summary,
+		Error:      result.Err,
+		Attempts:   result.Attempts,
+		StartedAt:  result.StartedAt,
+		FinishedAt: result.FinishedAt,
+		Duration:   result.Duration().Seconds(),
+	}
+	for _, c := range result.Changes {
+		p.Changes = append(p.Changes, &jsonChange{Action: c.Action, Resource: secrets.Redact(c.Resource)})
+	}
+	for _, child := range result.Children {
+		p.Steps = append(p.Steps, newJSONProvider(child))
+	}
+	return p
+}
+
+// runStatus returns the status of the whole run: ERROR if any rotation failed
+// and SUCCESS otherwise.
+func runStatus(result *Result) string {
+	if result.Status == Error {
+		return Error
+	}
+	return Success
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr,omitempty"`
+	Cases     []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// writeJUnit writes a test suite for each rotation with a test case for each
+// step, so that CI can show the failed steps. A failed step is a failure, and
+// a cancelled one is an error.
+func writeJUnit(w io.Writer, result *Result) error {
+	name := "revolver"
+	if result.DryRun {
+		name = "revolver (dry run)"
+	}
+	suites := &junitTestSuites{
+		Name: name,
+		Time: junitTime(result.Duration()),
+	}
+	for _, rn := range result.Children {
+		suite := &junitTestSuite{
+			Name: rn.Name,
+			Time: junitTime(rn.Duration()),
+		}
+		if !rn.StartedAt.IsZero() {
+			suite.Timestamp = rn.StartedAt.Format("2006-01-02T15:04:05")
+		}
+		for _, p := range rn.Children {
+			addJUnitTestCases(suite, rn.Name, p, "")
+		}
+		suites.Suites = append(suites.Suites, suite)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// addJUnitTestCases adds the test case of the step and its sub steps, which are
+// named after the path of the step, like "To/Tfe / update env variable KEY".
+func addJUnitTestCases(suite *junitTestSuite, className string, step *Result, parent string) {
+	name := step.Name
+	if parent != "" {
+		name = parent + " / " + step.Name
+	}
+	tc := &junitTestCase{
+		Name:      name,
+		ClassName: className,
+		Time:      junitTime(step.Duration()),
+		SystemOut: step.Summary,
+	}
+	switch step.Status {
+	case Error:
+		tc.Failure = &junitMessage{Message: step.Err, Type: Error}
+		suite.Failures++
+	case Cancelled:
+		tc.Error = &junitMessage{Message: step.Err, Type: Cancelled}
+		suite.Errors++
+	case Skip:
+		tc.Skipped = &junitMessage{Message: step.Summary}
+		suite.Skipped++
+	}
+	suite.Cases = append(suite.Cases, tc)
+	suite.Tests++
+
+	for _, child := range step.Children {
+		addJUnitTestCases(suite, className, child, name)
+	}
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeMarkdown writes a table to be posted as a comment of a pull request.
+func writeMarkdown(w io.Writer, result *Result) error {
+	var b strings.Builder
+	title := "Revolver report"
+	if result.DryRun {
+		title += " (dry run)"
+	}
+	fmt.Fprintf(&b, "### %s\n\n", title)
+	if len(result.Children) == 0 {
+		b.WriteString("No rotations were run.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	b.WriteString("| Rotation | Provider | Status | Attempts | Duration | Summary | Error |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, rn := range result.Children {
+		fmt.Fprintf(&b, "| **%s** | | %s | | %s | | |\n", markdownCell(rn.Name), rn.Outcome(), formatDuration(rn.Duration()))
+		for _, p := range rn.Children {
+			writeMarkdownStep(&b, p, 0)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMarkdownStep writes the rows of the step and its sub steps, which are
+// indented by their depth.
+func writeMarkdownStep(b *strings.Builder, step *Result, depth int) {
+	summary := []string{markdownCell(step.Summary)}
+	for _, c := range step.Changes {
+		summary = append(summary, markdownCell(strings.TrimSpace(formatChange(c))))
+	}
+	fmt.Fprintf(b, "| | %s%s | %s | %s | %s | %s | %s |\n",
+		strings.Repeat("&nbsp;&nbsp;", depth), markdownCell(step.Name), step.Status, formatAttempts(step.Attempts),
+		formatDuration(step.Duration()), strings.Join(summary, "<br>"), markdownCell(step.Err))
+	for _, child := range step.Children {
+		writeMarkdownStep(b, child, depth+1)
+	}
+}
+
+// markdownCell escapes s to be put in a cell of a Markdown table.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// formatDuration formats d rounded for humans.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d == 0:
+		return ""
+	case d < time.Millisecond:
+		return "<1ms"
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	default:
+		return d.Round(100 * time.Millisecond).String()
+	}
+}
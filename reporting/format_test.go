@@ -0,0 +1,247 @@
+package reporting
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grezar/revolver/plan"
+)
+
+func testResult() *Result {
+	start := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	at := func(d time.Duration) time.Time {
+		return start.Add(d)
+	}
+	return &Result{
+		Status:     Error,
+		DryRun:     true,
+		StartedAt:  start,
+		FinishedAt: at(3 * time.Second),
+		Children: []*Result{
+			{
+				Name:       "a",
+				Status:     Error,
+				StartedAt:  start,
+				FinishedAt: at(3 * time.Second),
+				Children: []*Result{
+					{Name: "From/AWSIAMUser", Status: Success, Summary: "user: a", Attempts: 1, StartedAt: start, FinishedAt: at(time.Second)},
+					{
+						Name: "To/Tfe", Status: Error, Summary: "org | ws", Err: "failed\nto update", Attempts: 3, StartedAt: at(time.Second), FinishedAt: at(3 * time.Second),
+						Changes: []*plan.Change{{Action: plan.Update, Resource: "env variable KEY in org/ws", Value: "secret"}},
+						Children: []*Result{
+							{Name: "update env variable KEY in org/ws", Status: Error, Err: "failed", StartedAt: at(time.Second), FinishedAt: at(2500 * time.Millisecond)},
+						},
+					},
+					{Name: "Cleanup/AWSIAMUser", Status: Skip, Summary: "skipped"},
+				},
+			},
+			{
+				Name:   "b",
+				Status: Cancelled,
+				Children: []*Result{
+					{Name: "From/Stdin", Status: Cancelled, Err: "context canceled"},
+				},
+			},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, testResult()); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`"dryRun": true`,
+		`"status": "ERROR"`,
+		`"durationSeconds": 3`,
+		`"name": "To/Tfe"`,
+		`"error": "failed\nto update"`,
+		`"attempts": 3`,
+		`"resource": "env variable KEY in org/ws"`,
+		`"status": "CANCELLED"`,
+		`"steps": [`,
+		`"durationSeconds": 1.5`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("the JSON report doesn't contain %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "secret") {
+		t.Errorf("the JSON report contains the value of a change:\n%s", got)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeJUnit(&buf, testResult()); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="revolver (dry run)" tests="5" failures="2" errors="1" skipped="1" time="3.000">
+  <testsuite name="a" tests="4" failures="2" errors="0" skipped="1" time="3.000" timestamp="2022-03-04T05:06:07">
+    <testcase name="From/AWSIAMUser" classname="a" time="1.000">
+      <system-out>user: a</system-out>
+    </testcase>
+    <testcase name="To/Tfe" classname="a" time="2.000">
+      <failure message="failed&#xA;to update" type="ERROR"></failure>
+      <system-out>org | ws</system-out>
+    </testcase>
+    <testcase name="To/Tfe / update env variable KEY in org/ws" classname="a" time="1.500">
+      <failure message="failed" type="ERROR"></failure>
+    </testcase>
+    <testcase name="Cleanup/AWSIAMUser" classname="a" time="0.000">
+      <skipped message="skipped"></skipped>
+      <system-out>skipped</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="b" tests="1" failures="0" errors="1" skipped="0" time="0.000">
+    <testcase name="From/Stdin" classname="b" time="0.000">
+      <error message="context canceled" type="CANCELLED"></error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("writeJUnit() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeMarkdown(&buf, testResult()); err != nil {
+		t.Fatal(err)
+	}
+	want := `### Revolver report (dry run)
+
+| Rotation | Provider | Status | Attempts | Duration | Summary | Error |
+| --- | --- | --- | --- | --- | --- | --- |
+| **a** | | ERROR | | 3s | | |
+| | From/AWSIAMUser | SUCCESS | 1 | 1s | user: a |  |
+| | To/Tfe | ERROR | 3 | 2s | org \| ws<br>update env variable KEY in org/ws = ******** | failed<br>to update |
+| | &nbsp;&nbsp;update env variable KEY in org/ws | ERROR |  | 1.5s |  | failed |
+| | Cleanup/AWSIAMUser | SKIP |  |  | skipped |  |
+| **b** | | CANCELLED | |  | | |
+| | From/Stdin | CANCELLED |  |  |  | context canceled |
+`
+	if got := buf.String(); got != want {
+		t.Errorf("writeMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range Formats {
+		got, err := ParseFormat(string(f))
+		if err != nil || got != f {
+			t.Errorf("ParseFormat(%s) = %s, %v", f, got, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(xml) succeeded unexpectedly")
+	}
+}
+
+func TestChangeStatus(t *testing.T) {
+	create := &plan.Change{Action: plan.Create, Resource: "access key"}
+	update := &plan.Change{Action: plan.Update, Resource: "variable"}
+	tests := []struct {
+		changes []*plan.Change
+		dryRun  bool
+		want    string
+	}{
+		{want: Unchanged},
+		{dryRun: true, want: NoOp},
+		{changes: []*plan.Change{create}, want: Created},
+		{changes: []*plan.Change{create}, dryRun: true, want: WouldCreate},
+		{changes: []*plan.Change{create, update}, want: Updated},
+		{changes: []*plan.Change{update}, dryRun: true, want: WouldUpdate},
+	}
+	for _, tt := range tests {
+		got := ChangeStatus(tt.changes, tt.dryRun)
+		if got != tt.want {
+			t.Errorf("ChangeStatus(%v, %t) = %s, want %s", tt.changes, tt.dryRun, got, tt.want)
+		}
+		if !Succeeded(got) {
+			t.Errorf("Succeeded(%s) = false, want true", got)
+		}
+	}
+	for _, status := range []string{Error, Cancelled, Skip, NotDue} {
+		if Succeeded(status) {
+			t.Errorf("Succeeded(%s) = true, want false", status)
+		}
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	p := &plan.Plan{
+		Version: plan.Version,
+		Rotations: []*plan.Rotation{
+			{
+				Name: "a",
+				Due:  true,
+				Steps: []*plan.Step{
+					{Provider: "From/AWSIAMUser", Planned: true, Changes: []*plan.Change{{Action: plan.Create, Resource: "access key of a"}}},
+					{Provider: "To/Stdout"},
+				},
+			},
+			{Name: "b"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePlan(&buf, p, Markdown); err != nil {
+		t.Fatal(err)
+	}
+	want := `### Revolver plan
+
+| Rotation | Provider | Action | Resource |
+| --- | --- | --- | --- |
+| **a** | | rotate | |
+| | From/AWSIAMUser | create | access key of a |
+| | To/Stdout | unknown | |
+| **b** | | skip | |
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WritePlan() =\n%s\nwant\n%s", got, want)
+	}
+
+	if err := WritePlan(&buf, p, JUnit); err == nil {
+		t.Error("WritePlan() in JUnit succeeded unexpectedly")
+	}
+}
+
+func TestR_Steps(t *testing.T) {
+	start := time.Now()
+	ok, err := TimeStep("create", func() error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := TimeStep("delete", func() error {
+		return errors.New("not found")
+	})
+	if err == nil || err.Error() != "not found" {
+		t.Errorf("TimeStep() error = %v, want not found", err)
+	}
+
+	r := &R{status: Success}
+	r.Steps([]*Step{ok, failed})
+	result := r.Result()
+	if result.Status != Success {
+		t.Errorf("status = %s, want %s since a failed step doesn't fail the report", result.Status, Success)
+	}
+	if len(result.Children) != 2 {
+		t.Fatalf("len(children) = %d, want 2", len(result.Children))
+	}
+	if c := result.Children[0]; c.Name != "create" || c.Status != Success || c.StartedAt.Before(start) || c.FinishedAt.Before(c.StartedAt) {
+		t.Errorf("children[0] = %+v", c)
+	}
+	if c := result.Children[1]; c.Name != "delete" || c.Status != Error || c.Err != "not found" {
+		t.Errorf("children[1] = %+v", c)
+	}
+}
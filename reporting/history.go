@@ -0,0 +1,34 @@
+package reporting
+
+import (
+	"io"
+	"time"
+
+	"github.com/grezar/revolver/state"
+)
+
+// RenderHistory renders the runs of the rotations in the given order, the
+// latest run first.
+func RenderHistory(w io.Writer, rotations []string, history map[string][]*state.Run) {
+	var rows [][]string
+	for _, name := range rotations {
+		runs := history[name]
+		for i := len(runs) - 1; i >= 0; i-- {
+			run := runs[i]
+			rows = append(rows, []string{name, run.StartedAt.Format(time.RFC3339), "", run.Status, "", run.SecretID, ""})
+			for _, p := range run.Providers {
+				rows = append(rows, []string{"", "", p.Name, p.Status, formatAttempts(p.Attempts), p.Summary, p.Error})
+			}
+		}
+	}
+
+	table := newTable(w)
+	table.SetHeader([]string{"ROTATION", "STARTED AT", "PROVIDER", "STATUS", "ATTEMPTS", "SUMMARY", "ERROR"})
+
+	color := colorEnabled(w)
+	for _, row := range rows {
+		appendRow(table, row, 3, color)
+	}
+
+	table.Render()
+}
@@ -0,0 +1,80 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/grezar/revolver/plan"
+)
+
+// WritePlan writes the changes of the plan to w in the format. The plan cannot
+// be written in JUnit, which is only for the results of runs.
+func WritePlan(w io.Writer, p *plan.Plan, format Format) error {
+	switch format {
+	case Table:
+		RenderPlan(w, p)
+		return nil
+	case JSON:
+		return plan.Save(w, p)
+	case Markdown:
+		return writePlanMarkdown(w, p)
+	}
+	return fmt.Errorf("plans cannot be written in the %s format", format)
+}
+
+// RenderPlan renders the changes of the plan.
+func RenderPlan(w io.Writer, p *plan.Plan) {
+	table := newTable(w)
+	table.SetHeader([]string{"ROTATION", "PROVIDER", "ACTION", "RESOURCE"})
+
+	for _, rn := range p.Rotations {
+		action := "rotate"
+		if !rn.Due {
+			action = "skip"
+		}
+		table.Append([]string{rn.Name, "", action, ""})
+		for _, step := range rn.Steps {
+			switch {
+			case !step.Planned:
+				table.Append([]string{"", step.Provider, "unknown", ""})
+			case len(step.Changes) == 0:
+				table.Append([]string{"", step.Provider, "none", ""})
+			}
+			for _, c := range step.Changes {
+				table.Append([]string{"", step.Provider, c.Action, c.Resource})
+			}
+		}
+	}
+
+	table.Render()
+}
+
+// writePlanMarkdown writes the changes of the plan in a table to be posted as
+// a comment of a pull request.
+func writePlanMarkdown(w io.Writer, p *plan.Plan) error {
+	var b strings.Builder
+	b.WriteString("### Revolver plan\n\n")
+	b.WriteString("| Rotation | Provider | Action | Resource |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, rn := range p.Rotations {
+		action := "rotate"
+		if !rn.Due {
+			action = "skip"
+		}
+		fmt.Fprintf(&b, "| **%s** | | %s | |\n", markdownCell(rn.Name), action)
+		for _, step := range rn.Steps {
+			switch {
+			case !step.Planned:
+				fmt.Fprintf(&b, "| | %s | unknown | |\n", markdownCell(step.Provider))
+			case len(step.Changes) == 0:
+				fmt.Fprintf(&b, "| | %s | none | |\n", markdownCell(step.Provider))
+			}
+			for _, c := range step.Changes {
+				fmt.Fprintf(&b, "| | %s | %s | %s |\n", markdownCell(step.Provider), c.Action, markdownCell(c.Resource))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,273 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+	"github.com/olekukonko/tablewriter"
+)
+
+// progress streams the events of the reports while they run.
+type progress interface {
+	start(r *R)
+	finish(r *R)
+	close()
+}
+
+// WithProgress streams the progress of the run to w as each report starts and
+// finishes. A terminal shows a live view of the running steps, and anything
+// else, like the logs of CI, gets a line for each event.
+func WithProgress(w io.Writer) Option {
+	return func(o *options) {
+		if isTerminal(w) {
+			o.progress = &liveProgress{w: w, color: colorEnabled(w), width: terminalWidth()}
+		} else {
+			o.progress = &lineProgress{w: w, color: colorEnabled(w)}
+		}
+	}
+}
+
+// lineProgress writes a line when a report starts and finishes.
+type lineProgress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	color bool
+}
+
+func (p *lineProgress) start(r *R) {
+	result := r.Result()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "%s %s %s\n", result.StartedAt.Format("15:04:05"), colorize("START", p.color), r.path())
+}
+
+func (p *lineProgress) finish(r *R) {
+	result := r.Result()
+	status := progressStatus(r, result)
+	line := fmt.Sprintf("%s %s %s (%s)", result.FinishedAt.Format("15:04:05"), colorize(status, p.color), r.path(), formatDuration(result.Duration()))
+	if result.Err != "" {
+		line += ": " + result.Err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.w, line)
+}
+
+func (p *lineProgress) close() {}
+
+// liveProgress keeps a line at the bottom of a terminal which shows the
+// rotations done and the steps running, and writes a line above it as each
+// rotation finishes.
+type liveProgress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	color   bool
+	width   int
+	running []*R
+	done    int
+	started int
+}
+
+func (p *liveProgress) start(r *R) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if r.isRotation() {
+		p.started++
+	} else {
+		p.running = append(p.running, r)
+	}
+	p.redraw()
+}
+
+func (p *liveProgress) finish(r *R) {
+	var line string
+	if r.isRotation() {
+		result := r.Result()
+		line = fmt.Sprintf("%s %s (%s)", colorize(progressStatus(r, result), p.color), r.name, formatDuration(result.Duration()))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if r.isRotation() {
+		p.done++
+		fmt.Fprintf(p.w, "\r\033[K%s\n", line)
+	} else {
+		for i, running := range p.running {
+			if running == r {
+				p.running = append(p.running[:i], p.running[i+1:]...)
+				break
+			}
+		}
+	}
+	p.redraw()
+}
+
+func (p *liveProgress) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprint(p.w, "\r\033[K")
+}
+
+func (p *liveProgress) redraw() {
+	var names []string
+	for _, r := range p.running {
+		names = append(names, r.path())
+	}
+	line := fmt.Sprintf("[%d/%d] %s", p.done, p.started, strings.Join(names, ", "))
+	// The line is cut so that it doesn't wrap, or it couldn't be cleared.
+	if runes := []rune(line); len(runes) > p.width-1 {
+		line = string(runes[:p.width-4]) + "..."
+	}
+	fmt.Fprintf(p.w, "\r\033[K%s", line)
+}
+
+// progressStatus returns the status of a finished report. The status of a
+// rotation is derived from its steps.
+func progressStatus(r *R, result *Result) string {
+	if r.isRotation() {
+		return result.Outcome()
+	}
+	return result.Status
+}
+
+// isRotation returns true if the report is the one of a rotation, whose sub
+// reports are its steps.
+func (r *R) isRotation() bool {
+	return r.parent != nil && r.parent.parent == nil
+}
+
+// path returns the names of the report and its parents, except for the root.
+func (r *R) path() string {
+	var names []string
+	for p := r; p.parent != nil; p = p.parent {
+		names = append([]string{p.name}, names...)
+	}
+	return strings.Join(names, " > ")
+}
+
+// isTerminal returns true if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// colorEnabled returns true if the statuses written to w should be colored,
+// i.e. w is a terminal and NO_COLOR isn't set.
+func colorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// terminalWidth returns the width of the terminal, which is told by COLUMNS
+// if set.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 10 {
+		return n
+	}
+	return 80
+}
+
+// colorize colors the status as in the table if color is enabled.
+func colorize(status string, color bool) string {
+	colors := statusColors(status)
+	if !color || len(colors) == 0 {
+		return status
+	}
+	codes := make([]string, len(colors))
+	for i, c := range colors {
+		codes[i] = strconv.Itoa(c)
+	}
+	return fmt.Sprintf("\033[%sm%s\033[0m", strings.Join(codes, ";"), status)
+}
+
+// appendRow appends the row to the table with the column at status colored
+// if color is enabled.
+func appendRow(table *tablewriter.Table, row []string, status int, color bool) {
+	if !color {
+		table.Append(row)
+		return
+	}
+	colors := make([]tablewriter.Colors, len(row))
+	colors[status] = statusColors(row[status])
+	table.Rich(row, colors)
+}
+
+// progressQueue passes the events to the progress on its own goroutine, so
+// that writing them doesn't hold up the reports.
+type progressQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	events []progressEvent
+	closed bool
+	done   chan struct{}
+	p      progress
+}
+
+type progressEvent struct {
+	r        *R
+	finished bool
+}
+
+func newProgressQueue(p progress) *progressQueue {
+	q := &progressQueue{done: make(chan struct{}), p: p}
+	q.cond = sync.NewCond(&q.mu)
+	go q.deliver()
+	return q
+}
+
+func (q *progressQueue) start(r *R) {
+	q.push(progressEvent{r: r})
+}
+
+func (q *progressQueue) finish(r *R) {
+	q.push(progressEvent{r: r, finished: true})
+}
+
+func (q *progressQueue) push(e progressEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.events = append(q.events, e)
+	q.cond.Signal()
+}
+
+// close waits for the events to be delivered and closes the progress.
+func (q *progressQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Signal()
+	q.mu.Unlock()
+	<-q.done
+	q.p.close()
+}
+
+func (q *progressQueue) deliver() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		for len(q.events) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		events := q.events
+		q.events = nil
+		q.mu.Unlock()
+		if len(events) == 0 {
+			return
+		}
+		for _, e := range events {
+			if e.finished {
+				q.p.finish(e.r)
+			} else {
+				q.p.start(e.r)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package reporting
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func runRotations(opts ...Option) {
+	Run(func(r *R) {
+		r.Run("Rotation", func(r *R) {
+			r.Run("From/Mock", func(r *R) {
+				r.SetStatus(Created)
+			})
+			r.Run("To/Mock", func(r *R) {
+				r.Fail(errors.New("bad request"))
+			})
+		})
+	}, opts...)
+}
+
+func TestWithProgress_Lines(t *testing.T) {
+	var b bytes.Buffer
+	runRotations(WithReport(&bytes.Buffer{}, JSON), WithProgress(&b))
+
+	// The time and duration of each event vary.
+	re := regexp.MustCompile(`(?m)^\d{2}:\d{2}:\d{2} | \([^)]*\)`)
+	got := re.ReplaceAllString(b.String(), "")
+	want := `START Rotation
+START Rotation > From/Mock
+CREATED Rotation > From/Mock
+START Rotation > To/Mock
+ERROR Rotation > To/Mock: bad request
+ERROR Rotation
+`
+	if got != want {
+		t.Errorf("progress =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLiveProgress(t *testing.T) {
+	var b bytes.Buffer
+	p := &liveProgress{w: &b, width: 80}
+	runRotations(WithReport(&bytes.Buffer{}, JSON), func(o *options) {
+		o.progress = p
+	})
+
+	got := b.String()
+	for _, want := range []string{
+		"\r\033[K[0/1] Rotation > From/Mock",
+		"\r\033[K[0/1] Rotation > To/Mock",
+		"\r\033[KERROR Rotation (",
+		"\r\033[K[1/1] ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("progress = %q, want to contain %q", got, want)
+		}
+	}
+	if !strings.HasSuffix(got, "\r\033[K") {
+		t.Errorf("progress = %q, want the line cleared at the end", got)
+	}
+}
+
+func TestColorize(t *testing.T) {
+	if got := colorize(Error, false); got != Error {
+		t.Errorf("colorize(%s, false) = %q, want %q", Error, got, Error)
+	}
+	if got, want := colorize(Error, true), "\033[90;1;41mERROR\033[0m"; got != want {
+		t.Errorf("colorize(%s, true) = %q, want %q", Error, got, want)
+	}
+	if colorEnabled(&bytes.Buffer{}) {
+		t.Error("colorEnabled() = true for a buffer, want false")
+	}
+}
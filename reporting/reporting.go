@@ -1,10 +1,17 @@
 package reporting
 
 import (
+	"fmt"
+	"io"
+	"log"
 	"os"
-	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/grezar/revolver/plan"
+	"github.com/grezar/revolver/secrets"
 	"github.com/olekukonko/tablewriter"
 )
 
@@ -12,18 +19,123 @@ const (
 	Success = "SUCCESS"
 	Skip    = "SKIP"
 	Error   = "ERROR"
+	// Cancelled is the status of a provider which was interrupted, or not
+	// started since the run had been cancelled.
+	Cancelled = "CANCELLED"
+
+	// Statuses of the providers in dry-run mode. Planned is the status of a
+	// provider which cannot tell the changes it would make.
+	Planned     = "PLANNED"
+	WouldCreate = "WOULD_CREATE"
+	WouldUpdate = "WOULD_UPDATE"
+	NoOp        = "NOOP"
+
+	// Statuses of the providers which made changes, or found nothing to
+	// change.
+	Created   = "CREATED"
+	Updated   = "UPDATED"
+	Unchanged = "UNCHANGED"
+
+	// NotDue is the status of a from provider whose secret hasn't expired.
+	NotDue = "NOT_DUE"
 )
 
-func Run(f func(r *R)) bool {
-	ctx := newReportContext()
-	r := &R{
-		barrier: make(chan bool),
-		done:    make(chan bool),
-		context: ctx,
+// Succeeded returns true if the status is of a provider which succeeded,
+// whether or not it changed anything.
+func Succeeded(status string) bool {
+	switch status {
+	case Success, Planned, WouldCreate, WouldUpdate, NoOp, Created, Updated, Unchanged:
+		return true
+	}
+	return false
+}
+
+// ChangeStatus returns the status of a provider which made the changes, or
+// would make them in dry-run mode: CREATED if it only created resources,
+// UNCHANGED if it made no changes, and UPDATED otherwise.
+func ChangeStatus(changes []*plan.Change, dryRun bool) string {
+	created, updated := Created, Updated
+	if dryRun {
+		created, updated = WouldCreate, WouldUpdate
+	}
+	if len(changes) == 0 {
+		if dryRun {
+			return NoOp
+		}
+		return Unchanged
+	}
+	for _, c := range changes {
+		if c.Action != plan.Create {
+			return updated
+		}
+	}
+	return created
+}
+
+// maskedValue is shown in place of the values of changes, which are usually
+// secrets.
+const maskedValue = "********"
+
+// Option configures Run.
+type Option func(*options)
+
+type options struct {
+	reports     []report
+	progress    progress
+	parallelism int
+}
+
+type report struct {
+	w      io.Writer
+	format Format
+}
+
+// WithReport writes the report to w in the format once the run completes. The
+// report is rendered as a table to stdout if no report is given.
+func WithReport(w io.Writer, format Format) Option {
+	return func(o *options) {
+		o.reports = append(o.reports, report{w: w, format: format})
+	}
+}
+
+// WithParallelism sets the number of reports run at the same time. It is
+// DefaultParallelism if n is not positive.
+func WithParallelism(n int) Option {
+	return func(o *options) {
+		o.parallelism = n
+	}
+}
+
+// Run runs f, which starts the reports, on the calling goroutine and waits for
+// all of them to complete. The parallel sub reports run on a fixed number of
+// workers, and are reported in the order they were started.
+func Run(f func(r *R), opts ...Option) bool {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(o.reports) == 0 {
+		o.reports = []report{{w: os.Stdout, format: Table}}
+	}
+	if o.parallelism <= 0 {
+		o.parallelism = DefaultParallelism
+	}
+
+	exec := newExecutor(o.parallelism)
+	r := &R{exec: exec}
+	if o.progress != nil {
+		r.progress = newProgressQueue(o.progress)
+	}
+	r.run(f)
+	exec.close()
+	if r.progress != nil {
+		r.progress.close()
+	}
+	for _, rp := range o.reports {
+		if err := r.Report(rp.w, rp.format); err != nil {
+			log.Printf("failed to write the %s report: %v", rp.format, err)
+		}
 	}
-	go rRunner(r, f)
-	<-r.done
-	r.Render()
 	return !r.Failed()
 }
 
@@ -35,65 +147,105 @@ type R struct {
 	err        string
 	parent     *R
 	children   []*R
-	sub        []*R
-	isParallel bool
-	context    *reportContext
-	barrier    chan bool
-	done       chan bool
+	tasks      []*task
 	dryRun     bool
+	cleanups   []func()
+	changes    []*plan.Change
+	attempts   int
+	startedAt  time.Time
+	finishedAt time.Time
+	exec       *executor
+	progress   progress
+	// onWorker is true if the report runs on a worker of the executor.
+	onWorker bool
 }
 
+// Run runs f as a sub report named name, and waits for it and its parallel
+// sub reports to complete.
 func (r *R) Run(name string, f func(r *R)) {
-	ctx := newReportContext()
+	r.newChild(name).run(f)
+}
+
+// Go runs f as a sub report named name in parallel with the caller. The sub
+// report is run by the executor once a worker is free, and completes before
+// r does.
+func (r *R) Go(name string, f func(r *R)) {
+	t := &task{r: r.newChild(name), f: f, done: make(chan struct{})}
+	r.mu.Lock()
+	r.tasks = append(r.tasks, t)
+	r.mu.Unlock()
+	r.exec.submit(t)
+}
+
+// Wait waits for the parallel sub reports started so far to complete. A
+// report running on a worker runs the ones which no worker has picked up yet,
+// so that it doesn't hold a worker they need.
+func (r *R) Wait() {
+	r.mu.Lock()
+	tasks := r.tasks
+	r.tasks = nil
+	r.mu.Unlock()
+	if r.onWorker {
+		for _, t := range tasks {
+			t.run()
+		}
+	}
+	for _, t := range tasks {
+		<-t.done
+	}
+}
+
+func (r *R) newChild(name string) *R {
 	child := &R{
-		barrier: make(chan bool),
-		done:    make(chan bool),
-		name:    name,
-		parent:  r,
-		context: ctx,
-	}
-	go rRunner(child, f)
-	<-child.done
+		name:     name,
+		parent:   r,
+		exec:     r.exec,
+		progress: r.progress,
+		onWorker: r.onWorker,
+	}
 	r.appendChild(child)
+	return child
 }
 
-func rRunner(r *R, fn func(r *R)) {
-	defer func() {
-		if len(r.sub) > 0 {
-			// Run parallel sub reports.
-			// Decrease the running count for this report.
-			r.context.release()
-			// Release the parallel sub reports.
-			close(r.barrier)
-			// Wait for sub reports to complete.
-			for _, sub := range r.sub {
-				<-sub.done
-			}
-			if !r.isParallel {
-				// Reacuire the count for sequential reports. See comment in Run.
-				r.context.waitParallel()
-			}
-		} else if r.isParallel {
-			// Only release the count for this report if it was run as a parallel
-			// report. See comment in Run method.
-			r.context.release()
-		}
+// run runs f and the parallel sub reports it started, and then the cleanup
+// functions.
+func (r *R) run(f func(r *R)) {
+	r.mu.Lock()
+	r.startedAt = time.Now()
+	r.mu.Unlock()
+	if r.progress != nil && r.parent != nil {
+		r.progress.start(r)
+	}
+
+	f(r)
+	r.Wait()
 
-		r.done <- true
-	}()
+	r.mu.Lock()
+	r.finishedAt = time.Now()
+	r.mu.Unlock()
+	r.runCleanup()
+	if r.progress != nil && r.parent != nil {
+		r.progress.finish(r)
+	}
+}
 
-	fn(r)
+// Cleanup registers a function to be called when the report and all its sub
+// reports complete. Cleanup functions are called in last added, first called
+// order.
+func (r *R) Cleanup(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cleanups = append(r.cleanups, f)
 }
 
-func (r *R) Parallel() {
-	if r.isParallel {
-		panic("reporter: r.Parallel called multiple times")
+func (r *R) runCleanup() {
+	r.mu.Lock()
+	cleanups := r.cleanups
+	r.cleanups = nil
+	r.mu.Unlock()
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
 	}
-	r.isParallel = true
-	r.parent.sub = append(r.parent.sub, r)
-	r.done <- true     // Release calling report
-	<-r.parent.barrier // Wait for the parent report to complete
-	r.context.waitParallel()
 }
 
 func (r *R) appendChild(child *R) {
@@ -102,57 +254,63 @@ func (r *R) appendChild(child *R) {
 	r.children = append(r.children, child)
 }
 
-type reportContext struct {
-	mu            sync.Mutex
-	running       int
-	startParallel chan bool
-	numWaiting    int
-	maxParallel   int
+func (r *R) Render() {
+	r.renderTable(os.Stdout)
 }
 
-func newReportContext() *reportContext {
-	return &reportContext{
-		startParallel: make(chan bool),
-		maxParallel:   runtime.GOMAXPROCS(0),
-		running:       1,
+func (r *R) renderTable(w io.Writer) {
+	var rows [][]string
+	for _, rotation := range r.Result().Children {
+		rows = append(rows, []string{rotation.Name, "", "", "", formatDuration(rotation.Duration()), "", ""})
+		for _, step := range rotation.Children {
+			rows = appendStepRows(rows, step, 0)
+		}
+	}
+
+	table := newTable(w)
+	table.SetHeader([]string{"ROTATION", "PROVIDER", "STATUS", "ATTEMPTS", "DURATION", "SUMMARY", "ERROR"})
+
+	color := colorEnabled(w)
+	for _, row := range rows {
+		appendRow(table, row, 2, color)
 	}
+
+	table.Render()
 }
 
-func (c *reportContext) waitParallel() {
-	c.mu.Lock()
-	if c.running < c.maxParallel {
-		c.running++
-		c.mu.Unlock()
-		return
+// appendStepRows appends the rows of the step and its sub steps, which are
+// indented by their depth.
+func appendStepRows(rows [][]string, step *Result, depth int) [][]string {
+	name := strings.Repeat("  ", depth) + step.Name
+	rows = append(rows, []string{"", name, step.Status, formatAttempts(step.Attempts), formatDuration(step.Duration()), step.Summary, step.Err})
+	for _, c := range step.Changes {
+		rows = append(rows, []string{"", "", "", "", "", formatChange(c), ""})
+	}
+	for _, child := range step.Children {
+		rows = appendStepRows(rows, child, depth+1)
 	}
-	c.numWaiting++
-	c.mu.Unlock()
-	<-c.startParallel
+	return rows
 }
 
-func (c *reportContext) release() {
-	c.mu.Lock()
-	if c.numWaiting == 0 {
-		c.running--
-		c.mu.Unlock()
-		return
+// formatAttempts formats the number of calls to a provider. It is blank for
+// steps which didn't call the provider.
+func formatAttempts(attempts int) string {
+	if attempts == 0 {
+		return ""
 	}
-	c.numWaiting--
-	c.mu.Unlock()
-	c.startParallel <- true
+	return strconv.Itoa(attempts)
 }
 
-func (r *R) Render() {
-	var rows [][]string
-	for _, rotation := range r.children {
-		rows = append(rows, []string{rotation.name, "", "", "", ""})
-		for _, provider := range rotation.children {
-			rows = append(rows, []string{"", provider.name, provider.status, provider.summary, provider.err})
-		}
+// formatChange formats the change with its value masked.
+func formatChange(c *plan.Change) string {
+	if c.Value == "" {
+		return secrets.Redact(fmt.Sprintf("  %s", c))
 	}
+	return secrets.Redact(fmt.Sprintf("  %s = %s", c, maskedValue))
+}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ROTATION", "PROVIDER", "STATUS", "SUMMARY", "ERROR"})
+func newTable(w io.Writer) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
 	table.SetAutoFormatHeaders(true)
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
@@ -164,55 +322,199 @@ func (r *R) Render() {
 	table.SetTablePadding("\t")
 	table.SetNoWhiteSpace(true)
 	table.SetColWidth(60)
+	return table
+}
 
+func statusColors(status string) tablewriter.Colors {
 	var bgColor int
-	for _, row := range rows {
-		switch row[2] {
-		case "UPDATED":
-			bgColor = tablewriter.BgMagentaColor
-		case "SKIP":
-			bgColor = tablewriter.BgCyanColor
-		case "ERROR":
-			bgColor = tablewriter.BgRedColor
-		case "SUCCESS":
-			bgColor = tablewriter.BgGreenColor
+	switch status {
+	case Created, Updated:
+		bgColor = tablewriter.BgMagentaColor
+	case Planned, WouldCreate, WouldUpdate:
+		bgColor = tablewriter.BgBlueColor
+	case Skip, NoOp, Unchanged, NotDue:
+		bgColor = tablewriter.BgCyanColor
+	case Error:
+		bgColor = tablewriter.BgRedColor
+	case Cancelled:
+		bgColor = tablewriter.BgYellowColor
+	case Success:
+		bgColor = tablewriter.BgGreenColor
+	default:
+		return tablewriter.Colors{}
+	}
+	return tablewriter.Colors{tablewriter.FgHiBlackColor, tablewriter.Bold, bgColor}
+}
+
+// Result is the outcome of a report and its sub reports.
+type Result struct {
+	Name       string
+	Status     string
+	Summary    string
+	Err        string
+	Attempts   int
+	DryRun     bool
+	Changes    []*plan.Change
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Children   []*Result
+}
+
+// Outcome returns the status of a report whose sub reports are the steps of a
+// rotation: ERROR or CANCELLED if any step was, SUCCESS if any step succeeded,
+// and SKIP otherwise, e.g. if the secret was not due.
+func (r *Result) Outcome() string {
+	status := Skip
+	for _, child := range r.Children {
+		if Succeeded(child.Status) {
+			status = Success
 		}
-		table.Rich(row, []tablewriter.Colors{{}, {}, {tablewriter.FgHiBlackColor, tablewriter.Bold, bgColor}, {}})
 	}
+	if r.Status == Error {
+		status = Error
+	}
+	for _, child := range r.Children {
+		if child.Status == Cancelled {
+			status = Cancelled
+		}
+	}
+	return status
+}
 
-	table.Render()
+// Duration returns how long the report took.
+func (r *Result) Duration() time.Duration {
+	if r.StartedAt.IsZero() || r.FinishedAt.IsZero() {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
 }
 
+// Result returns the outcome of the report. It should be called once the
+// report has completed, e.g. in a function registered with Cleanup.
+func (r *R) Result() *Result {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := &Result{
+		Name:       r.name,
+		Status:     r.status,
+		Summary:    r.summary,
+		Err:        r.err,
+		Attempts:   r.attempts,
+		DryRun:     r.dryRun,
+		Changes:    r.changes,
+		StartedAt:  r.startedAt,
+		FinishedAt: r.finishedAt,
+	}
+	for _, child := range r.children {
+		result.Children = append(result.Children, child.Result())
+	}
+	return result
+}
+
+// DryRun marks the report as the one of a dry run.
 func (r *R) DryRun() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.dryRun = true
 }
 
 func (r *R) Summary(summary string) {
+	summary = secrets.Redact(summary)
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.summary = summary
 }
 
+// Changes sets the changes the provider made, or would make in dry-run mode.
+func (r *R) Changes(changes []*plan.Change) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = changes
+}
+
+// AddAttempts adds the number of calls made to the provider, including
+// retries.
+func (r *R) AddAttempts(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts += n
+}
+
 func (r *R) Success() {
-	r.status = Success
+	r.SetStatus(Success)
 }
 
 func (r *R) Skip() {
-	r.status = Skip
+	r.SetStatus(Skip)
+}
+
+// SetStatus sets the status of a provider which succeeded, like CREATED.
+func (r *R) SetStatus(status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
 }
 
 func (r *R) Fail(err error) {
+	r.finish(Error, err)
+}
+
+// Cancel marks the report as cancelled. The parent reports fail as they do
+// with Fail, since the rotation didn't complete.
+func (r *R) Cancel(err error) {
+	r.finish(Cancelled, err)
+}
+
+// finish sets the status of a report which didn't succeed, and fails its
+// parents.
+func (r *R) finish(status string, err error) {
+	// The report fails before its parents, so that ResetChildren of a sibling
+	// never finds the parents failed without a failed sub report.
+	r.mu.Lock()
+	if err != nil {
+		r.err = secrets.Redact(err.Error())
+	}
+	r.status = status
+	r.mu.Unlock()
 	if r.parent != nil {
 		r.parent.Fail(nil)
 	}
-	if err != nil {
-		r.err = err.Error()
-	}
-	r.status = Error
 }
 
 func (r *R) Failed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.status == Error
 }
 
+// ResetChildren removes the sub reports once they have completed, along with
+// the failure they caused to the report and its parents, so that a dry run
+// which failed doesn't fail the run which follows it.
 func (r *R) ResetChildren() {
-	r.children = make([]*R, 0)
+	r.Wait()
+	r.mu.Lock()
+	r.children = nil
+	r.mu.Unlock()
+	for p := r; p != nil; p = p.parent {
+		p.clearFailure()
+	}
+}
+
+// clearFailure clears the failure of a report which failed only because one
+// of its sub reports did, once none of them has failed.
+func (r *R) clearFailure() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status != Error || r.err != "" {
+		return
+	}
+	for _, child := range r.children {
+		child.mu.RLock()
+		status := child.status
+		child.mu.RUnlock()
+		if status == Error || status == Cancelled {
+			return
+		}
+	}
+	r.status = ""
 }
@@ -0,0 +1,47 @@
+package reporting
+
+import (
+	"time"
+
+	"github.com/grezar/revolver/secrets"
+)
+
+// Step is a step inside a provider, like updating a variable, which is shown
+// under the provider in the report.
+type Step struct {
+	Name       string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Err        error
+}
+
+// TimeStep calls f and returns the step named name with its timing and error.
+// The error of f is returned as well.
+func TimeStep(name string, f func() error) (*Step, error) {
+	step := &Step{
+		Name:      name,
+		StartedAt: time.Now(),
+	}
+	step.Err = f()
+	step.FinishedAt = time.Now()
+	return step, step.Err
+}
+
+// Steps adds the steps as the sub reports. A failed step doesn't fail the
+// report, since the provider returns the error and may be retried.
+func (r *R) Steps(steps []*Step) {
+	for _, step := range steps {
+		child := &R{
+			name:       step.Name,
+			status:     Success,
+			parent:     r,
+			startedAt:  step.StartedAt,
+			finishedAt: step.FinishedAt,
+		}
+		if step.Err != nil {
+			child.status = Error
+			child.err = secrets.Redact(step.Err.Error())
+		}
+		r.appendChild(child)
+	}
+}
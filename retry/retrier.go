@@ -0,0 +1,38 @@
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Retrier retries the API requests of a provider with its policy, and counts
+// the retries so that they can be reported. Only the requests which are safe to
+// repeat are to be retried, rather than a whole call to the provider which
+// might have changed something before it failed. The zero value retries with
+// DefaultPolicy.
+type Retrier struct {
+	policy  *Policy
+	retries int64
+}
+
+// SetRetryPolicy sets the policy the requests are retried with.
+func (r *Retrier) SetRetryPolicy(p Policy) {
+	r.policy = &p
+}
+
+// Retries returns the number of requests retried so far.
+func (r *Retrier) Retries() int {
+	return int(atomic.LoadInt64(&r.retries))
+}
+
+// Do calls f, which makes a request safe to repeat, until it succeeds, fails
+// with an error which is not transient, or the attempts run out.
+func (r *Retrier) Do(ctx context.Context, f func() error) error {
+	p := DefaultPolicy
+	if r.policy != nil {
+		p = *r.policy
+	}
+	attempts, err := p.Do(ctx, f)
+	atomic.AddInt64(&r.retries, int64(attempts-1))
+	return err
+}
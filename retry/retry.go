@@ -0,0 +1,111 @@
+// Package retry retries calls to providers which failed with transient errors,
+// like rate limiting and server errors, with exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Policy tells how to retry a call.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. It doubles on each
+	// retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter randomizes the waits between zero and the backoff not to retry
+	// in lockstep with other clients.
+	Jitter bool
+}
+
+// DefaultPolicy is the policy used unless configured otherwise. It is the same
+// as the default of the AWS SDK.
+var DefaultPolicy = Policy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     20 * time.Second,
+	Jitter:         true,
+}
+
+// Do calls f until it succeeds, fails with an error which is not transient, or
+// the attempts run out. It returns the number of attempts and the last error.
+// It stops waiting to retry when ctx is canceled.
+func (p Policy) Do(ctx context.Context, f func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= p.MaxAttempts || !IsTransient(err) {
+			return attempt, err
+		}
+
+		wait := p.backoff(attempt)
+		if d, ok := retryAfter(err); ok {
+			wait = d
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return attempt, err
+		case <-t.C:
+		}
+	}
+}
+
+// backoff returns the wait after the attempt.
+func (p Policy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter && d > 0 {
+		d = time.Duration(rand.Int63n(int64(d)))
+	}
+	return d
+}
+
+// transientError is an error which is worth retrying.
+type transientError struct {
+	err error
+}
+
+// Transient marks err as worth retrying.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// IsTransient reports whether err is worth retrying. Only the client which
+// made the request can tell whether it is safe to repeat, so an error is
+// transient only if the client marked it with Transient. A request which timed
+// out is not retried, since it might have succeeded.
+func IsTransient(err error) bool {
+	var te *transientError
+	return errors.As(err, &te)
+}
+
+// retryAfter returns how long the server asked to wait before retrying, if it
+// did.
+func retryAfter(err error) (time.Duration, bool) {
+	var se *StatusError
+	if errors.As(err, &se) && se.RetryAfter > 0 {
+		return se.RetryAfter, true
+	}
+	return 0, false
+}
@@ -0,0 +1,111 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFake = errors.New("retry test fake error")
+
+func TestPolicy_Do(t *testing.T) {
+	policy := Policy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		Jitter:         true,
+	}
+
+	tests := []struct {
+		name         string
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "Success",
+			errs:         []error{nil},
+			wantAttempts: 1,
+		},
+		{
+			name:         "Success after transient errors",
+			errs:         []error{Transient(errFake), Transient(&StatusError{StatusCode: 503}), nil},
+			wantAttempts: 3,
+		},
+		{
+			name:         "Attempts run out",
+			errs:         []error{Transient(errFake), Transient(errFake), Transient(errFake)},
+			wantAttempts: 3,
+			wantErr:      errFake,
+		},
+		{
+			name:         "Error which is not transient",
+			errs:         []error{Transient(errFake), &StatusError{StatusCode: 400}},
+			wantAttempts: 2,
+			wantErr:      &StatusError{StatusCode: 400},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			attempts, err := policy.Do(context.Background(), func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if (err == nil) != (tt.wantErr == nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicy_Do_RetryAfter(t *testing.T) {
+	policy := Policy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+	start := time.Now()
+	calls := 0
+	_, err := policy.Do(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return Transient(&StatusError{StatusCode: 429, RetryAfter: 50 * time.Millisecond})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("retried after %s, want at least 50ms", elapsed)
+	}
+}
+
+func TestPolicy_Do_Cancelled(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, InitialBackoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts, err := policy.Do(ctx, func() error {
+		cancel()
+		return Transient(errFake)
+	})
+	if attempts != 1 || !errors.Is(err, errFake) {
+		t.Errorf("Do() = %d, %v, want 1, %v", attempts, err, errFake)
+	}
+}
+
+func TestPolicy_backoff(t *testing.T) {
+	policy := Policy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  5 * time.Second,
+		10: 5 * time.Second,
+	} {
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package retry
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is the error of an HTTP request which failed with a status code
+// worth retrying.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is the wait the server asked for with Retry-After.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s (retry after %s)", e.Status, e.RetryAfter)
+	}
+	return e.Status
+}
+
+// Transport retries the requests of an HTTP client which failed with status
+// 429 or 5xx, as long as they are safe to send again, and turns the last of
+// these responses into a StatusError rather than an opaque error of the API
+// client.
+type Transport struct {
+	// Base is the transport which makes the requests. http.DefaultTransport is
+	// used if nil.
+	Base http.RoundTripper
+	// Retrier retries the requests. They are retried with DefaultPolicy if
+	// nil.
+	Retrier *Retrier
+}
+
+// RoundTrip implements http.RoundTripper interface
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	retrier := t.Retrier
+	if retrier == nil {
+		retrier = &Retrier{}
+	}
+
+	var resp *http.Response
+	attempt := 0
+	err := retrier.Do(req.Context(), func() error {
+		attempt++
+		r := req
+		if attempt > 1 {
+			r = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return err
+				}
+				r.Body = body
+			}
+		}
+		res, err := base.RoundTrip(r)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+			resp = res
+			return nil
+		}
+
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		se := &StatusError{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+		}
+		if retryable(req, res.StatusCode) {
+			return Transient(se)
+		}
+		return se
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// retryable reports whether the request which failed with the status can be
+// sent again. A request rejected with 429 wasn't processed, while the one
+// which failed with a server error might have been, so it is sent again only
+// if its method is idempotent. The body must be able to be sent again as well.
+func retryable(req *http.Request, status int) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses Retry-After, which is either seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
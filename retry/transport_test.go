@@ -0,0 +1,149 @@
+package retry
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	// The responses are not retried to check the errors.
+	retrier := &Retrier{}
+	retrier.SetRetryPolicy(Policy{MaxAttempts: 1})
+	client := &http.Client{Transport: &Transport{Retrier: retrier}}
+
+	for _, tt := range []struct {
+		status   int
+		wantErr  bool
+		wantWait time.Duration
+	}{
+		{status: http.StatusOK},
+		{status: http.StatusNotFound},
+		{status: http.StatusTooManyRequests, wantErr: true, wantWait: 2 * time.Second},
+		{status: http.StatusBadGateway, wantErr: true, wantWait: 2 * time.Second},
+	} {
+		status = tt.status
+		resp, err := client.Get(server.URL)
+		if !tt.wantErr {
+			if err != nil {
+				t.Errorf("status %d: err = %v", tt.status, err)
+				continue
+			}
+			resp.Body.Close()
+			continue
+		}
+
+		var se *StatusError
+		if !errors.As(err, &se) {
+			t.Errorf("status %d: err = %v, want StatusError", tt.status, err)
+			continue
+		}
+		if se.StatusCode != tt.status || se.RetryAfter != tt.wantWait {
+			t.Errorf("status %d: StatusError = %+v", tt.status, se)
+		}
+		if !IsTransient(err) {
+			t.Errorf("status %d: not transient", tt.status)
+		}
+	}
+}
+
+func TestTransport_Retry(t *testing.T) {
+	var (
+		statuses []int
+		bodies   []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		status := statuses[0]
+		statuses = statuses[1:]
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		name        string
+		method      string
+		statuses    []int
+		wantStatus  int
+		wantRetries int
+	}{
+		{
+			name:        "An idempotent request is retried after a server error",
+			method:      http.MethodPut,
+			statuses:    []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantStatus:  http.StatusOK,
+			wantRetries: 1,
+		},
+		{
+			name:       "A request which is not idempotent is not retried after a server error",
+			method:     http.MethodPost,
+			statuses:   []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:        "A request rejected by rate limiting is retried",
+			method:      http.MethodPost,
+			statuses:    []int{http.StatusTooManyRequests, http.StatusCreated},
+			wantStatus:  http.StatusCreated,
+			wantRetries: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses, bodies = tt.statuses, nil
+			retrier := &Retrier{}
+			retrier.SetRetryPolicy(Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+			client := &http.Client{Transport: &Transport{Retrier: retrier}}
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader("body"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			var se *StatusError
+			switch {
+			case err == nil:
+				resp.Body.Close()
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+				}
+			case !errors.As(err, &se) || se.StatusCode != tt.wantStatus:
+				t.Errorf("err = %v, want status %d", err, tt.wantStatus)
+			}
+			if got := retrier.Retries(); got != tt.wantRetries {
+				t.Errorf("retries = %d, want %d", got, tt.wantRetries)
+			}
+			for _, b := range bodies {
+				if b != "body" {
+					t.Errorf("body = %q, want the body sent again", b)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for v, want := range map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-1":                            0,
+		"Sat, 01 Jan 2022 00:00:30 GMT": 30 * time.Second,
+		"Fri, 31 Dec 2021 00:00:00 GMT": 0,
+		"soon":                          0,
+	} {
+		if got := parseRetryAfter(v, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", v, got, want)
+		}
+	}
+}
@@ -1,13 +1,25 @@
 package revolver
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/grezar/revolver/notify"
+	"github.com/grezar/revolver/plan"
+	fromprovider "github.com/grezar/revolver/provider/from"
 	_ "github.com/grezar/revolver/provider/from/awsiamuser"
 	_ "github.com/grezar/revolver/provider/from/stdin"
+	toprovider "github.com/grezar/revolver/provider/to"
 	_ "github.com/grezar/revolver/provider/to/awssharedcredentials"
 	_ "github.com/grezar/revolver/provider/to/circleci"
 	_ "github.com/grezar/revolver/provider/to/stdout"
@@ -15,101 +27,933 @@ import (
 	"github.com/grezar/revolver/reporting"
 	"github.com/grezar/revolver/schema"
 	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/state"
+	str2duration "github.com/xhit/go-str2duration/v2"
 	"go.uber.org/ratelimit"
 )
 
-var revolverRateLimit = 5
+// defaultRateLimit is the number of rotations started per second unless
+// configured otherwise.
+const defaultRateLimit = 5
 
 type Runner struct {
-	rotations []*schema.Rotation
-	dryRun    bool
+	rotations     []*schema.Rotation
+	dryRun        bool
+	state         state.Store
+	checkpointKey []byte
+	resume        bool
+	config        string
+	configDigest  string
+	filter        Filter
+	timeout       time.Duration
+	// rateLimit is the number of rotations started per second.
+	rateLimit      int
+	maxConcurrency int
+	// providerSlots limits the concurrent calls to the providers by their
+	// names.
+	providerSlots map[string]chan struct{}
+	notifications []*notify.Sink
+	// redaction holds the secrets issued by a run of serve to stop redacting
+	// them once the run is over. The secrets of rotate are kept until the
+	// process exits.
+	redaction *secrets.Scope
 }
 
-func NewRunner(path string, dryRun bool) (*Runner, error) {
-	f, err := os.Open(path)
+// Option configures optional behaviors of a Runner.
+type Option func(*Runner)
+
+// WithStateStore sets the store used to persist the state of rotations between
+// runs. It is required by staged rotations.
+func WithStateStore(s state.Store) Option {
+	return func(r *Runner) {
+		r.state = s
+	}
+}
+
+// WithCheckpointKey enables checkpointing of rotation runs with the state
+// store. The issued secrets are encrypted with the key, which must be
+// secrets.KeySize bytes long.
+func WithCheckpointKey(key []byte) Option {
+	return func(r *Runner) {
+		r.checkpointKey = key
+	}
+}
+
+// WithResume makes the runner resume the rotations interrupted in a previous
+// run from their checkpoints instead of issuing new secrets.
+func WithResume() Option {
+	return func(r *Runner) {
+		r.resume = true
+	}
+}
+
+// WithTimeout limits how long the whole run may take. Once it has passed, each
+// rotation stops before the next step of a provider.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Runner) {
+		r.timeout = d
+	}
+}
+
+func NewRunner(path string, dryRun bool, opts ...Option) (*Runner, error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	rotations, err := schema.LoadRotations(f)
+	cfg, err := schema.LoadConfig(bytes.NewReader(b))
 	if err != nil {
 		return nil, err
 	}
 
-	return &Runner{
-		rotations: rotations,
-		dryRun:    dryRun,
-	}, nil
+	digest := sha256.Sum256(b)
+	r := &Runner{
+		rotations:      cfg.Rotations,
+		dryRun:         dryRun,
+		config:         path,
+		configDigest:   hex.EncodeToString(digest[:]),
+		rateLimit:      cfg.Settings.RateLimit,
+		maxConcurrency: cfg.Settings.MaxConcurrency,
+		providerSlots:  make(map[string]chan struct{}),
+		notifications:  cfg.Notifications,
+	}
+	// REVOLVER_RATE_LIMIT is still honoured for configurations without
+	// settings.
+	if v, ok := os.LookupEnv("REVOLVER_RATE_LIMIT"); ok && r.rateLimit == 0 {
+		r.rateLimit, err = strconv.Atoi(v)
+		if err != nil || r.rateLimit <= 0 {
+			return nil, fmt.Errorf("invalid REVOLVER_RATE_LIMIT: %s", v)
+		}
+	}
+	for name, ps := range cfg.Settings.Providers {
+		if ps != nil && ps.MaxConcurrency > 0 {
+			r.providerSlots[name] = make(chan struct{}, ps.MaxConcurrency)
+		}
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.rotations, err = r.filter.apply(r.rotations)
+	if err != nil {
+		return nil, err
+	}
+	if r.checkpointKey != nil && len(r.checkpointKey) != secrets.KeySize {
+		return nil, fmt.Errorf("checkpoint key must be %d bytes", secrets.KeySize)
+	}
+	if r.resume && (r.state == nil || r.checkpointKey == nil) {
+		return nil, errors.New("resuming rotations requires a state file and a checkpoint key")
+	}
+	return r, nil
 }
 
 func (r *Runner) Run(rptr *reporting.R) {
-	if v, ok := os.LookupEnv("REVOLVER_RATE_LIMIT"); ok {
-		var err error
-		revolverRateLimit, err = strconv.Atoi(v)
-		if err != nil {
-			panic(err)
-		}
+	r.RunContext(context.Background(), rptr)
+}
+
+// RunContext runs the rotations until ctx is canceled. A rotation which has
+// started stops before the next step of a provider, and the steps which didn't
+// run are reported as cancelled.
+func (r *Runner) RunContext(ctx context.Context, rptr *reporting.R) {
+	if r.dryRun {
+		rptr.DryRun()
+	}
+	if r.redaction != nil {
+		ctx = secrets.WithScope(ctx, r.redaction)
+	}
+	if len(r.notifications) > 0 {
+		// The rotations run in parallel after this function returns.
+		rptr.Cleanup(func() {
+			r.notify(rptr)
+		})
+	}
+	rateLimit := r.rateLimit
+	if rateLimit == 0 {
+		rateLimit = defaultRateLimit
+	}
+	rl := ratelimit.New(rateLimit)
+	var slots chan struct{}
+	if r.maxConcurrency > 0 {
+		slots = make(chan struct{}, r.maxConcurrency)
+	}
+	c := newCompletion(r.rotations)
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		// The rotations run in parallel after this function returns.
+		rptr.Cleanup(cancel)
 	}
-	rl := ratelimit.New(revolverRateLimit)
 
-	for _, rn := range r.rotations {
-		rl.Take()
+	// All rotations are started in parallel, and each of them waits for the
+	// rotations it depends on. They are started after their dependencies so
+	// that a worker never waits for a rotation which no worker has picked up.
+	for _, rn := range dependencyOrder(r.rotations) {
 		rn := rn
-		rptr.Run(rn.Name, func(rptr *reporting.R) {
-			rptr.Parallel()
-			ctx := context.Background()
-			// Always run advance dry-run in order not to rotate the from provider's
-			// resource when the to provider is unavailable.
-			ok := r.run(ctx, rptr, rn, true)
+		rptr.Go(rn.Name, func(rptr *reporting.R) {
+			var skipped bool
+			rptr.Cleanup(func() {
+				c.finish(rn.Name, skipped || rptr.Result().Status == reporting.Error)
+			})
+			dep := c.wait(rn)
+			if ctx.Err() != nil {
+				rptr.Run(fmt.Sprintf("From/%s", rn.From.Provider), func(rptr *reporting.R) {
+					rptr.Summary(rn.From.Spec.Operator.Summary())
+					cancelled(ctx, rptr)
+				})
+				return
+			}
+			if dep != "" {
+				skipped = true
+				rptr.Run(fmt.Sprintf("From/%s", rn.From.Provider), func(rptr *reporting.R) {
+					rptr.Summary(fmt.Sprintf("skipped since %s failed", dep))
+					rptr.Skip()
+				})
+				return
+			}
+			rl.Take()
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() {
+					<-slots
+				}()
+			}
+
+			ctx := ctx
+			if rn.Timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, time.Duration(rn.Timeout))
+				rptr.Cleanup(cancel)
+			}
+			checkpoint, ok := r.checkpoint(rptr, rn)
 			if !ok {
 				return
 			}
+			if checkpoint == nil {
+				// Always run advance dry-run in order not to rotate the from provider's
+				// resource when the to provider is unavailable.
+				if _, ok := r.run(ctx, rptr, rn, true, nil); !ok {
+					return
+				}
+			}
 			if !r.dryRun {
 				rptr.ResetChildren()
-				_ = r.run(ctx, rptr, rn, false)
+				var issued secrets.Secrets
+				if r.state != nil {
+					startedAt := time.Now()
+					rptr.Cleanup(func() {
+						r.record(rn, rptr.Result(), startedAt, issued)
+					})
+				}
+				cp := r.newCheckpointer(rn)
+				rptr.Cleanup(func() {
+					if rptr.Result().Status != reporting.Error {
+						cp.clear()
+					}
+				})
+				if checkpoint != nil {
+					issued = r.resumeRun(ctx, rptr, rn, cp, checkpoint)
+				} else {
+					issued, _ = r.run(ctx, rptr, rn, false, cp)
+				}
+			}
+		})
+	}
+}
+
+// dependencyOrder returns the rotations in the order of the configuration,
+// except that each rotation comes after the rotations it depends on.
+func dependencyOrder(rotations []*schema.Rotation) []*schema.Rotation {
+	byName := make(map[string]*schema.Rotation)
+	for _, rn := range rotations {
+		if _, ok := byName[rn.Name]; !ok {
+			byName[rn.Name] = rn
+		}
+	}
+	var (
+		ordered []*schema.Rotation
+		visited = make(map[*schema.Rotation]bool)
+		visit   func(rn *schema.Rotation)
+	)
+	visit = func(rn *schema.Rotation) {
+		if visited[rn] {
+			return
+		}
+		visited[rn] = true
+		for _, dep := range rn.DependsOn {
+			if d, ok := byName[dep]; ok {
+				visit(d)
+			}
+		}
+		ordered = append(ordered, rn)
+	}
+	for _, rn := range rotations {
+		visit(rn)
+	}
+	return ordered
+}
+
+// completion tracks the rotations which have completed so that the rotations
+// depending on them can start.
+type completion struct {
+	mu     sync.Mutex
+	done   map[string]chan struct{}
+	failed map[string]bool
+}
+
+func newCompletion(rotations []*schema.Rotation) *completion {
+	c := &completion{
+		done:   make(map[string]chan struct{}),
+		failed: make(map[string]bool),
+	}
+	for _, rn := range rotations {
+		c.done[rn.Name] = make(chan struct{})
+	}
+	return c
+}
+
+// finish marks the rotation as completed.
+func (c *completion) finish(name string, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done[name]:
+		// Rotations which nothing depends on may share a name.
+	default:
+		c.failed[name] = failed
+		close(c.done[name])
+	}
+}
+
+// wait waits for the rotations rn depends on to complete, and returns the name
+// of one which failed or was skipped, if any.
+func (c *completion) wait(rn *schema.Rotation) string {
+	for _, dep := range rn.DependsOn {
+		if done, ok := c.done[dep]; ok {
+			<-done
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, dep := range rn.DependsOn {
+		if c.failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+// checkpoint loads the checkpoint left by an interrupted run of the rotation,
+// if any. It returns false if the rotation cannot proceed.
+func (r *Runner) checkpoint(rptr *reporting.R, rn *schema.Rotation) (*state.Checkpoint, bool) {
+	if r.state == nil || r.checkpointKey == nil {
+		return nil, true
+	}
+
+	var checkpoint *state.Checkpoint
+	ok := true
+	rs, err := r.state.Load(rn.Name)
+	if err == nil {
+		checkpoint = rs.Checkpoint
+	}
+	if err != nil || (checkpoint != nil && (!r.resume || r.dryRun)) {
+		rptr.Run(fmt.Sprintf("From/%s", rn.From.Provider), func(rptr *reporting.R) {
+			rptr.Summary(rn.From.Spec.Operator.Summary())
+			switch {
+			case err != nil:
+				rptr.Fail(err)
+				ok = false
+			case !r.resume:
+				rptr.Fail(fmt.Errorf("the run at %s was interrupted. Run with --resume to finish it", checkpoint.CreatedAt.Format(time.RFC3339)))
+				ok = false
+			default:
+				rptr.Summary(fmt.Sprintf("resume the run at %s", checkpoint.CreatedAt.Format(time.RFC3339)))
+				rptr.Success()
 			}
 		})
 	}
+	return checkpoint, ok
 }
 
-func (r *Runner) run(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, dryRun bool) bool {
+// run performs the rotation and returns the issued secrets, if any.
+func (r *Runner) run(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, dryRun bool, cp *checkpointer) (secrets.Secrets, bool) {
+	// Transactional, staged and verified rotations need to know the result of
+	// every destination before the previous secret is cleaned up.
+	sequential := (rn.Transactional || rn.GracePeriod != "" || rn.Verify) && !dryRun
+
+	if !r.retire(ctx, rptr, rn, dryRun) {
+		return nil, false
+	}
+
+	var status string
 	rptr.Run(fmt.Sprintf("From/%s", rn.From.Provider), func(rptr *reporting.R) {
 		rptr.Summary(rn.From.Spec.Operator.Summary())
-		newSecrets, err := rn.From.Spec.Operator.Do(ctx, dryRun)
+		if cancelled(ctx, rptr) {
+			return
+		}
+		var newSecrets secrets.Secrets
+		err := r.callFrom(ctx, rptr, rn, func(ctx context.Context) error {
+			var err error
+			newSecrets, err = rn.From.Spec.Operator.Do(ctx, dryRun)
+			r.redaction.Register(newSecrets)
+			return err
+		})
+		reportFromChanges(rptr, rn)
 		if err != nil {
 			rptr.Fail(err)
 			return
 		}
+		// The new secrets are saved before the previous ones are cleaned up, so
+		// that they aren't lost if the cleanup fails or the run is interrupted.
+		if len(newSecrets) > 0 && !dryRun {
+			cp.start(rn, newSecrets)
+		}
+		// The previous secret must be kept until all destinations have been
+		// updated in a transactional or staged rotation.
+		if c, ok := rn.From.Spec.Operator.(fromprovider.Cleaner); ok && !sequential {
+			// The attempts of the step are the ones of Do.
+			err := r.callFrom(ctx, nil, rn, func(ctx context.Context) error {
+				return c.Cleanup(ctx, dryRun)
+			})
+			reportFromChanges(rptr, rn)
+			if err != nil {
+				rptr.Fail(err)
+				return
+			}
+			if !dryRun {
+				cp.cleanedUp()
+			}
+		}
+		status = fromStatus(rn, dryRun, newSecrets)
+		rptr.SetStatus(status)
 		if len(newSecrets) > 0 {
-			rptr.Success()
 			ctx = secrets.WithSecrets(ctx, newSecrets)
-		} else {
-			if dryRun {
-				rptr.Success()
-			} else {
+		} else if keys := secretKeys(rn); dryRun && len(keys) > 0 {
+			ctx = secrets.WithPlaceholders(ctx, keys)
+		}
+	})
+
+	if sequential {
+		return secrets.GetSecrets(ctx), r.runSequential(ctx, rptr, rn, cp)
+	}
+
+	for i, to := range rn.To {
+		id, to := destinationID(i, to), to
+		rptr.Go(fmt.Sprintf("To/%s", to.Provider), func(rptr *reporting.R) {
+			rptr.Summary(to.Spec.Operator.Summary())
+			if cancelled(ctx, rptr) {
+				return
+			}
+			if len(secrets.GetSecrets(ctx)) == 0 && !dryRun {
+				rptr.Skip()
+				return
+			}
+
+			err := r.callTo(ctx, rptr, rn, to, func(ctx context.Context) error {
+				return to.Spec.Operator.Do(ctx, dryRun)
+			})
+			reportChanges(rptr, to)
+			if err != nil {
+				rptr.Fail(err)
+				return
+			}
+			if !dryRun {
+				cp.done(id)
+			}
+			// The destinations are still called in dry-run mode to check
+			// them, but nothing would change if the secret isn't due.
+			if status == reporting.NotDue {
+				rptr.SetStatus(reporting.NoOp)
+				return
+			}
+			rptr.SetStatus(toStatus(to, dryRun))
+		})
+	}
+	// The advance dry-run completes before the actual run starts.
+	rptr.Wait()
+
+	return secrets.GetSecrets(ctx), true
+}
+
+// runSequential updates the destinations one by one and verifies the new
+// secrets if required. When a destination which is not optional or the
+// verification fails, the destinations updated so far are restored and the new
+// secrets are revoked in a transactional rotation. Otherwise the previous
+// secret is cleaned up, or scheduled to be retired in a staged rotation.
+func (r *Runner) runSequential(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, cp *checkpointer) bool {
+	if len(secrets.GetSecrets(ctx)) == 0 {
+		for _, to := range rn.To {
+			to := to
+			rptr.Run(fmt.Sprintf("To/%s", to.Provider), func(rptr *reporting.R) {
+				rptr.Summary(to.Spec.Operator.Summary())
 				rptr.Skip()
+			})
+		}
+		return true
+	}
+
+	if !r.distribute(ctx, rptr, rn, cp) {
+		return false
+	}
+
+	if rn.GracePeriod != "" {
+		return r.stage(rptr, rn, superseded(rn))
+	}
+
+	if c, ok := rn.From.Spec.Operator.(fromprovider.Cleaner); ok {
+		rptr.Run(fmt.Sprintf("Cleanup/%s", rn.From.Provider), func(rptr *reporting.R) {
+			rptr.Summary(rn.From.Spec.Operator.Summary())
+			if cancelled(ctx, rptr) {
+				return
+			}
+			err := r.callFrom(ctx, rptr, rn, func(ctx context.Context) error {
+				return c.Cleanup(ctx, false)
+			})
+			reportFromChanges(rptr, rn)
+			if err != nil {
+				rptr.Fail(err)
+				return
+			}
+			rptr.Success()
+		})
+	}
+
+	return true
+}
+
+// resumeRun finishes the run interrupted in a previous run with the secrets
+// saved in the checkpoint instead of issuing new ones. The destinations
+// already updated are skipped.
+func (r *Runner) resumeRun(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, cp *checkpointer, checkpoint *state.Checkpoint) secrets.Secrets {
+	var issued secrets.Secrets
+	rptr.Run(fmt.Sprintf("From/%s", rn.From.Provider), func(rptr *reporting.R) {
+		rptr.Summary(fmt.Sprintf("resume the run at %s", checkpoint.CreatedAt.Format(time.RFC3339)))
+		if cancelled(ctx, rptr) {
+			return
+		}
+		s, err := cp.resume(checkpoint)
+		if err != nil {
+			rptr.Fail(err)
+			return
+		}
+		r.redaction.Register(s)
+		issued = s
+		rptr.Success()
+	})
+	if len(issued) == 0 {
+		return nil
+	}
+	ctx = secrets.WithSecrets(ctx, issued)
+
+	if !r.distribute(ctx, rptr, rn, cp) || checkpoint.CleanedUp || len(checkpoint.Superseded) == 0 {
+		return issued
+	}
+
+	if rn.GracePeriod != "" {
+		r.stage(rptr, rn, checkpoint.Superseded)
+		return issued
+	}
+
+	rptr.Run(fmt.Sprintf("Cleanup/%s", rn.From.Provider), func(rptr *reporting.R) {
+		rptr.Summary(rn.From.Spec.Operator.Summary())
+		if cancelled(ctx, rptr) {
+			return
+		}
+		stager, ok := rn.From.Spec.Operator.(fromprovider.Stager)
+		if !ok {
+			rptr.Fail(fmt.Errorf("%s provider doesn't support deleting the previous secrets", rn.From.Provider))
+			return
+		}
+		for _, id := range checkpoint.Superseded {
+			id := id
+			err := r.callFrom(ctx, rptr, rn, func(ctx context.Context) error {
+				return stager.Delete(ctx, false, id)
+			})
+			if err != nil {
+				rptr.Fail(err)
+				return
 			}
 		}
+		rptr.Success()
 	})
+	return issued
+}
+
+// distribute updates the destinations one by one and verifies the new secrets
+// if required. It returns false if a destination which is not optional or the
+// verification fails, in which case a transactional rotation is rolled back.
+func (r *Runner) distribute(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, cp *checkpointer) bool {
+	var (
+		updated []*schema.To
+		failed  bool
+	)
 
-	for _, to := range rn.To {
-		to := to
+	for i, to := range rn.To {
+		id, to := destinationID(i, to), to
 		rptr.Run(fmt.Sprintf("To/%s", to.Provider), func(rptr *reporting.R) {
-			rptr.Parallel()
 			rptr.Summary(to.Spec.Operator.Summary())
-			if len(secrets.GetSecrets(ctx)) == 0 && !dryRun {
+			if failed {
 				rptr.Skip()
 				return
 			}
+			if cp.isDone(id) {
+				rptr.Summary(alreadyUpdated)
+				rptr.Skip()
+				return
+			}
+			// The destinations updated so far are rolled back in a
+			// transactional rotation.
+			if cancelled(ctx, rptr) {
+				failed = true
+				return
+			}
 
-			err := to.Spec.Operator.Do(ctx, dryRun)
+			// Only a transactional rotation is rolled back, and a
+			// destination fails the snapshot if it couldn't be restored.
+			if rs, ok := to.Spec.Operator.(toprovider.Restorer); ok && rn.Transactional {
+				if err := r.callTo(ctx, rptr, rn, to, rs.Snapshot); err != nil {
+					rptr.Fail(err)
+					failed = failed || !to.Optional
+					return
+				}
+			}
+
+			// A failed destination may have been updated partially, so it is
+			// restored as well.
+			updated = append(updated, to)
+			err := r.callTo(ctx, rptr, rn, to, func(ctx context.Context) error {
+				return to.Spec.Operator.Do(ctx, false)
+			})
+			reportChanges(rptr, to)
 			if err != nil {
 				rptr.Fail(err)
+				failed = failed || !to.Optional
 				return
 			}
-			rptr.Success()
+			cp.done(id)
+			rptr.SetStatus(toStatus(to, false))
 		})
 	}
 
+	if !failed && rn.Verify {
+		failed = !r.verify(ctx, rptr, rn)
+	}
+
+	if failed {
+		if rn.Transactional {
+			r.rollback(ctx, rptr, rn, updated)
+			cp.clear()
+		}
+		return false
+	}
 	return true
 }
+
+// reportChanges shows the changes the destination made, or would have made in
+// dry-run mode, and the steps it took to make them.
+func reportChanges(rptr *reporting.R, to *schema.To) {
+	if d, ok := to.Spec.Operator.(toprovider.Differ); ok {
+		rptr.Changes(d.Changes())
+	}
+	if st, ok := to.Spec.Operator.(toprovider.Stepper); ok {
+		rptr.Steps(st.Steps())
+	}
+}
+
+// reportFromChanges shows the changes the from provider made, or would have
+// made in dry-run mode, and the steps it took in the last call.
+func reportFromChanges(rptr *reporting.R, rn *schema.Rotation) {
+	if d, ok := rn.From.Spec.Operator.(fromprovider.Differ); ok {
+		rptr.Changes(d.Changes())
+	}
+	if st, ok := rn.From.Spec.Operator.(fromprovider.Stepper); ok {
+		rptr.Steps(st.Steps())
+	}
+}
+
+// fromStatus returns the status of the from provider which succeeded. A dry
+// run of a provider which cannot tell its changes is only planned.
+func fromStatus(rn *schema.Rotation, dryRun bool, issued secrets.Secrets) string {
+	if !dryRun {
+		if len(issued) == 0 {
+			return reporting.NotDue
+		}
+		return reporting.Created
+	}
+	d, ok := rn.From.Spec.Operator.(fromprovider.Differ)
+	if !ok {
+		return reporting.Planned
+	}
+	changes := d.Changes()
+	if len(changes) == 0 {
+		return reporting.NotDue
+	}
+	return reporting.ChangeStatus(changes, true)
+}
+
+// secretKeys returns the keys of the secrets the from provider of the rotation
+// issues.
+func secretKeys(rn *schema.Rotation) []string {
+	if p := fromprovider.Get(rn.From.Provider); p != nil {
+		return p.SecretKeys()
+	}
+	return nil
+}
+
+// toStatus returns the status of the destination which succeeded. A
+// destination which cannot tell its changes is assumed to be updated.
+func toStatus(to *schema.To, dryRun bool) string {
+	if d, ok := to.Spec.Operator.(toprovider.Differ); ok {
+		return reporting.ChangeStatus(d.Changes(), dryRun)
+	}
+	if dryRun {
+		return reporting.Planned
+	}
+	return reporting.Updated
+}
+
+func (r *Runner) rollback(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, updated []*schema.To) {
+	for i := len(updated) - 1; i >= 0; i-- {
+		to := updated[i]
+		rptr.Run(fmt.Sprintf("Rollback/To/%s", to.Provider), func(rptr *reporting.R) {
+			rptr.Summary(to.Spec.Operator.Summary())
+			rs, ok := to.Spec.Operator.(toprovider.Restorer)
+			if !ok {
+				rptr.Fail(fmt.Errorf("%s provider doesn't support rollback", to.Provider))
+				return
+			}
+			if err := r.callTo(ctx, rptr, rn, to, rs.Restore); err != nil {
+				rptr.Fail(err)
+				return
+			}
+			rptr.Success()
+		})
+	}
+
+	rptr.Run(fmt.Sprintf("Rollback/From/%s", rn.From.Provider), func(rptr *reporting.R) {
+		rptr.Summary(rn.From.Spec.Operator.Summary())
+		rv, ok := rn.From.Spec.Operator.(fromprovider.Revoker)
+		if !ok {
+			rptr.Fail(fmt.Errorf("%s provider doesn't support revoking secrets", rn.From.Provider))
+			return
+		}
+		err := r.callFrom(ctx, rptr, rn, func(ctx context.Context) error {
+			return rv.Revoke(ctx, secrets.GetSecrets(ctx))
+		})
+		if err != nil {
+			rptr.Fail(err)
+			return
+		}
+		rptr.Success()
+	})
+}
+
+func (r *Runner) verify(ctx context.Context, rptr *reporting.R, rn *schema.Rotation) bool {
+	ok := true
+	rptr.Run(fmt.Sprintf("Verify/%s", rn.From.Provider), func(rptr *reporting.R) {
+		rptr.Summary(rn.From.Spec.Operator.Summary())
+		if cancelled(ctx, rptr) {
+			ok = false
+			return
+		}
+		v, isVerifier := rn.From.Spec.Operator.(fromprovider.Verifier)
+		if !isVerifier {
+			rptr.Fail(fmt.Errorf("%s provider doesn't support verification", rn.From.Provider))
+			ok = false
+			return
+		}
+		err := r.callFrom(ctx, rptr, rn, func(ctx context.Context) error {
+			return v.Verify(ctx, secrets.GetSecrets(ctx))
+		})
+		if err != nil {
+			rptr.Fail(err)
+			ok = false
+			return
+		}
+		rptr.Success()
+	})
+	return ok
+}
+
+// retire deactivates the secrets whose grace period has passed and deletes the
+// ones deactivated in a previous run. It returns false if the rotation cannot
+// proceed.
+func (r *Runner) retire(ctx context.Context, rptr *reporting.R, rn *schema.Rotation, dryRun bool) bool {
+	if rn.GracePeriod == "" {
+		return true
+	}
+	stager, isStager := rn.From.Spec.Operator.(fromprovider.Stager)
+
+	ok := true
+	rptr.Run(fmt.Sprintf("Retire/%s", rn.From.Provider), func(rptr *reporting.R) {
+		rptr.Summary(rn.From.Spec.Operator.Summary())
+		fail := func(err error) {
+			rptr.Fail(err)
+			ok = false
+		}
+		if cancelled(ctx, rptr) {
+			ok = false
+			return
+		}
+
+		if r.state == nil {
+			fail(errors.New("staged rotations require a state file"))
+			return
+		}
+		if !isStager {
+			fail(fmt.Errorf("%s provider doesn't support staged rotations", rn.From.Provider))
+			return
+		}
+
+		gracePeriod, err := str2duration.ParseDuration(rn.GracePeriod)
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		rs, err := r.state.Load(rn.Name)
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		var (
+			remaining []*state.RetiringSecret
+			retiring  []string
+			actions   []string
+		)
+		for _, secret := range rs.RetiringSecrets {
+			switch retiringAction(secret, gracePeriod) {
+			case plan.Delete:
+				err := r.callFrom(ctx, rptr, rn, func(ctx context.Context) error {
+					return stager.Delete(ctx, dryRun, secret.ID)
+				})
+				if err != nil {
+					fail(err)
+					return
+				}
+				actions = append(actions, fmt.Sprintf("delete: %s", secret.ID))
+				continue
+			case plan.Deactivate:
+				err := r.callFrom(ctx, rptr, rn, func(ctx context.Context) error {
+					return stager.Deactivate(ctx, dryRun, secret.ID)
+				})
+				if err != nil {
+					fail(err)
+					return
+				}
+				now := time.Now()
+				secret.DeactivatedAt = &now
+				actions = append(actions, fmt.Sprintf("deactivate: %s", secret.ID))
+			}
+			remaining = append(remaining, secret)
+			retiring = append(retiring, secret.ID)
+		}
+		stager.SetRetiring(retiring)
+
+		if len(actions) == 0 {
+			rptr.Skip()
+			return
+		}
+		rptr.Summary(strings.Join(actions, ", "))
+
+		if !dryRun {
+			rs.RetiringSecrets = remaining
+			if err := r.state.Save(rn.Name, rs); err != nil {
+				fail(err)
+				return
+			}
+		}
+		rptr.Success()
+	})
+
+	return ok
+}
+
+// retiringAction returns what retire does with the retiring secret in this run,
+// if anything.
+func retiringAction(secret *state.RetiringSecret, gracePeriod time.Duration) string {
+	switch {
+	case secret.DeactivatedAt != nil:
+		return plan.Delete
+	case time.Since(secret.SupersededAt) >= gracePeriod:
+		return plan.Deactivate
+	}
+	return ""
+}
+
+// stage records the superseded secrets so that they are retired in later runs.
+func (r *Runner) stage(rptr *reporting.R, rn *schema.Rotation, superseded []string) bool {
+	if len(superseded) == 0 {
+		return true
+	}
+
+	ok := true
+	rptr.Run(fmt.Sprintf("Cleanup/%s", rn.From.Provider), func(rptr *reporting.R) {
+		rptr.Summary(fmt.Sprintf("retire after %s: %s", rn.GracePeriod, strings.Join(superseded, ", ")))
+		rs, err := r.state.Load(rn.Name)
+		if err != nil {
+			rptr.Fail(err)
+			ok = false
+			return
+		}
+		for _, id := range superseded {
+			rs.RetiringSecrets = append(rs.RetiringSecrets, &state.RetiringSecret{
+				ID:           id,
+				SupersededAt: time.Now(),
+			})
+		}
+		if err := r.state.Save(rn.Name, rs); err != nil {
+			rptr.Fail(err)
+			ok = false
+			return
+		}
+		rptr.Success()
+	})
+
+	return ok
+}
+
+// superseded returns the IDs of the secrets superseded by the ones issued by
+// the from provider, if it supports staged rotations.
+func superseded(rn *schema.Rotation) []string {
+	if stager, ok := rn.From.Spec.Operator.(fromprovider.Stager); ok {
+		return stager.Superseded()
+	}
+	return nil
+}
+
+// secretID returns the non-sensitive identifier of the secrets, if the from
+// provider supports it.
+func secretID(rn *schema.Rotation, s secrets.Secrets) string {
+	if id, ok := rn.From.Spec.Operator.(fromprovider.Identifier); ok && len(s) > 0 {
+		return id.SecretID(s)
+	}
+	return ""
+}
+
+// record appends the result of the rotation to its history. Only non-sensitive
+// information is recorded.
+func (r *Runner) record(rn *schema.Rotation, result *reporting.Result, startedAt time.Time, issued secrets.Secrets) {
+	run := &state.Run{
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+		Status:     result.Outcome(),
+	}
+	run.SecretID = secretID(rn, issued)
+	for _, child := range result.Children {
+		run.Providers = append(run.Providers, &state.ProviderResult{
+			Name:     child.Name,
+			Status:   child.Status,
+			Summary:  child.Summary,
+			Error:    child.Err,
+			Attempts: child.Attempts,
+		})
+	}
+
+	rs, err := r.state.Load(rn.Name)
+	if err == nil {
+		rs.AddRun(run)
+		err = r.state.Save(rn.Name, rs)
+	}
+	if err != nil {
+		log.Printf("failed to record the history of %s: %v", rn.Name, err)
+	}
+}
@@ -1,22 +1,60 @@
 package revolver
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/grezar/revolver/notify"
+	fromprovider "github.com/grezar/revolver/provider/from"
 	mockedfp "github.com/grezar/revolver/provider/from/mocks"
 	mockedtp "github.com/grezar/revolver/provider/to/mocks"
 	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/retry"
 	"github.com/grezar/revolver/schema"
 	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/state"
 )
 
 var (
 	errFakeRunnerTest = errors.New("runner test fake error")
 )
 
+type mockedTransactionalFromOperator struct {
+	*mockedfp.MockOperator
+	*mockedfp.MockCleaner
+	*mockedfp.MockRevoker
+	*mockedfp.MockVerifier
+}
+
+type mockedRestorableToOperator struct {
+	*mockedtp.MockOperator
+	*mockedtp.MockRestorer
+}
+
+// retrier implements the Retrier interfaces of the providers for the mocked
+// operators.
+type retrier struct {
+	r retry.Retrier
+}
+
+func (r *retrier) SetRetryPolicy(p retry.Policy) {
+	r.r.SetRetryPolicy(p)
+}
+
+func (r *retrier) Retries() int {
+	return r.r.Retries()
+}
+
 func TestRunner_Run(t *testing.T) {
 	type fields struct {
 		mockedRotations func(t *testing.T, ctrl *gomock.Controller, dryRun bool) []*schema.Rotation
@@ -205,6 +243,287 @@ func TestRunner_Run(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "All destinations are updated in a transactional rotation and the previous secret is cleaned up",
+			fields: fields{
+				mockedRotations: func(t *testing.T, ctrl *gomock.Controller, dryRun bool) []*schema.Rotation {
+					t.Helper()
+
+					ctx := context.Background()
+					expectedSecrets := secrets.Secrets{
+						"KEY_ID": "key1",
+					}
+
+					mockedFromOperator := mockedTransactionalFromOperator{
+						MockOperator: mockedfp.NewMockOperator(ctrl),
+						MockCleaner:  mockedfp.NewMockCleaner(ctrl),
+						MockRevoker:  mockedfp.NewMockRevoker(ctrl),
+					}
+					mockedToOperator := mockedRestorableToOperator{
+						MockOperator: mockedtp.NewMockOperator(ctrl),
+						MockRestorer: mockedtp.NewMockRestorer(ctrl),
+					}
+
+					// advance dry-run
+					mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator")
+					mockedFromOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil, nil)
+					mockedFromOperator.MockCleaner.EXPECT().Cleanup(ctx, true).Return(nil)
+					mockedToOperator.MockOperator.EXPECT().Summary().Return("mocked to operator")
+					mockedToOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil)
+
+					// actual run
+					mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator").Times(2)
+					mockedFromOperator.MockOperator.EXPECT().Do(ctx, false).Return(expectedSecrets, nil)
+					ctx = secrets.WithSecrets(ctx, expectedSecrets)
+					mockedToOperator.MockOperator.EXPECT().Summary().Return("mocked to operator")
+					mockedToOperator.MockRestorer.EXPECT().Snapshot(ctx).Return(nil)
+					mockedToOperator.MockOperator.EXPECT().Do(ctx, false).Return(nil)
+					mockedFromOperator.MockCleaner.EXPECT().Cleanup(ctx, false).Return(nil)
+
+					rotations := []*schema.Rotation{
+						{
+							Name: "Mocked Rotation",
+							From: schema.From{
+								Spec: schema.FromProviderSpec{
+									Operator: mockedFromOperator,
+								},
+							},
+							To: []*schema.To{
+								{
+									Spec: schema.ToProviderSpec{
+										Operator: mockedToOperator,
+									},
+								},
+							},
+							Transactional: true,
+						},
+					}
+
+					return rotations
+				},
+			},
+		},
+		{
+			name: "A required destination fails in a transactional rotation and the rotation is rolled back",
+			fields: fields{
+				mockedRotations: func(t *testing.T, ctrl *gomock.Controller, dryRun bool) []*schema.Rotation {
+					t.Helper()
+
+					ctx := context.Background()
+					expectedSecrets := secrets.Secrets{
+						"KEY_ID": "key1",
+					}
+
+					mockedFromOperator := mockedTransactionalFromOperator{
+						MockOperator: mockedfp.NewMockOperator(ctrl),
+						MockCleaner:  mockedfp.NewMockCleaner(ctrl),
+						MockRevoker:  mockedfp.NewMockRevoker(ctrl),
+					}
+					mockedToOperator1 := mockedRestorableToOperator{
+						MockOperator: mockedtp.NewMockOperator(ctrl),
+						MockRestorer: mockedtp.NewMockRestorer(ctrl),
+					}
+					mockedToOperator2 := mockedRestorableToOperator{
+						MockOperator: mockedtp.NewMockOperator(ctrl),
+						MockRestorer: mockedtp.NewMockRestorer(ctrl),
+					}
+					mockedToOperator3 := mockedRestorableToOperator{
+						MockOperator: mockedtp.NewMockOperator(ctrl),
+						MockRestorer: mockedtp.NewMockRestorer(ctrl),
+					}
+
+					// advance dry-run
+					mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator")
+					mockedFromOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil, nil)
+					mockedFromOperator.MockCleaner.EXPECT().Cleanup(ctx, true).Return(nil)
+					mockedToOperator1.MockOperator.EXPECT().Summary().Return("mocked to operator 1")
+					mockedToOperator1.MockOperator.EXPECT().Do(ctx, true).Return(nil)
+					mockedToOperator2.MockOperator.EXPECT().Summary().Return("mocked to operator 2")
+					mockedToOperator2.MockOperator.EXPECT().Do(ctx, true).Return(nil)
+					mockedToOperator3.MockOperator.EXPECT().Summary().Return("mocked to operator 3")
+					mockedToOperator3.MockOperator.EXPECT().Do(ctx, true).Return(nil)
+
+					// actual run
+					mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator").Times(2)
+					mockedFromOperator.MockOperator.EXPECT().Do(ctx, false).Return(expectedSecrets, nil)
+					ctx = secrets.WithSecrets(ctx, expectedSecrets)
+					mockedToOperator1.MockOperator.EXPECT().Summary().Return("mocked to operator 1").Times(2)
+					mockedToOperator1.MockRestorer.EXPECT().Snapshot(ctx).Return(nil)
+					mockedToOperator1.MockOperator.EXPECT().Do(ctx, false).Return(nil)
+					mockedToOperator2.MockOperator.EXPECT().Summary().Return("mocked to operator 2").Times(2)
+					mockedToOperator2.MockRestorer.EXPECT().Snapshot(ctx).Return(nil)
+					mockedToOperator2.MockOperator.EXPECT().Do(ctx, false).Return(errFakeRunnerTest)
+					// The following destination is skipped.
+					mockedToOperator3.MockOperator.EXPECT().Summary().Return("mocked to operator 3")
+
+					// rollback
+					gomock.InOrder(
+						mockedToOperator2.MockRestorer.EXPECT().Restore(ctx).Return(nil),
+						mockedToOperator1.MockRestorer.EXPECT().Restore(ctx).Return(nil),
+						mockedFromOperator.MockRevoker.EXPECT().Revoke(ctx, expectedSecrets).Return(nil),
+					)
+
+					rotations := []*schema.Rotation{
+						{
+							Name: "Mocked Rotation",
+							From: schema.From{
+								Spec: schema.FromProviderSpec{
+									Operator: mockedFromOperator,
+								},
+							},
+							To: []*schema.To{
+								{
+									Spec: schema.ToProviderSpec{
+										Operator: mockedToOperator1,
+									},
+								},
+								{
+									Spec: schema.ToProviderSpec{
+										Operator: mockedToOperator2,
+									},
+								},
+								{
+									Spec: schema.ToProviderSpec{
+										Operator: mockedToOperator3,
+									},
+								},
+							},
+							Transactional: true,
+						},
+					}
+
+					return rotations
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "The new secrets don't work in a transactional rotation and the rotation is rolled back",
+			fields: fields{
+				mockedRotations: func(t *testing.T, ctrl *gomock.Controller, dryRun bool) []*schema.Rotation {
+					t.Helper()
+
+					ctx := context.Background()
+					expectedSecrets := secrets.Secrets{
+						"KEY_ID": "key1",
+					}
+
+					mockedFromOperator := mockedTransactionalFromOperator{
+						MockOperator: mockedfp.NewMockOperator(ctrl),
+						MockCleaner:  mockedfp.NewMockCleaner(ctrl),
+						MockRevoker:  mockedfp.NewMockRevoker(ctrl),
+						MockVerifier: mockedfp.NewMockVerifier(ctrl),
+					}
+					mockedToOperator := mockedRestorableToOperator{
+						MockOperator: mockedtp.NewMockOperator(ctrl),
+						MockRestorer: mockedtp.NewMockRestorer(ctrl),
+					}
+
+					// advance dry-run
+					mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator")
+					mockedFromOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil, nil)
+					mockedFromOperator.MockCleaner.EXPECT().Cleanup(ctx, true).Return(nil)
+					mockedToOperator.MockOperator.EXPECT().Summary().Return("mocked to operator")
+					mockedToOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil)
+
+					// actual run
+					mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator").Times(3)
+					mockedFromOperator.MockOperator.EXPECT().Do(ctx, false).Return(expectedSecrets, nil)
+					ctx = secrets.WithSecrets(ctx, expectedSecrets)
+					mockedToOperator.MockOperator.EXPECT().Summary().Return("mocked to operator").Times(2)
+					mockedToOperator.MockRestorer.EXPECT().Snapshot(ctx).Return(nil)
+					mockedToOperator.MockOperator.EXPECT().Do(ctx, false).Return(nil)
+					mockedFromOperator.MockVerifier.EXPECT().Verify(ctx, expectedSecrets).Return(errFakeRunnerTest)
+
+					// rollback
+					gomock.InOrder(
+						mockedToOperator.MockRestorer.EXPECT().Restore(ctx).Return(nil),
+						mockedFromOperator.MockRevoker.EXPECT().Revoke(ctx, expectedSecrets).Return(nil),
+					)
+
+					rotations := []*schema.Rotation{
+						{
+							Name: "Mocked Rotation",
+							From: schema.From{
+								Spec: schema.FromProviderSpec{
+									Operator: mockedFromOperator,
+								},
+							},
+							To: []*schema.To{
+								{
+									Spec: schema.ToProviderSpec{
+										Operator: mockedToOperator,
+									},
+								},
+							},
+							Transactional: true,
+							Verify:        true,
+						},
+					}
+
+					return rotations
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "An optional destination fails in a transactional rotation and the rotation isn't rolled back",
+			fields: fields{
+				mockedRotations: func(t *testing.T, ctrl *gomock.Controller, dryRun bool) []*schema.Rotation {
+					t.Helper()
+
+					ctx := context.Background()
+					expectedSecrets := secrets.Secrets{
+						"KEY_ID": "key1",
+					}
+
+					mockedFromOperator := mockedTransactionalFromOperator{
+						MockOperator: mockedfp.NewMockOperator(ctrl),
+						MockCleaner:  mockedfp.NewMockCleaner(ctrl),
+						MockRevoker:  mockedfp.NewMockRevoker(ctrl),
+					}
+					mockedToOperator := mockedtp.NewMockOperator(ctrl)
+
+					// advance dry-run
+					mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator")
+					mockedFromOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil, nil)
+					mockedFromOperator.MockCleaner.EXPECT().Cleanup(ctx, true).Return(nil)
+					mockedToOperator.EXPECT().Summary().Return("mocked to operator")
+					mockedToOperator.EXPECT().Do(ctx, true).Return(nil)
+
+					// actual run
+					mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator").Times(2)
+					mockedFromOperator.MockOperator.EXPECT().Do(ctx, false).Return(expectedSecrets, nil)
+					ctx = secrets.WithSecrets(ctx, expectedSecrets)
+					mockedToOperator.EXPECT().Summary().Return("mocked to operator")
+					mockedToOperator.EXPECT().Do(ctx, false).Return(errFakeRunnerTest)
+					mockedFromOperator.MockCleaner.EXPECT().Cleanup(ctx, false).Return(nil)
+
+					rotations := []*schema.Rotation{
+						{
+							Name: "Mocked Rotation",
+							From: schema.From{
+								Spec: schema.FromProviderSpec{
+									Operator: mockedFromOperator,
+								},
+							},
+							To: []*schema.To{
+								{
+									Spec: schema.ToProviderSpec{
+										Operator: mockedToOperator,
+									},
+									Optional: true,
+								},
+							},
+							Transactional: true,
+						},
+					}
+
+					return rotations
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -223,3 +542,930 @@ func TestRunner_Run(t *testing.T) {
 		})
 	}
 }
+
+type mockedStagedFromOperator struct {
+	*mockedfp.MockOperator
+	*mockedfp.MockStager
+}
+
+func TestRunner_Run_Staged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	deactivatedAt := time.Now().Add(-24 * time.Hour)
+	err := store.Save("Mocked Rotation", &state.Rotation{
+		RetiringSecrets: []*state.RetiringSecret{
+			{
+				ID:            "DEACTIVATED",
+				SupersededAt:  time.Now().Add(-30 * 24 * time.Hour),
+				DeactivatedAt: &deactivatedAt,
+			},
+			{
+				ID:           "GRACE_PERIOD_PASSED",
+				SupersededAt: time.Now().Add(-8 * 24 * time.Hour),
+			},
+			{
+				ID:           "IN_GRACE_PERIOD",
+				SupersededAt: time.Now().Add(-24 * time.Hour),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockedFromOperator := mockedStagedFromOperator{
+		MockOperator: mockedfp.NewMockOperator(ctrl),
+		MockStager:   mockedfp.NewMockStager(ctrl),
+	}
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+	mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator").AnyTimes()
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").AnyTimes()
+
+	expectedSecrets := secrets.Secrets{
+		"KEY_ID": "key1",
+	}
+	gomock.InOrder(
+		// advance dry-run
+		mockedFromOperator.MockStager.EXPECT().Delete(ctx, true, "DEACTIVATED"),
+		mockedFromOperator.MockStager.EXPECT().Deactivate(ctx, true, "GRACE_PERIOD_PASSED"),
+		mockedFromOperator.MockStager.EXPECT().SetRetiring([]string{"GRACE_PERIOD_PASSED", "IN_GRACE_PERIOD"}),
+		mockedFromOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil, nil),
+		// actual run
+		mockedFromOperator.MockStager.EXPECT().Delete(ctx, false, "DEACTIVATED"),
+		mockedFromOperator.MockStager.EXPECT().Deactivate(ctx, false, "GRACE_PERIOD_PASSED"),
+		mockedFromOperator.MockStager.EXPECT().SetRetiring([]string{"GRACE_PERIOD_PASSED", "IN_GRACE_PERIOD"}),
+		mockedFromOperator.MockOperator.EXPECT().Do(ctx, false).Return(expectedSecrets, nil),
+		mockedToOperator.EXPECT().Do(secrets.WithSecrets(ctx, expectedSecrets), false),
+		mockedFromOperator.MockStager.EXPECT().Superseded().Return([]string{"SUPERSEDED"}),
+	)
+	mockedToOperator.EXPECT().Do(ctx, true)
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator,
+						},
+					},
+				},
+				GracePeriod: "7d",
+			},
+		},
+		state: store,
+	}
+
+	ok := reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	})
+	if !ok {
+		t.Fatal("Runner.Run() failed")
+	}
+
+	rs, err := store.Load("Mocked Rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range rs.RetiringSecrets {
+		got = append(got, s.ID)
+	}
+	want := []string{"GRACE_PERIOD_PASSED", "IN_GRACE_PERIOD", "SUPERSEDED"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("retiring secrets = %v, want %v", got, want)
+	}
+	if rs.RetiringSecrets[0].DeactivatedAt == nil {
+		t.Errorf("%s is not marked as deactivated", rs.RetiringSecrets[0].ID)
+	}
+}
+
+func TestRunner_Run_NotStaged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	err := store.Save("Mocked Rotation", &state.Rotation{
+		RetiringSecrets: []*state.RetiringSecret{
+			{
+				ID:           "GRACE_PERIOD_PASSED",
+				SupersededAt: time.Now().Add(-8 * 24 * time.Hour),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A rotation without a grace period doesn't retire secrets even if the
+	// provider supports staged rotations.
+	mockedFromOperator := mockedStagedFromOperator{
+		MockOperator: mockedfp.NewMockOperator(ctrl),
+		MockStager:   mockedfp.NewMockStager(ctrl),
+	}
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+	mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator").AnyTimes()
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").AnyTimes()
+
+	expectedSecrets := secrets.Secrets{
+		"KEY_ID": "key1",
+	}
+	mockedFromOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil, nil)
+	mockedToOperator.EXPECT().Do(ctx, true)
+	mockedFromOperator.MockOperator.EXPECT().Do(ctx, false).Return(expectedSecrets, nil)
+	mockedToOperator.EXPECT().Do(secrets.WithSecrets(ctx, expectedSecrets), false)
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator,
+						},
+					},
+				},
+			},
+		},
+		state: store,
+	}
+
+	ok := reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	}, reporting.WithReport(io.Discard, reporting.Table))
+	if !ok {
+		t.Fatal("Runner.Run() failed")
+	}
+
+	rs, err := store.Load("Mocked Rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.RetiringSecrets) != 1 || rs.RetiringSecrets[0].DeactivatedAt != nil {
+		t.Errorf("retiring secrets = %v, want them untouched", rs.RetiringSecrets)
+	}
+}
+
+type mockedIdentifiableFromOperator struct {
+	*mockedfp.MockOperator
+	*mockedfp.MockIdentifier
+}
+
+func TestRunner_Run_History(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	mockedFromOperator := mockedIdentifiableFromOperator{
+		MockOperator:   mockedfp.NewMockOperator(ctrl),
+		MockIdentifier: mockedfp.NewMockIdentifier(ctrl),
+	}
+	mockedToOperator1 := mockedtp.NewMockOperator(ctrl)
+	mockedToOperator2 := mockedtp.NewMockOperator(ctrl)
+	expectedSecrets := secrets.Secrets{
+		"KEY_ID": "key1",
+		"SECRET": "secret1",
+	}
+
+	// advance dry-run
+	mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator")
+	mockedFromOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil, nil)
+	mockedToOperator1.EXPECT().Summary().Return("mocked to operator 1")
+	mockedToOperator1.EXPECT().Do(ctx, true)
+	mockedToOperator2.EXPECT().Summary().Return("mocked to operator 2")
+	mockedToOperator2.EXPECT().Do(ctx, true)
+
+	// actual run
+	mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator")
+	mockedFromOperator.MockOperator.EXPECT().Do(ctx, false).Return(expectedSecrets, nil)
+	ctx = secrets.WithSecrets(ctx, expectedSecrets)
+	mockedToOperator1.EXPECT().Summary().Return("mocked to operator 1")
+	mockedToOperator1.EXPECT().Do(ctx, false)
+	mockedToOperator2.EXPECT().Summary().Return("mocked to operator 2")
+	mockedToOperator2.EXPECT().Do(ctx, false).Return(errFakeRunnerTest)
+	mockedFromOperator.MockIdentifier.EXPECT().SecretID(expectedSecrets).Return("key1")
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Provider: "Mock",
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Provider: "Mock1",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator1,
+						},
+					},
+					{
+						Provider: "Mock2",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator2,
+						},
+					},
+				},
+			},
+		},
+		state: store,
+	}
+
+	reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	})
+
+	rs, err := store.Load("Mocked Rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.History) != 1 {
+		t.Fatalf("len(history) = %d, want 1", len(rs.History))
+	}
+	run := rs.History[0]
+	if run.Status != reporting.Error || run.SecretID != "key1" {
+		t.Errorf("run = {Status: %s, SecretID: %s}, want {Status: %s, SecretID: key1}", run.Status, run.SecretID, reporting.Error)
+	}
+	want := []*state.ProviderResult{
+		{Name: "From/Mock", Status: reporting.Created, Summary: "mocked from operator", Attempts: 1},
+		{Name: "To/Mock1", Status: reporting.Updated, Summary: "mocked to operator 1", Attempts: 1},
+		{Name: "To/Mock2", Status: reporting.Error, Summary: "mocked to operator 2", Error: errFakeRunnerTest.Error(), Attempts: 1},
+	}
+	if !reflect.DeepEqual(run.Providers, want) {
+		t.Errorf("providers = %v, want %v", run.Providers, want)
+	}
+}
+
+func TestRunner_Run_DryRunPlaceholders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// The from provider issues no secrets in dry-run mode.
+	provider := mockedfp.NewMockProvider(ctrl)
+	provider.EXPECT().Name().Return("PlaceholderMock").AnyTimes()
+	provider.EXPECT().SecretKeys().Return([]string{"SECRET"}).AnyTimes()
+	fromprovider.Register(provider)
+
+	mockedFromOperator := mockedfp.NewMockOperator(ctrl)
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator").Times(2)
+	mockedFromOperator.EXPECT().Do(gomock.Any(), true).Return(nil, nil)
+	mockedFromOperator.EXPECT().Do(gomock.Any(), false).Return(secrets.Secrets{"SECRET": "placeholder test secret"}, nil)
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").Times(2)
+	var rendered []string
+	mockedToOperator.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, dryRun bool) error {
+		s, err := secrets.ExecuteTemplate(ctx, "{{ .SECRET }}")
+		rendered = append(rendered, s)
+		return err
+	}).Times(2)
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Provider: "PlaceholderMock",
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Provider: "Mock",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if !reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	}, reporting.WithReport(io.Discard, reporting.Table)) {
+		t.Fatal("the run failed")
+	}
+	if want := []string{"<SECRET>", "placeholder test secret"}; !reflect.DeepEqual(rendered, want) {
+		t.Errorf("rendered = %v, want %v", rendered, want)
+	}
+}
+
+func TestRunner_Run_Redaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	mockedFromOperator := mockedfp.NewMockOperator(ctrl)
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+	issued := secrets.Secrets{
+		"SECRET": "redacted secret",
+	}
+
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator").Times(2)
+	mockedFromOperator.EXPECT().Do(gomock.Any(), true).Return(nil, nil)
+	mockedFromOperator.EXPECT().Do(gomock.Any(), false).Return(issued, nil)
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").Times(2)
+	mockedToOperator.EXPECT().Do(gomock.Any(), true)
+	// An API error echoing the request body.
+	mockedToOperator.EXPECT().Do(gomock.Any(), false).Return(errors.New(`bad request: {"value":"redacted secret"}`))
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Provider: "Mock",
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Provider: "Mock",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator,
+						},
+					},
+				},
+			},
+		},
+		state: store,
+	}
+
+	reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	})
+
+	rs, err := store.Load("Mocked Rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.History) != 1 {
+		t.Fatalf("len(history) = %d, want 1", len(rs.History))
+	}
+	got := rs.History[0].Providers[1].Error
+	if want := `bad request: {"value":"[REDACTED]"}`; got != want {
+		t.Errorf("error = %s, want %s", got, want)
+	}
+}
+
+func TestRunner_Run_Resume(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	key := bytes.Repeat([]byte{1}, secrets.KeySize)
+
+	mockedFromOperator := mockedfp.NewMockOperator(ctrl)
+	mockedToOperator1 := mockedtp.NewMockOperator(ctrl)
+	mockedToOperator2 := mockedtp.NewMockOperator(ctrl)
+	expectedSecrets := secrets.Secrets{
+		"KEY_ID": "key1",
+		"SECRET": "secret1",
+	}
+	rotations := []*schema.Rotation{
+		{
+			Name: "Mocked Rotation",
+			From: schema.From{
+				Provider: "Mock",
+				Spec: schema.FromProviderSpec{
+					Operator: mockedFromOperator,
+				},
+			},
+			To: []*schema.To{
+				{
+					Provider: "Mock1",
+					Spec: schema.ToProviderSpec{
+						Operator: mockedToOperator1,
+					},
+				},
+				{
+					Provider: "Mock2",
+					Spec: schema.ToProviderSpec{
+						Operator: mockedToOperator2,
+					},
+				},
+			},
+		},
+	}
+	run := func(resume bool) bool {
+		r := &Runner{
+			rotations:     rotations,
+			state:         store,
+			checkpointKey: key,
+			resume:        resume,
+		}
+		return reporting.Run(func(rptr *reporting.R) {
+			r.Run(rptr)
+		})
+	}
+	loadCheckpoint := func() *state.Checkpoint {
+		rs, err := store.Load("Mocked Rotation")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rs.Checkpoint
+	}
+
+	// The run is interrupted after updating the first destination.
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator").Times(2)
+	mockedFromOperator.EXPECT().Do(ctx, true).Return(nil, nil)
+	mockedFromOperator.EXPECT().Do(ctx, false).Return(expectedSecrets, nil)
+	mockedToOperator1.EXPECT().Summary().Return("mocked to operator 1").Times(2)
+	mockedToOperator1.EXPECT().Do(ctx, true)
+	mockedToOperator1.EXPECT().Do(secrets.WithSecrets(ctx, expectedSecrets), false)
+	mockedToOperator2.EXPECT().Summary().Return("mocked to operator 2").Times(2)
+	mockedToOperator2.EXPECT().Do(ctx, true)
+	mockedToOperator2.EXPECT().Do(secrets.WithSecrets(ctx, expectedSecrets), false).Return(errFakeRunnerTest)
+	if run(false) {
+		t.Fatal("the first run succeeded unexpectedly")
+	}
+	checkpoint := loadCheckpoint()
+	if checkpoint == nil {
+		t.Fatal("checkpoint was not saved")
+	}
+	if want := []string{"0/Mock1"}; !reflect.DeepEqual(checkpoint.Destinations, want) {
+		t.Errorf("destinations = %v, want %v", checkpoint.Destinations, want)
+	}
+
+	// Another run is refused without --resume not to issue another secret.
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator")
+	if run(false) {
+		t.Fatal("the run without resume succeeded unexpectedly")
+	}
+
+	// The resumed run distributes the same secrets only to the destination
+	// which has not been updated.
+	mockedToOperator1.EXPECT().Summary().Return("mocked to operator 1")
+	mockedToOperator2.EXPECT().Summary().Return("mocked to operator 2")
+	mockedToOperator2.EXPECT().Do(secrets.WithSecrets(ctx, expectedSecrets), false)
+	if !run(true) {
+		t.Fatal("the resumed run failed")
+	}
+	if checkpoint := loadCheckpoint(); checkpoint != nil {
+		t.Errorf("checkpoint = %v, want nil", checkpoint)
+	}
+}
+
+func TestRunner_Run_CheckpointBeforeCleanup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	mockedFromOperator := struct {
+		*mockedfp.MockOperator
+		*mockedfp.MockCleaner
+	}{mockedfp.NewMockOperator(ctrl), mockedfp.NewMockCleaner(ctrl)}
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+	mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator").AnyTimes()
+	mockedFromOperator.MockOperator.EXPECT().Do(gomock.Any(), true).Return(nil, nil)
+	mockedFromOperator.MockOperator.EXPECT().Do(gomock.Any(), false).Return(secrets.Secrets{"SECRET": "checkpoint test secret"}, nil)
+	mockedFromOperator.MockCleaner.EXPECT().Cleanup(gomock.Any(), true)
+	mockedFromOperator.MockCleaner.EXPECT().Cleanup(gomock.Any(), false).Return(errFakeRunnerTest)
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").AnyTimes()
+	mockedToOperator.EXPECT().Do(gomock.Any(), true)
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Provider: "Mock",
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Provider: "Mock",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator,
+						},
+					},
+				},
+			},
+		},
+		state:         store,
+		checkpointKey: bytes.Repeat([]byte{1}, secrets.KeySize),
+	}
+	if reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	}, reporting.WithReport(io.Discard, reporting.Table)) {
+		t.Fatal("the run succeeded unexpectedly")
+	}
+
+	// The new secret is kept to be resumed though the cleanup failed.
+	rs, err := store.Load("Mocked Rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Checkpoint == nil {
+		t.Fatal("checkpoint was not saved")
+	}
+	if rs.Checkpoint.CleanedUp {
+		t.Error("CleanedUp = true, want false")
+	}
+}
+
+func TestRunner_Run_DependsOn(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+
+	rotation := func(name string, from *mockedfp.MockOperator, to *mockedtp.MockOperator, dependsOn ...string) *schema.Rotation {
+		return &schema.Rotation{
+			Name:      name,
+			DependsOn: dependsOn,
+			From: schema.From{
+				Provider: "Mock",
+				Spec: schema.FromProviderSpec{
+					Operator: from,
+				},
+			},
+			To: []*schema.To{
+				{
+					Provider: "Mock",
+					Spec: schema.ToProviderSpec{
+						Operator: to,
+					},
+				},
+			},
+		}
+	}
+
+	// a fails, so b which depends on it and c which depends on b are
+	// skipped. d runs after a regardless.
+	fromA, toA := mockedfp.NewMockOperator(ctrl), mockedtp.NewMockOperator(ctrl)
+	fromB, toB := mockedfp.NewMockOperator(ctrl), mockedtp.NewMockOperator(ctrl)
+	fromC, toC := mockedfp.NewMockOperator(ctrl), mockedtp.NewMockOperator(ctrl)
+	fromD, toD := mockedfp.NewMockOperator(ctrl), mockedtp.NewMockOperator(ctrl)
+	fromE, toE := mockedfp.NewMockOperator(ctrl), mockedtp.NewMockOperator(ctrl)
+
+	fromA.EXPECT().Summary().Return("from a")
+	fromA.EXPECT().Do(ctx, true).Return(nil, errFakeRunnerTest)
+	toA.EXPECT().Summary().Return("to a")
+	toA.EXPECT().Do(ctx, true)
+
+	fromD.EXPECT().Summary().Return("from d")
+	fromD.EXPECT().Do(ctx, true).Return(nil, nil)
+	toD.EXPECT().Summary().Return("to d")
+	doneD := toD.EXPECT().Do(ctx, true)
+
+	// e depends on d, so it starts after d has completed.
+	fromE.EXPECT().Summary().Return("from e")
+	fromE.EXPECT().Do(ctx, true).Return(nil, nil).After(doneD)
+	toE.EXPECT().Summary().Return("to e")
+	toE.EXPECT().Do(ctx, true)
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			rotation("c", fromC, toC, "b"),
+			rotation("b", fromB, toB, "a"),
+			rotation("a", fromA, toA),
+			rotation("e", fromE, toE, "d"),
+			rotation("d", fromD, toD),
+		},
+		dryRun: true,
+	}
+
+	var result *reporting.Result
+	reporting.Run(func(rptr *reporting.R) {
+		rptr.Cleanup(func() {
+			result = rptr.Result()
+		})
+		r.Run(rptr)
+	})
+
+	// The rotations are reported after the rotations they depend on.
+	var names []string
+	for _, rn := range result.Children {
+		names = append(names, rn.Name)
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("rotations = %v, want %v", names, want)
+	}
+
+	for _, rn := range result.Children {
+		switch rn.Name {
+		case "b", "c":
+			from := rn.Children[0]
+			if from.Status != reporting.Skip {
+				t.Errorf("rotation %s status = %s, want %s", rn.Name, from.Status, reporting.Skip)
+			}
+		}
+	}
+}
+
+func TestRunner_RunContext_Cancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	mockedFromOperator := mockedfp.NewMockOperator(ctrl)
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+	expectedSecrets := secrets.Secrets{
+		"KEY_ID": "key1",
+		"SECRET": "secret1",
+	}
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Provider: "Mock",
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Provider: "Mock",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator,
+						},
+					},
+				},
+			},
+		},
+		state: store,
+	}
+
+	// No provider is called once the run has been cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator")
+	if reporting.Run(func(rptr *reporting.R) {
+		r.RunContext(ctx, rptr)
+	}) {
+		t.Error("the cancelled run succeeded unexpectedly")
+	}
+
+	// The call to the from provider in progress completes, and the
+	// destinations are not updated after that.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator").Times(2)
+	mockedFromOperator.EXPECT().Do(gomock.Any(), true).Return(nil, nil)
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").Times(2)
+	// The advance dry-run completes before the actual run starts.
+	dryRunDone := mockedToOperator.EXPECT().Do(gomock.Any(), true)
+	mockedFromOperator.EXPECT().Do(gomock.Any(), false).DoAndReturn(func(ctx context.Context, dryRun bool) (secrets.Secrets, error) {
+		cancel()
+		if err := ctx.Err(); err != nil {
+			t.Errorf("the call in progress was cancelled: %v", err)
+		}
+		return expectedSecrets, nil
+	}).After(dryRunDone)
+	if reporting.Run(func(rptr *reporting.R) {
+		r.RunContext(ctx, rptr)
+	}) {
+		t.Error("the interrupted run succeeded unexpectedly")
+	}
+
+	rs, err := store.Load("Mocked Rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.History) != 1 {
+		t.Fatalf("len(history) = %d, want 1", len(rs.History))
+	}
+	run := rs.History[0]
+	if run.Status != reporting.Cancelled {
+		t.Errorf("status = %s, want %s", run.Status, reporting.Cancelled)
+	}
+	want := []*state.ProviderResult{
+		{Name: "From/Mock", Status: reporting.Created, Summary: "mocked from operator", Attempts: 1},
+		{Name: "To/Mock", Status: reporting.Cancelled, Summary: "mocked to operator", Error: context.Canceled.Error()},
+	}
+	if !reflect.DeepEqual(run.Providers, want) {
+		t.Errorf("providers = %v, want %v", run.Providers, want)
+	}
+}
+
+func TestRunner_Run_Retry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	// The operators retry their requests themselves.
+	mockedFromOperator := struct {
+		*mockedfp.MockOperator
+		*mockedfp.MockCleaner
+		*retrier
+	}{mockedfp.NewMockOperator(ctrl), mockedfp.NewMockCleaner(ctrl), &retrier{}}
+	mockedToOperator := struct {
+		*mockedtp.MockOperator
+		*retrier
+	}{mockedtp.NewMockOperator(ctrl), &retrier{}}
+	expectedSecrets := secrets.Secrets{
+		"KEY_ID": "key1",
+		"SECRET": "secret1",
+	}
+	noJitter := false
+	retryBlock := &schema.Retry{
+		MaxAttempts:    3,
+		InitialBackoff: schema.Duration(time.Millisecond),
+		Jitter:         &noJitter,
+	}
+
+	mockedFromOperator.MockOperator.EXPECT().Summary().Return("mocked from operator").Times(2)
+	mockedFromOperator.MockOperator.EXPECT().Do(ctx, true).Return(nil, nil)
+	mockedFromOperator.MockOperator.EXPECT().Do(ctx, false).DoAndReturn(func(ctx context.Context, dryRun bool) (secrets.Secrets, error) {
+		calls := 0
+		err := mockedFromOperator.retrier.r.Do(ctx, func() error {
+			calls++
+			if calls == 1 {
+				return retry.Transient(errFakeRunnerTest)
+			}
+			return nil
+		})
+		return expectedSecrets, err
+	})
+	// The cleanup doesn't count as an attempt of the step.
+	mockedFromOperator.MockCleaner.EXPECT().Cleanup(ctx, true)
+	mockedFromOperator.MockCleaner.EXPECT().Cleanup(ctx, false)
+	ctx = secrets.WithSecrets(ctx, expectedSecrets)
+	mockedToOperator.MockOperator.EXPECT().Summary().Return("mocked to operator").Times(2)
+	mockedToOperator.MockOperator.EXPECT().Do(gomock.Any(), true)
+	// The retry block of the provider takes precedence over the rotation's.
+	mockedToOperator.MockOperator.EXPECT().Do(ctx, false).DoAndReturn(func(ctx context.Context, dryRun bool) error {
+		return mockedToOperator.retrier.r.Do(ctx, func() error {
+			return retry.Transient(&retry.StatusError{StatusCode: 503, Status: "503 Service Unavailable"})
+		})
+	})
+
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name:  "Mocked Rotation",
+				Retry: retryBlock,
+				From: schema.From{
+					Provider: "Mock",
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Provider: "Mock",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator,
+						},
+						Retry: &schema.Retry{MaxAttempts: 2},
+					},
+				},
+			},
+		},
+		state: store,
+	}
+	reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	})
+
+	rs, err := store.Load("Mocked Rotation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.History) != 1 {
+		t.Fatalf("len(history) = %d, want 1", len(rs.History))
+	}
+	want := []*state.ProviderResult{
+		{Name: "From/Mock", Status: reporting.Created, Summary: "mocked from operator", Attempts: 2},
+		{Name: "To/Mock", Status: reporting.Error, Summary: "mocked to operator", Error: "503 Service Unavailable", Attempts: 2},
+	}
+	if got := rs.History[0].Providers; !reflect.DeepEqual(got, want) {
+		t.Errorf("providers = %v, want %v", got, want)
+	}
+}
+
+func TestRunner_Run_ProviderMaxConcurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	do := func(ctx context.Context, dryRun bool) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	var rotations []*schema.Rotation
+	for _, name := range []string{"a", "b", "c"} {
+		from := mockedfp.NewMockOperator(ctrl)
+		from.EXPECT().Summary().Return("mocked from operator").AnyTimes()
+		from.EXPECT().Do(gomock.Any(), gomock.Any()).Return(secrets.Secrets{"SECRET": name}, nil).AnyTimes()
+		to := mockedtp.NewMockOperator(ctrl)
+		to.EXPECT().Summary().Return("mocked to operator").AnyTimes()
+		to.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(do).AnyTimes()
+		rotations = append(rotations, &schema.Rotation{
+			Name: name,
+			From: schema.From{
+				Provider: "Mock",
+				Spec:     schema.FromProviderSpec{Operator: from},
+			},
+			To: []*schema.To{
+				{
+					Provider: "Mock",
+					Spec:     schema.ToProviderSpec{Operator: to},
+				},
+			},
+		})
+	}
+
+	r := &Runner{
+		rotations:     rotations,
+		rateLimit:     100,
+		providerSlots: map[string]chan struct{}{"Mock": make(chan struct{}, 1)},
+	}
+	if !reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	}) {
+		t.Fatal("the run failed")
+	}
+	if peak != 1 {
+		t.Errorf("peak concurrency = %d, want 1", peak)
+	}
+}
+
+func TestRunner_Run_Notifications(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	bodies := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer srv.Close()
+
+	mockedFromOperator := mockedfp.NewMockOperator(ctrl)
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator").AnyTimes()
+	mockedFromOperator.EXPECT().Do(gomock.Any(), true).Return(nil, nil)
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").AnyTimes()
+	mockedToOperator.EXPECT().Do(gomock.Any(), true).Return(errFakeRunnerTest)
+
+	// The notifications are sent in dry-run mode too.
+	r := &Runner{
+		rotations: []*schema.Rotation{
+			{
+				Name: "Mocked Rotation",
+				From: schema.From{
+					Provider: "Mock",
+					Spec: schema.FromProviderSpec{
+						Operator: mockedFromOperator,
+					},
+				},
+				To: []*schema.To{
+					{
+						Provider: "Mock",
+						Spec: schema.ToProviderSpec{
+							Operator: mockedToOperator,
+						},
+					},
+				},
+			},
+		},
+		notifications: []*notify.Sink{
+			{Webhook: &notify.Webhook{URL: srv.URL, Body: "{{ .Status }} {{ .Failed }}"}},
+			{On: notify.OnSuccess, Webhook: &notify.Webhook{URL: srv.URL}},
+		},
+		dryRun: true,
+	}
+	reporting.Run(func(rptr *reporting.R) {
+		r.Run(rptr)
+	}, reporting.WithReport(io.Discard, reporting.Table))
+
+	select {
+	case got := <-bodies:
+		if want := "ERROR Mocked Rotation"; got != want {
+			t.Errorf("body = %q, want %q", got, want)
+		}
+	default:
+		t.Fatal("no notification was sent")
+	}
+	select {
+	case got := <-bodies:
+		t.Errorf("unexpected notification %q", got)
+	default:
+	}
+}
@@ -0,0 +1,196 @@
+// Package schedule parses the schedules of rotations, which are either a cron
+// expression or an interval like "every 30d".
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	str2duration "github.com/xhit/go-str2duration/v2"
+)
+
+// Schedule tells when a rotation runs next.
+type Schedule interface {
+	// Next returns the first time to run after the last run at t.
+	Next(t time.Time) time.Time
+}
+
+// Parse parses a schedule. It is either "every" followed by a duration in the
+// same format as expiration, a cron expression with five fields, or one of
+// @yearly, @monthly, @weekly, @daily and @hourly.
+func Parse(s string) (Schedule, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "every ") {
+		d, err := str2duration.ParseDuration(strings.TrimSpace(strings.TrimPrefix(s, "every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", s, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: the interval must be positive", s)
+		}
+		return Every(d), nil
+	}
+	if d, ok := descriptors[s]; ok {
+		s = d
+	}
+	c, err := parseCron(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", s, err)
+	}
+	return c, nil
+}
+
+// Every is a schedule which runs at a fixed interval.
+type Every time.Duration
+
+// Next implements Schedule interface
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+var descriptors = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+// cron is a schedule written as a cron expression. Each field is a set of the
+// values it matches.
+type cron struct {
+	minute, hour, dom, month, dow map[int]bool
+	// As in cron, a day matches if either the day of month or the day of
+	// week matches when both of them are restricted.
+	domRestricted, dowRestricted bool
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseCron(s string) (*cron, error) {
+	exprs := strings.Fields(s)
+	if len(exprs) != len(fields) {
+		return nil, fmt.Errorf("a cron expression must have %d fields", len(fields))
+	}
+
+	sets := make([]map[int]bool, len(fields))
+	for i, f := range fields {
+		set, err := parseField(exprs[i], f)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	// Both 0 and 7 mean Sunday.
+	if sets[4][7] {
+		sets[4][0] = true
+		delete(sets[4], 7)
+	}
+
+	return &cron{
+		minute:        sets[0],
+		hour:          sets[1],
+		dom:           sets[2],
+		month:         sets[3],
+		dow:           sets[4],
+		domRestricted: !strings.HasPrefix(exprs[2], "*"),
+		dowRestricted: !strings.HasPrefix(exprs[4], "*"),
+	}, nil
+}
+
+// parseField parses a comma separated list of *, a value or a range, each of
+// which may be followed by a step like */15 or 1-5/2.
+func parseField(expr string, f field) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, term := range strings.Split(expr, ",") {
+		rng, step := term, 1
+		if i := strings.Index(term, "/"); i >= 0 {
+			var err error
+			rng = term[:i]
+			step, err = strconv.Atoi(term[i+1:])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in the %s field: %s", f.name, term)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || lo > hi {
+				return nil, fmt.Errorf("invalid range in the %s field: %s", f.name, term)
+			}
+		default:
+			v, err := strconv.Atoi(rng)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in the %s field: %s", f.name, term)
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		if lo < f.min || hi > f.max {
+			return nil, fmt.Errorf("the %s field must be between %d and %d: %s", f.name, f.min, f.max, term)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Next implements Schedule interface
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every valid expression matches within a few years, e.g. February 29.
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	// Never matches, like 0 0 31 2 *.
+	return time.Time{}
+}
+
+func (c *cron) matchDay(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
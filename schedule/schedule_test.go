@@ -0,0 +1,97 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	base := time.Date(2022, 1, 31, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		schedule string
+		want     time.Time
+		wantErr  bool
+	}{
+		{
+			schedule: "every 30d",
+			want:     base.Add(30 * 24 * time.Hour),
+		},
+		{
+			schedule: "every 1h30m",
+			want:     base.Add(90 * time.Minute),
+		},
+		{
+			schedule: "*/15 * * * *",
+			want:     time.Date(2022, 1, 31, 10, 45, 0, 0, time.UTC),
+		},
+		{
+			schedule: "0 3 * * *",
+			want:     time.Date(2022, 2, 1, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			schedule: "0 9 1,15 * *",
+			want:     time.Date(2022, 2, 1, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// Saturday or Sunday
+			schedule: "0 0 * * 6-7",
+			want:     time.Date(2022, 2, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// Either the 10th or Monday when both are restricted.
+			schedule: "0 0 10 * 1",
+			want:     time.Date(2022, 2, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			schedule: "0 0 29 2 *",
+			want:     time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			schedule: "@monthly",
+			want:     time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			schedule: "0 0 31 2 *",
+			want:     time.Time{},
+		},
+		{
+			schedule: "every 0d",
+			wantErr:  true,
+		},
+		{
+			schedule: "every month",
+			wantErr:  true,
+		},
+		{
+			schedule: "* * * *",
+			wantErr:  true,
+		},
+		{
+			schedule: "60 * * * *",
+			wantErr:  true,
+		},
+		{
+			schedule: "5-1 * * * *",
+			wantErr:  true,
+		},
+		{
+			schedule: "*/0 * * * *",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			s, err := Parse(tt.schedule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := s.Next(base); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/grezar/revolver/notify"
+	fromprovider "github.com/grezar/revolver/provider/from"
+	toprovider "github.com/grezar/revolver/provider/to"
+	"go.uber.org/ratelimit"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pkg/errors"
+)
+
+// Config is the configuration of Revolver. It is either a mapping with the
+// settings and the rotations, or only the list of rotations.
+type Config struct {
+	Settings  Settings    `yaml:"settings"`
+	Rotations []*Rotation `yaml:"rotations"`
+	// Notifications are where the outcome of a run is sent to.
+	Notifications []*notify.Sink `yaml:"notifications"`
+}
+
+// Settings limits the load Revolver puts on the providers.
+type Settings struct {
+	// RateLimit is the number of rotations started per second.
+	RateLimit int `yaml:"rateLimit"`
+	// MaxConcurrency is the maximum number of rotations running at the same
+	// time. It is unlimited if zero.
+	MaxConcurrency int `yaml:"maxConcurrency"`
+	// Providers are the settings of the providers by their names.
+	Providers map[string]*ProviderSettings `yaml:"providers"`
+}
+
+// ProviderSettings limits the load Revolver puts on a provider, e.g. for a
+// self-hosted Terraform Enterprise whose limits differ from Terraform Cloud.
+type ProviderSettings struct {
+	// RateLimit is the number of requests per second to the API of the
+	// provider, shared by all rotations.
+	RateLimit int `yaml:"rateLimit"`
+	// MaxConcurrency is the maximum number of calls to the provider at the
+	// same time. It is unlimited if zero.
+	MaxConcurrency int `yaml:"maxConcurrency"`
+}
+
+// LoadConfig loads YAML and extracts the settings and the rotations.
+func LoadConfig(r io.Reader) (*Config, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, errors.Wrap(err, "failed to decode YAML")
+	}
+	d := yaml.NewDecoder(bytes.NewReader(b), yaml.UseOrderedMap(), yaml.Strict())
+	rotationsPath := "$.rotations"
+	if _, ok := v.([]interface{}); ok {
+		rotationsPath = "$"
+		err = d.Decode(&cfg.Rotations)
+	} else {
+		err = d.Decode(&cfg)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode YAML")
+	}
+
+	if err := validateDependencies(cfg.Rotations); err != nil {
+		return nil, err
+	}
+	if err := validate(b, &cfg, rotationsPath); err != nil {
+		return nil, err
+	}
+	if err := cfg.applySettings(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateNotifications(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// validateNotifications returns an error if a sink is invalid.
+func (cfg *Config) validateNotifications() error {
+	for i, s := range cfg.Notifications {
+		if s == nil {
+			return errors.Errorf("notifications[%d]: a sink is required", i)
+		}
+		if err := s.Validate(); err != nil {
+			return errors.Wrapf(err, "notifications[%d]", i)
+		}
+	}
+	return nil
+}
+
+// applySettings validates the settings and makes the operators of each
+// provider share a rate limiter with the rate in the settings.
+func (cfg *Config) applySettings() error {
+	s := cfg.Settings
+	if s.RateLimit < 0 {
+		return errors.Errorf("settings: rateLimit must not be negative: %d", s.RateLimit)
+	}
+	if s.MaxConcurrency < 0 {
+		return errors.Errorf("settings: maxConcurrency must not be negative: %d", s.MaxConcurrency)
+	}
+
+	for name, ps := range s.Providers {
+		if fromprovider.Get(name) == nil && toprovider.Get(name) == nil {
+			return errors.Errorf("settings: unknown provider: %s", name)
+		}
+		if ps == nil {
+			continue
+		}
+		if ps.RateLimit < 0 {
+			return errors.Errorf("settings: rateLimit of %s must not be negative: %d", name, ps.RateLimit)
+		}
+		if ps.MaxConcurrency < 0 {
+			return errors.Errorf("settings: maxConcurrency of %s must not be negative: %d", name, ps.MaxConcurrency)
+		}
+		if ps.RateLimit == 0 {
+			continue
+		}
+
+		limiter := ratelimit.New(ps.RateLimit)
+		for _, rn := range cfg.Rotations {
+			if rn.From.Provider == name {
+				rl, ok := rn.From.Spec.Operator.(fromprovider.RateLimited)
+				if !ok {
+					return errors.Errorf("settings: %s provider doesn't support rate limits", name)
+				}
+				rl.SetRateLimiter(limiter)
+			}
+			for _, to := range rn.To {
+				if to.Provider != name {
+					continue
+				}
+				rl, ok := to.Spec.Operator.(toprovider.RateLimited)
+				if !ok {
+					return errors.Errorf("settings: %s provider doesn't support rate limits", name)
+				}
+				rl.SetRateLimiter(limiter)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grezar/revolver/notify"
+	"github.com/grezar/revolver/provider/from/awsiamuser"
+	"github.com/grezar/revolver/provider/to/tfe"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig(strings.NewReader(`
+settings:
+  rateLimit: 2
+  maxConcurrency: 4
+  providers:
+    Tfe:
+      rateLimit: 10
+      maxConcurrency: 1
+rotations:
+  - name: a
+    from:
+      provider: AWSIAMUser
+      spec:
+        accountId: 111
+        username: a
+    to:
+      - provider: Tfe
+        spec:
+          organization: org
+          workspace: ws1
+  - name: b
+    from:
+      provider: AWSIAMUser
+      spec:
+        accountId: 111
+        username: b
+    to:
+      - provider: Tfe
+        spec:
+          organization: org
+          workspace: ws2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Settings.RateLimit != 2 || cfg.Settings.MaxConcurrency != 4 {
+		t.Errorf("settings = %+v", cfg.Settings)
+	}
+	if len(cfg.Rotations) != 2 {
+		t.Fatalf("len(rotations) = %d, want 2", len(cfg.Rotations))
+	}
+
+	// A list of rotations without settings uses the default limiters of the
+	// providers.
+	rotations, err := LoadRotations(strings.NewReader(`
+- name: c
+  from:
+    provider: AWSIAMUser
+    spec:
+      accountId: 111
+      username: c
+  to:
+    - provider: Tfe
+      spec:
+        organization: org
+        workspace: ws3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultIAM := rotations[0].From.Spec.Operator.(*awsiamuser.Spec).RateLimit
+	defaultTfe := rotations[0].To[0].Spec.Operator.(*tfe.Spec).RateLimit
+
+	// The operators of a provider with a rate limit share a limiter.
+	tfe1 := cfg.Rotations[0].To[0].Spec.Operator.(*tfe.Spec).RateLimit
+	tfe2 := cfg.Rotations[1].To[0].Spec.Operator.(*tfe.Spec).RateLimit
+	if tfe1 != tfe2 {
+		t.Error("the operators of Tfe don't share the rate limiter")
+	}
+	if tfe1 == defaultTfe {
+		t.Error("the rate limiter of Tfe is not configured")
+	}
+	if iam := cfg.Rotations[0].From.Spec.Operator.(*awsiamuser.Spec).RateLimit; iam != defaultIAM {
+		t.Error("the rate limiter of AWSIAMUser was changed")
+	}
+}
+
+func TestLoadConfig_Notifications(t *testing.T) {
+	cfg, err := LoadConfig(strings.NewReader(`
+notifications:
+  - on: always
+    slack:
+      webhookURL: ${SLACK_WEBHOOK_URL}
+  - smtp:
+      host: localhost
+      from: revolver@example.com
+      to: [oncall@example.com]
+      subject: "{{ .Headline }}"
+rotations:
+  - name: a
+    from:
+      provider: Stdin
+      spec: {}
+    to:
+      - provider: Stdout
+        spec: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Notifications) != 2 {
+		t.Fatalf("len(notifications) = %d, want 2", len(cfg.Notifications))
+	}
+	if n := cfg.Notifications[0]; n.On != notify.Always || n.Slack == nil || n.Slack.WebhookURL != "${SLACK_WEBHOOK_URL}" {
+		t.Errorf("notifications[0] = %+v", n)
+	}
+	if n := cfg.Notifications[1]; n.On != "" || n.SMTP == nil || n.SMTP.To[0] != "oncall@example.com" {
+		t.Errorf("notifications[1] = %+v", n)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	rotations := `
+rotations:
+  - name: a
+    from:
+      provider: Stdin
+      spec: {}
+    to:
+      - provider: Stdout
+        spec: {}
+`
+	tests := []struct {
+		name     string
+		settings string
+		wantErr  string
+	}{
+		{
+			name:     "Negative rate limit",
+			settings: "settings:\n  rateLimit: -1\n",
+			wantErr:  "settings: rateLimit must not be negative: -1",
+		},
+		{
+			name:     "Negative max concurrency of a provider",
+			settings: "settings:\n  providers:\n    Stdout:\n      maxConcurrency: -1\n",
+			wantErr:  "settings: maxConcurrency of Stdout must not be negative: -1",
+		},
+		{
+			name:     "Unknown provider",
+			settings: "settings:\n  providers:\n    Unknown:\n      rateLimit: 1\n",
+			wantErr:  "settings: unknown provider: Unknown",
+		},
+		{
+			name:     "Provider without rate limits",
+			settings: "settings:\n  providers:\n    Stdout:\n      rateLimit: 1\n",
+			wantErr:  "settings: Stdout provider doesn't support rate limits",
+		},
+		{
+			name:     "Notification without a sink",
+			settings: "notifications:\n  - on: always\n",
+			wantErr:  "notifications[0]: exactly one of slack, webhook and smtp must be set",
+		},
+		{
+			name:     "Unknown trigger of a notification",
+			settings: "notifications:\n  - on: sometimes\n    webhook:\n      url: http://localhost\n",
+			wantErr:  `notifications[0]: unsupported trigger "sometimes". Only "failure", "success" or "always" are available`,
+		},
+		{
+			name:     "Notification template with an unknown value",
+			settings: "notifications:\n  - slack:\n      webhookURL: http://localhost\n      text: '{{ .Input }}'\n",
+			wantErr:  "notifications[0]: slack: unknown secret Input, available secrets are DryRun, Failed, Headline, JSON, Markdown, Status, Summary, Table",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(strings.NewReader(tt.settings + rotations))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,28 +3,127 @@ package schema
 import (
 	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	fromprovider "github.com/grezar/revolver/provider/from"
 	toprovider "github.com/grezar/revolver/provider/to"
 
-	"github.com/goccy/go-yaml"
 	"github.com/pkg/errors"
+	str2duration "github.com/xhit/go-str2duration/v2"
 )
 
 // LoadRotations loads YAML and extracts rotations
 func LoadRotations(r io.Reader) ([]*Rotation, error) {
-	var rotations []*Rotation
-	d := yaml.NewDecoder(r, yaml.UseOrderedMap(), yaml.Strict())
-	if err := d.Decode(&rotations); err != nil {
-		return nil, errors.Wrap(err, "failed to decode YAML")
+	cfg, err := LoadConfig(r)
+	if err != nil {
+		return nil, err
 	}
-	return rotations, nil
+	return cfg.Rotations, nil
+}
+
+// validateDependencies checks the rotations depended on exist and there is no
+// cycle in the dependencies.
+func validateDependencies(rotations []*Rotation) error {
+	byName := make(map[string]*Rotation)
+	duplicated := make(map[string]bool)
+	for _, rn := range rotations {
+		if _, ok := byName[rn.Name]; ok {
+			duplicated[rn.Name] = true
+		}
+		byName[rn.Name] = rn
+	}
+	for _, rn := range rotations {
+		for _, dep := range rn.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return errors.Errorf("%s depends on an unknown rotation: %s", rn.Name, dep)
+			}
+			if duplicated[dep] {
+				return errors.Errorf("%s depends on %s, whose name is not unique", rn.Name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := make(map[string]int)
+	var path []string
+	var visit func(rn *Rotation) error
+	visit = func(rn *Rotation) error {
+		switch states[rn.Name] {
+		case visiting:
+			cycle := []string{rn.Name}
+			for i := len(path) - 1; path[i] != rn.Name; i-- {
+				cycle = append([]string{path[i]}, cycle...)
+			}
+			cycle = append([]string{rn.Name}, cycle...)
+			return errors.Errorf("circular dependency between rotations: %s", strings.Join(cycle, " -> "))
+		case visited:
+			return nil
+		}
+		states[rn.Name] = visiting
+		path = append(path, rn.Name)
+		for _, dep := range rn.DependsOn {
+			if err := visit(byName[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[rn.Name] = visited
+		return nil
+	}
+	for _, rn := range rotations {
+		if err := visit(rn); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type Rotation struct {
 	Name string `yaml:"name"`
 	From From   `yaml:"from"`
 	To   []*To  `yaml:"to"`
+	// Transactional rolls back the new secret and the destinations that were
+	// already updated when any of the required destinations fails.
+	Transactional bool `yaml:"transactional"`
+	// GracePeriod enables a staged rotation. The previous secret is kept
+	// after the new one has been distributed, deactivated once the grace
+	// period has passed and deleted in a later run.
+	GracePeriod string `yaml:"gracePeriod"`
+	// Verify checks the new secret works after it has been distributed and
+	// before the previous one is cleaned up.
+	Verify bool `yaml:"verify"`
+	// DependsOn is the names of the rotations which must complete before this
+	// rotation starts. If any of them fails, this rotation is skipped.
+	DependsOn []string `yaml:"dependsOn"`
+	// Labels are used to select rotations to run.
+	Labels map[string]string `yaml:"labels"`
+	// Schedule is when serve runs the rotation, either a cron expression or
+	// an interval like "every 30d".
+	Schedule string `yaml:"schedule"`
+	// Timeout is how long the rotation may take. Once it has passed, the
+	// rotation stops before the next step of a provider.
+	Timeout Duration `yaml:"timeout"`
+	// Retry is the retry policy of the providers of the rotation.
+	Retry *Retry `yaml:"retry"`
+}
+
+// Retry configures how calls to a provider which failed with transient errors,
+// like rate limiting and server errors, are retried. The unset fields fall back
+// to the retry block of the rotation, and then to the defaults.
+type Retry struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	MaxAttempts int `yaml:"maxAttempts"`
+	// InitialBackoff is the wait before the first retry, which doubles on each
+	// retry up to MaxBackoff.
+	InitialBackoff Duration `yaml:"initialBackoff"`
+	MaxBackoff     Duration `yaml:"maxBackoff"`
+	// Jitter randomizes the waits between zero and the backoff.
+	Jitter *bool `yaml:"jitter"`
 }
 
 type FromUnmarshaler From
@@ -32,6 +131,9 @@ type FromUnmarshaler From
 type From struct {
 	Provider string           `yaml:"provider"`
 	Spec     FromProviderSpec `yaml:"spec"`
+	// Timeout is how long each call to the provider may take.
+	Timeout Duration `yaml:"timeout"`
+	Retry   *Retry   `yaml:"retry"`
 }
 
 type ToUnmarshaler To
@@ -39,6 +141,33 @@ type ToUnmarshaler To
 type To struct {
 	Provider string         `yaml:"provider"`
 	Spec     ToProviderSpec `yaml:"spec"`
+	// Optional destinations don't trigger a rollback of a transactional
+	// rotation when they fail.
+	Optional bool `yaml:"optional"`
+	// Timeout is how long each call to the provider may take.
+	Timeout Duration `yaml:"timeout"`
+	Retry   *Retry   `yaml:"retry"`
+}
+
+// Duration is a duration written in the same format as expiration, like "30s"
+// or "1d".
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler interface
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	v, err := str2duration.ParseDuration(s)
+	if err != nil {
+		return errors.Wrapf(err, "invalid duration %q", s)
+	}
+	if v < 0 {
+		return errors.Errorf("invalid duration %q: must not be negative", s)
+	}
+	*d = Duration(v)
+	return nil
 }
 
 type FromProviderSpec struct {
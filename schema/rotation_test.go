@@ -1,8 +1,11 @@
 package schema
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	_ "github.com/grezar/revolver/provider/from/awsiamuser"
 	_ "github.com/grezar/revolver/provider/from/stdin"
@@ -31,3 +34,102 @@ func TestLoadRotations(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadRotations_Dependencies(t *testing.T) {
+	rotation := func(name string, dependsOn ...string) string {
+		return fmt.Sprintf(`
+- name: %s
+  dependsOn: [%s]
+  from:
+    provider: Stdin
+    spec: {}
+  to:
+    - provider: Stdout
+      spec: {}
+`, name, strings.Join(dependsOn, ", "))
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "Valid dependencies",
+			yaml: rotation("a") + rotation("b", "a") + rotation("c", "a", "b"),
+		},
+		{
+			name:    "Unknown rotation",
+			yaml:    rotation("a", "b"),
+			wantErr: "a depends on an unknown rotation: b",
+		},
+		{
+			name:    "Rotation which depends on itself",
+			yaml:    rotation("a", "a"),
+			wantErr: "circular dependency between rotations: a -> a",
+		},
+		{
+			name:    "Circular dependency",
+			yaml:    rotation("a") + rotation("b", "a", "d") + rotation("c", "b") + rotation("d", "c"),
+			wantErr: "circular dependency between rotations: b -> d -> c -> b",
+		},
+		{
+			name:    "Ambiguous dependency",
+			yaml:    rotation("a") + rotation("a") + rotation("b", "a"),
+			wantErr: "b depends on a, whose name is not unique",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadRotations(strings.NewReader(tt.yaml))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("LoadRotations() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("LoadRotations() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadRotations_Timeouts(t *testing.T) {
+	rotations, err := LoadRotations(strings.NewReader(`
+- name: a
+  timeout: 10m
+  from:
+    provider: Stdin
+    timeout: 30s
+    spec: {}
+  to:
+    - provider: Stdout
+      timeout: 1d
+      spec: {}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rn := rotations[0]
+	if got, want := time.Duration(rn.Timeout), 10*time.Minute; got != want {
+		t.Errorf("rotation timeout = %s, want %s", got, want)
+	}
+	if got, want := time.Duration(rn.From.Timeout), 30*time.Second; got != want {
+		t.Errorf("from timeout = %s, want %s", got, want)
+	}
+	if got, want := time.Duration(rn.To[0].Timeout), 24*time.Hour; got != want {
+		t.Errorf("to timeout = %s, want %s", got, want)
+	}
+
+	_, err = LoadRotations(strings.NewReader(`
+- name: a
+  timeout: soon
+  from:
+    provider: Stdin
+    spec: {}
+`))
+	if err == nil || !strings.Contains(err.Error(), `invalid duration "soon"`) {
+		t.Errorf("LoadRotations() error = %v, want invalid duration", err)
+	}
+}
@@ -0,0 +1,162 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+	fromprovider "github.com/grezar/revolver/provider/from"
+	toprovider "github.com/grezar/revolver/provider/to"
+	"github.com/grezar/revolver/schedule"
+	"github.com/grezar/revolver/validation"
+	str2duration "github.com/xhit/go-str2duration/v2"
+)
+
+// Problem is a problem in the configuration.
+type Problem struct {
+	// Line and Column are the position of the problem in the YAML, or zero if
+	// unknown.
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// ValidationError is the problems found in the configuration.
+type ValidationError struct {
+	Problems []*Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("%d problems found in the configuration:", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validate checks the specs of the providers and the schedules and the grace
+// periods of the rotations, and returns all problems found with their positions in b.
+// rotationsPath is the YAML path of the list of the rotations.
+func validate(b []byte, cfg *Config, rotationsPath string) error {
+	f, err := parser.ParseBytes(b, 0)
+	if err != nil {
+		return err
+	}
+
+	var problems []*Problem
+	report := func(path, message string) {
+		p := &Problem{Message: message}
+		if node := lookup(f, path); node != nil {
+			pos := position(node)
+			p.Line, p.Column = pos.Line, pos.Column
+		}
+		problems = append(problems, p)
+	}
+
+	for i, rn := range cfg.Rotations {
+		path := fmt.Sprintf("%s[%d]", rotationsPath, i)
+		if rn.Schedule != "" {
+			if _, err := schedule.Parse(rn.Schedule); err != nil {
+				report(path+".schedule", fmt.Sprintf("%s: %v", rn.Name, err))
+			}
+		}
+		var gracePeriod time.Duration
+		if rn.GracePeriod != "" {
+			var err error
+			if gracePeriod, err = str2duration.ParseDuration(rn.GracePeriod); err != nil {
+				report(path+".gracePeriod", fmt.Sprintf("%s: gracePeriod: %v", rn.Name, err))
+			}
+		}
+
+		// The secrets the templates may refer to are unknown without a from
+		// provider, so the destinations are only checked for their fields.
+		var keys []string
+		p := fromprovider.Get(rn.From.Provider)
+		switch {
+		case rn.From.Provider == "":
+			report(path+".from", fmt.Sprintf("%s: from is required", rn.Name))
+		case p == nil || rn.From.Spec.Operator == nil:
+			report(path+".from.provider", fmt.Sprintf("%s: unknown provider %s", rn.Name, rn.From.Provider))
+		default:
+			errs := validation.Struct(rn.From.Spec.Operator)
+			if v, ok := rn.From.Spec.Operator.(fromprovider.Validator); ok {
+				errs = append(errs, v.Validate()...)
+			}
+			for _, e := range errs {
+				report(fieldPath(path+".from.spec", e), fmt.Sprintf("%s: From/%s: %s", rn.Name, rn.From.Provider, e))
+			}
+			// The previous secret would expire before it is retired.
+			if e, ok := rn.From.Spec.Operator.(fromprovider.Expirer); ok && gracePeriod > 0 {
+				if expiration, err := e.ExpiresAfter(); err == nil && gracePeriod >= expiration {
+					report(path+".gracePeriod", fmt.Sprintf("%s: gracePeriod: %s must be shorter than the expiration of From/%s", rn.Name, rn.GracePeriod, rn.From.Provider))
+				}
+			}
+			keys = p.SecretKeys()
+		}
+
+		for j, to := range rn.To {
+			errs := validation.Struct(to.Spec.Operator)
+			if v, ok := to.Spec.Operator.(toprovider.Validator); ok && p != nil {
+				errs = append(errs, v.Validate(keys)...)
+			}
+			for _, e := range errs {
+				report(fieldPath(fmt.Sprintf("%s.to[%d].spec", path, j), e), fmt.Sprintf("%s: To/%s: %s", rn.Name, to.Provider, e))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// fieldPath returns the YAML path of the field of the error in the spec at
+// spec.
+func fieldPath(spec string, e *validation.FieldError) string {
+	if e.Field == "" {
+		return spec
+	}
+	return spec + "." + e.Field
+}
+
+// lookup returns the node at the path, or the closest ancestor which exists
+// if the node doesn't, e.g. for a required field which is missing.
+func lookup(f *ast.File, path string) ast.Node {
+	for path != "$" {
+		if p, err := yaml.PathString(path); err == nil {
+			if node, err := p.FilterFile(f); err == nil && node != nil {
+				return node
+			}
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return nil
+}
+
+// position returns where the node starts. The token of a mapping is its first
+// colon, so the position of its first key is used instead.
+func position(node ast.Node) *token.Position {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		if len(n.Values) > 0 {
+			return n.Values[0].Key.GetToken().Position
+		}
+	case *ast.MappingValueNode:
+		return n.Key.GetToken().Position
+	}
+	return node.GetToken().Position
+}
@@ -0,0 +1,71 @@
+package schema
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadRotations_Invalid(t *testing.T) {
+	f, err := os.Open("./../testdata/invalid.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	_, err = LoadRotations(f)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("LoadRotations() error = %v, want *ValidationError", err)
+	}
+
+	want := []*Problem{
+		{Line: 9, Column: 7, Message: "Nil Provider Spec: To/AWSSharedCredentials: path: is required"},
+		{Line: 9, Column: 7, Message: "Nil Provider Spec: To/AWSSharedCredentials: profile: is required"},
+		{Line: 12, Column: 13, Message: `Invalid Specs: invalid schedule "every fortnight": time: invalid duration "fortnight"`},
+		{Line: 18, Column: 19, Message: `Invalid Specs: From/AWSIAMUser: expiration: time: unknown unit " fortnight" in duration "1 fortnight"`},
+		{Line: 26, Column: 20, Message: `Invalid Specs: To/Tfe: secrets[0].value: template: :1: unexpected "}" in operand`},
+		{Line: 30, Column: 23, Message: `Invalid Specs: To/Tfe: secrets[1].category: unsupported category "environment". Only "env" or "terraform" are available`},
+		{Line: 38, Column: 9, Message: "Unknown Secrets: To/AWSSharedCredentials: aws_access_key_id: unknown secret AWSAccessKeyID, available secrets are Input"},
+		{Line: 38, Column: 9, Message: "Unknown Secrets: To/AWSSharedCredentials: aws_secret_access_key: unknown secret AWSSecretAccessKey, available secrets are Input"},
+		{Line: 42, Column: 17, Message: "Unknown Secrets: To/Stdout: output: unknown secret AWSAccessKeyId, available secrets are Input"},
+		{Line: 45, Column: 16, Message: `Invalid Grace Period: gracePeriod: time: unknown unit " fortnight" in duration "1 fortnight"`},
+		{Line: 51, Column: 3, Message: "Missing From: from is required"},
+		{Line: 58, Column: 16, Message: "Grace Period Beyond Expiration: gracePeriod: 30d must be shorter than the expiration of From/AWSIAMUser"},
+	}
+	if diff := cmp.Diff(want, verr.Problems); diff != "" {
+		t.Errorf("problems mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadConfig_InvalidPositions(t *testing.T) {
+	_, err := LoadConfig(strings.NewReader(`
+rotations:
+  - name: a
+    from:
+      provider: Stdin
+    to:
+      - provider: CircleCI
+        spec:
+          projectVariables:
+            - project: gh/org/prj
+              variables:
+                - name: FOO
+                  value: "{{ .Input"
+`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("LoadConfig() error = %v, want *ValidationError", err)
+	}
+
+	want := []*Problem{
+		{Line: 9, Column: 11, Message: "a: To/CircleCI: owner: is required"},
+		{Line: 13, Column: 26, Message: "a: To/CircleCI: projectVariables[0].variables[0].value: template: :1: unclosed action"},
+	}
+	if diff := cmp.Diff(want, verr.Problems); diff != "" {
+		t.Errorf("problems mismatch (-want +got):\n%s", diff)
+	}
+}
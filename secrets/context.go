@@ -8,10 +8,39 @@ type keySecrets struct{}
 
 type Secrets map[string]string
 
+// WithSecrets returns a context with the secrets for the templates. The
+// secrets are redacted from the output once registered with Register or a
+// Scope.
 func WithSecrets(ctx context.Context, s Secrets) context.Context {
 	return context.WithValue(ctx, keySecrets{}, s)
 }
 
+// WithPlaceholders returns a context with a placeholder like <Key> for each of
+// the keys, so that the templates can be rendered in dry-run mode, when no
+// secrets are issued. The placeholders are not redacted.
+func WithPlaceholders(ctx context.Context, keys []string) context.Context {
+	s := make(Secrets, len(keys))
+	for _, k := range keys {
+		s[k] = "<" + k + ">"
+	}
+	return context.WithValue(ctx, keySecrets{}, s)
+}
+
+type keyScope struct{}
+
+// WithScope returns a context in which the secrets derived in the templates,
+// like the password of sesSmtpPassword, are registered to sc.
+func WithScope(ctx context.Context, sc *Scope) context.Context {
+	return context.WithValue(ctx, keyScope{}, sc)
+}
+
+// getScope returns the scope of ctx, or nil to register the secrets for the
+// lifetime of the process.
+func getScope(ctx context.Context) *Scope {
+	sc, _ := ctx.Value(keyScope{}).(*Scope)
+	return sc
+}
+
 func GetSecrets(ctx context.Context) Secrets {
 	ss, ok := ctx.Value(keySecrets{}).(Secrets)
 	if ok {
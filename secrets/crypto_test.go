@@ -0,0 +1,42 @@
+package secrets
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	key := bytes.Repeat([]byte("k"), KeySize)
+	s := Secrets{
+		"AWSAccessKeyID":     "SAMPLE_ID",
+		"AWSSecretAccessKey": "SAMPLE_SECRET",
+	}
+
+	ciphertext, err := Encrypt(key, s)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if bytes.Contains(ciphertext, []byte("SAMPLE_SECRET")) {
+		t.Errorf("Encrypt() = %q, contains the plaintext", ciphertext)
+	}
+
+	got, err := Decrypt(key, ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("Decrypt() = %v, want %v", got, s)
+	}
+	// The caller registers the secrets to the scope of its run.
+	if got := Redact("SAMPLE_SECRET"); got != "SAMPLE_SECRET" {
+		t.Errorf("Redact() = %q, want the decrypted secret as is", got)
+	}
+
+	if _, err := Decrypt(bytes.Repeat([]byte("x"), KeySize), ciphertext); err == nil {
+		t.Errorf("Decrypt() with a wrong key error = nil, want error")
+	}
+	if _, err := Encrypt([]byte("short"), s); err == nil {
+		t.Errorf("Encrypt() with a short key error = nil, want error")
+	}
+}
@@ -0,0 +1,76 @@
+package secrets
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// funcs are the functions available in the templates of all to providers.
+var funcs = template.FuncMap{
+	"b64enc":          b64enc,
+	"toJson":          toJSON,
+	"sha256sum":       sha256sum,
+	"urlquery":        url.QueryEscape,
+	"default":         defaultValue,
+	"trim":            strings.TrimSpace,
+	"upper":           strings.ToUpper,
+	"lower":           strings.ToLower,
+	"indent":          indent,
+	"now":             time.Now,
+	"date":            date,
+	"sesSmtpPassword": sesSMTPPasswordFunc(nil),
+}
+
+func b64enc(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func sha256sum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// defaultValue returns d if v is empty, e.g. `{{ .Input | default "none" }}`.
+func defaultValue(d, v interface{}) interface{} {
+	if v == nil {
+		return d
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Map, reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			return d
+		}
+	default:
+		if rv.IsZero() {
+			return d
+		}
+	}
+	return v
+}
+
+// indent indents every line of s by n spaces.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// date formats t with the layout of the time package, like "2006-01-02".
+func date(layout string, t time.Time) string {
+	return t.Format(layout)
+}
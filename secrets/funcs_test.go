@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExecuteTemplate_Funcs(t *testing.T) {
+	ctx := WithSecrets(context.Background(), Secrets{
+		"AWSAccessKeyID":     "AKIA/ID",
+		"AWSSecretAccessKey": "p@ss word",
+		"Empty":              "",
+		"Padded":             "  value\n",
+	})
+
+	tests := []struct {
+		name string
+		node string
+		want string
+	}{
+		{
+			name: "b64enc",
+			node: "{{ .AWSAccessKeyID | b64enc }}",
+			want: "QUtJQS9JRA==",
+		},
+		{
+			name: "toJson",
+			node: "{{ toJson . }}",
+			want: `{"AWSAccessKeyID":"AKIA/ID","AWSSecretAccessKey":"p@ss word","Empty":"","Padded":"  value\n"}`,
+		},
+		{
+			name: "sha256sum",
+			node: "{{ sha256sum .AWSAccessKeyID }}",
+			want: "953ac8afc7aef91f587cc1d5931f9419b742b231540c6508ee38063ce8212d00",
+		},
+		{
+			name: "urlquery",
+			node: "postgres://user:{{ urlquery .AWSSecretAccessKey }}@db/app",
+			want: "postgres://user:p%40ss+word@db/app",
+		},
+		{
+			name: "default for an empty value",
+			node: `{{ .Empty | default "none" }}`,
+			want: "none",
+		},
+		{
+			name: "default for a value",
+			node: `{{ .AWSAccessKeyID | default "none" }}`,
+			want: "AKIA/ID",
+		},
+		{
+			name: "trim",
+			node: "[{{ trim .Padded }}]",
+			want: "[value]",
+		},
+		{
+			name: "upper and lower",
+			node: "{{ upper .AWSAccessKeyID }} {{ lower .AWSAccessKeyID }}",
+			want: "AKIA/ID akia/id",
+		},
+		{
+			name: "indent",
+			node: "key:\n{{ indent 2 \"a: 1\\nb: 2\" }}",
+			want: "key:\n  a: 1\n  b: 2",
+		},
+		{
+			name: "now and date",
+			node: `{{ now | date "2006" }}`,
+			want: time.Now().Format("2006"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExecuteTemplate(ctx, tt.node)
+			if err != nil {
+				t.Fatalf("ExecuteTemplate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExecuteTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultValue(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want interface{}
+	}{
+		{name: "nil", v: nil, want: "d"},
+		{name: "empty string", v: "", want: "d"},
+		{name: "empty map", v: map[string]string{}, want: "d"},
+		{name: "zero", v: 0, want: "d"},
+		{name: "false", v: false, want: "d"},
+		{name: "string", v: "v", want: "v"},
+		{name: "number", v: 1, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultValue("d", tt.v); got != tt.want {
+				t.Errorf("defaultValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDate(t *testing.T) {
+	tm := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	if got, want := date("2006-01-02T15:04:05Z07:00", tm), "2022-03-04T05:06:07Z"; got != want {
+		t.Errorf("date() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,231 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted is shown in place of the secrets.
+const Redacted = "[REDACTED]"
+
+// minRedactedLength is the length under which values are not redacted, since
+// they would match everywhere in the output.
+const minRedactedLength = 4
+
+// Redactor removes the registered secrets from text.
+type Redactor struct {
+	mu sync.RWMutex
+	// values counts the registrations of each value, so that a value
+	// registered twice is redacted until it has been unregistered twice.
+	values   map[string]int
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a Redactor without any secret registered.
+func NewRedactor() *Redactor {
+	return &Redactor{values: make(map[string]int)}
+}
+
+// Register adds the values of s to the secrets to redact, along with their
+// common encodings.
+func (r *Redactor) Register(s Secrets) {
+	values := make([]string, 0, len(s))
+	for _, v := range s {
+		values = append(values, v)
+	}
+	r.RegisterValues(values...)
+}
+
+// RegisterValues adds the values to the secrets to redact, along with their
+// common encodings.
+func (r *Redactor) RegisterValues(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	added := false
+	for _, v := range values {
+		if len(v) < minRedactedLength {
+			continue
+		}
+		for _, e := range encodings(v) {
+			if r.values[e] == 0 {
+				added = true
+			}
+			r.values[e]++
+		}
+	}
+	if added {
+		r.update()
+	}
+}
+
+// Unregister removes the values of s from the secrets to redact.
+func (r *Redactor) Unregister(s Secrets) {
+	values := make([]string, 0, len(s))
+	for _, v := range s {
+		values = append(values, v)
+	}
+	r.UnregisterValues(values...)
+}
+
+// UnregisterValues removes the values from the secrets to redact. A value
+// registered more than once is still redacted until it has been unregistered
+// as many times.
+func (r *Redactor) UnregisterValues(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := false
+	for _, v := range values {
+		if len(v) < minRedactedLength {
+			continue
+		}
+		for _, e := range encodings(v) {
+			if r.values[e] == 0 {
+				continue
+			}
+			r.values[e]--
+			if r.values[e] == 0 {
+				delete(r.values, e)
+				removed = true
+			}
+		}
+	}
+	if removed {
+		r.update()
+	}
+}
+
+// update rebuilds the replacer from the registered values.
+func (r *Redactor) update() {
+	if len(r.values) == 0 {
+		r.replacer = nil
+		return
+	}
+
+	// The longer values are replaced first so that a value containing
+	// another is redacted as a whole.
+	all := make([]string, 0, len(r.values))
+	for v := range r.values {
+		all = append(all, v)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if len(all[i]) != len(all[j]) {
+			return len(all[i]) > len(all[j])
+		}
+		return all[i] < all[j]
+	})
+	oldnew := make([]string, 0, len(all)*2)
+	for _, v := range all {
+		oldnew = append(oldnew, v, Redacted)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+// Redact returns s with the registered secrets replaced by Redacted.
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// encodings returns v in the encodings in which it is likely to appear in
+// errors and logs, like in a request body echoed by an API.
+func encodings(v string) []string {
+	es := []string{
+		v,
+		base64.StdEncoding.EncodeToString([]byte(v)),
+		base64.RawStdEncoding.EncodeToString([]byte(v)),
+		base64.URLEncoding.EncodeToString([]byte(v)),
+		base64.RawURLEncoding.EncodeToString([]byte(v)),
+		url.QueryEscape(v),
+		url.PathEscape(v),
+		hex.EncodeToString([]byte(v)),
+	}
+	if b, err := json.Marshal(v); err == nil {
+		es = append(es, strings.Trim(string(b), `"`))
+	}
+	return es
+}
+
+var defaultRedactor = NewRedactor()
+
+// Register adds the values of s to the secrets redacted by Redact.
+func Register(s Secrets) {
+	defaultRedactor.Register(s)
+}
+
+// Scope registers secrets to be redacted by Redact until it is closed, so that
+// a long-running process like serve doesn't keep the secrets of every run. A
+// nil Scope registers them for the lifetime of the process.
+type Scope struct {
+	mu     sync.Mutex
+	values []string
+}
+
+// Register adds the values of s to the secrets redacted by Redact until the
+// scope is closed.
+func (sc *Scope) Register(s Secrets) {
+	values := make([]string, 0, len(s))
+	for _, v := range s {
+		values = append(values, v)
+	}
+	sc.RegisterValues(values...)
+}
+
+// RegisterValues adds the values to the secrets redacted by Redact until the
+// scope is closed.
+func (sc *Scope) RegisterValues(values ...string) {
+	defaultRedactor.RegisterValues(values...)
+	if sc == nil {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.values = append(sc.values, values...)
+}
+
+// Close unregisters the secrets registered in the scope. A secret registered
+// outside of the scope as well is still redacted.
+func (sc *Scope) Close() {
+	if sc == nil {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	defaultRedactor.UnregisterValues(sc.values...)
+	sc.values = nil
+}
+
+// Redact returns s with the secrets issued in this process replaced by
+// Redacted.
+func Redact(s string) string {
+	return defaultRedactor.Redact(s)
+}
+
+// RedactingWriter returns a writer which redacts the secrets issued in this
+// process from what is written to w. Each write must contain whole secrets,
+// as log.Logger does by writing a line at a time.
+func RedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"testing"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	const secret = "wJalrXUtnFEMI/K7MDENG+bPxRfiCY\"KEY"
+	r := NewRedactor()
+	r.Register(Secrets{
+		"AWSAccessKeyID":     "AKIAEXAMPLE",
+		"AWSSecretAccessKey": secret,
+		"Short":              "abc",
+	})
+
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "Plain",
+			s:    "invalid key AKIAEXAMPLE: " + secret,
+			want: "invalid key [REDACTED]: [REDACTED]",
+		},
+		{
+			name: "Base64",
+			s:    "body: " + base64.StdEncoding.EncodeToString([]byte(secret)),
+			want: "body: [REDACTED]",
+		},
+		{
+			name: "Base64 URL",
+			s:    "body: " + base64.RawURLEncoding.EncodeToString([]byte(secret)),
+			want: "body: [REDACTED]",
+		},
+		{
+			name: "URL query",
+			s:    "POST /?secret=" + url.QueryEscape(secret),
+			want: "POST /?secret=[REDACTED]",
+		},
+		{
+			name: "JSON",
+			s:    `{"value":"wJalrXUtnFEMI/K7MDENG+bPxRfiCY\"KEY"}`,
+			want: `{"value":"[REDACTED]"}`,
+		},
+		{
+			name: "Hex",
+			s:    "0x" + hex.EncodeToString([]byte(secret)),
+			want: "0x[REDACTED]",
+		},
+		{
+			name: "Short values are not redacted",
+			s:    "abc",
+			want: "abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Redact(tt.s); got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactor_Redact_Nothing(t *testing.T) {
+	if got := NewRedactor().Redact("AKIAEXAMPLE"); got != "AKIAEXAMPLE" {
+		t.Errorf("Redact() = %q, want the input as is", got)
+	}
+}
+
+func TestRedactor_Unregister(t *testing.T) {
+	r := NewRedactor()
+	r.Register(Secrets{"A": "registered twice", "B": "registered once"})
+	r.RegisterValues("registered twice")
+
+	r.Unregister(Secrets{"A": "registered twice", "B": "registered once"})
+	if got, want := r.Redact("registered twice, registered once"), "[REDACTED], registered once"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+	r.UnregisterValues("registered twice", "never registered")
+	if got, want := r.Redact("registered twice"), "registered twice"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+}
+
+func TestScope(t *testing.T) {
+	Register(Secrets{"Input": "scope process secret"})
+	sc := &Scope{}
+	sc.Register(Secrets{"Input": "scope run secret"})
+	sc.Register(Secrets{"Input": "scope process secret"})
+	if got, want := Redact("scope run secret, scope process secret"), "[REDACTED], [REDACTED]"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+
+	// The secrets registered outside of the scope are still redacted.
+	sc.Close()
+	if got, want := Redact("scope run secret, scope process secret"), "scope run secret, [REDACTED]"; got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+}
+
+func TestRedactingWriter(t *testing.T) {
+	Register(Secrets{"Input": "redacting writer secret"})
+
+	var buf bytes.Buffer
+	w := RedactingWriter(&buf)
+	in := "failed: redacting writer secret\n"
+	n, err := w.Write([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(in) {
+		t.Errorf("Write() = %d, want %d", n, len(in))
+	}
+	if got, want := buf.String(), "failed: [REDACTED]\n"; got != want {
+		t.Errorf("written %q, want %q", got, want)
+	}
+}
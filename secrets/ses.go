@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// The constants of the algorithm to derive an SES SMTP password from a secret
+// access key. See
+// https://docs.aws.amazon.com/ses/latest/dg/smtp-credentials.html
+const (
+	sesDate     = "11111111"
+	sesService  = "ses"
+	sesTerminal = "aws4_request"
+	sesMessage  = "SendRawEmail"
+	sesVersion  = 0x04
+)
+
+// sesSMTPPassword derives the SMTP password of Amazon SES in region from the
+// secret access key of an IAM user, e.g.
+// `{{ sesSmtpPassword .AWSSecretAccessKey "eu-west-1" }}`.
+func sesSMTPPassword(secretAccessKey, region string) (string, error) {
+	if secretAccessKey == "" {
+		return "", errors.New("sesSmtpPassword: the secret access key is empty")
+	}
+	if region == "" {
+		return "", errors.New("sesSmtpPassword: the region is empty")
+	}
+
+	signature := []byte("AWS4" + secretAccessKey)
+	for _, s := range []string{sesDate, region, sesService, sesTerminal, sesMessage} {
+		signature = sign(signature, s)
+	}
+	return base64.StdEncoding.EncodeToString(append([]byte{sesVersion}, signature...)), nil
+}
+
+// sesSMTPPasswordFunc returns sesSMTPPassword for the templates, which
+// registers the password to sc to be redacted.
+func sesSMTPPasswordFunc(sc *Scope) func(secretAccessKey, region string) (string, error) {
+	return func(secretAccessKey, region string) (string, error) {
+		password, err := sesSMTPPassword(secretAccessKey, region)
+		if err != nil {
+			return "", err
+		}
+		sc.RegisterValues(password)
+		return password, nil
+	}
+}
+
+func sign(key []byte, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
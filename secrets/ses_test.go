@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSESSMTPPassword(t *testing.T) {
+	const key = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	tests := []struct {
+		name            string
+		secretAccessKey string
+		region          string
+		want            string
+		wantErr         bool
+	}{
+		{
+			name:            "eu-west-1",
+			secretAccessKey: key,
+			region:          "eu-west-1",
+			want:            "BMW5RDrXmmVs0lV7GpI4oLkHXpZ4stDsk6q91z1g38Pk",
+		},
+		{
+			name:            "us-east-1",
+			secretAccessKey: key,
+			region:          "us-east-1",
+			want:            "BLBM/9hSUELfq8Gw+rU1YcBjkOxGbhT2XG763xVLGWL9",
+		},
+		{
+			name:            "Empty secret access key",
+			secretAccessKey: "",
+			region:          "eu-west-1",
+			wantErr:         true,
+		},
+		{
+			name:            "Empty region",
+			secretAccessKey: key,
+			region:          "",
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sesSMTPPassword(tt.secretAccessKey, tt.region)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sesSMTPPassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("sesSMTPPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteTemplate_SESSMTPPassword(t *testing.T) {
+	ctx := WithSecrets(context.Background(), Secrets{
+		"AWSSecretAccessKey": "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY",
+	})
+	got, err := ExecuteTemplate(ctx, `{{ sesSmtpPassword .AWSSecretAccessKey "eu-west-1" }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "BMW5RDrXmmVs0lV7GpI4oLkHXpZ4stDsk6q91z1g38Pk"; got != want {
+		t.Errorf("ExecuteTemplate() = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteTemplate_SESSMTPPassword_Scope(t *testing.T) {
+	const password = "BLBM/9hSUELfq8Gw+rU1YcBjkOxGbhT2XG763xVLGWL9"
+	sc := &Scope{}
+	ctx := WithScope(WithSecrets(context.Background(), Secrets{
+		"AWSSecretAccessKey": "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY",
+	}), sc)
+	if _, err := ExecuteTemplate(ctx, `{{ sesSmtpPassword .AWSSecretAccessKey "us-east-1" }}`); err != nil {
+		t.Fatal(err)
+	}
+	if got := Redact(password); got != Redacted {
+		t.Errorf("Redact() = %q, want %q", got, Redacted)
+	}
+
+	// The password is no longer redacted once the scope is closed.
+	sc.Close()
+	if got := Redact(password); got != password {
+		t.Errorf("Redact() = %q, want %q", got, password)
+	}
+}
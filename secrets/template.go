@@ -2,20 +2,117 @@ package secrets
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strings"
 	"text/template"
+	"text/template/parse"
 )
 
 func ExecuteTemplate(ctx context.Context, node string) (string, error) {
-	tmpl, err := template.New("").Parse(node)
+	return render(node, GetSecrets(ctx), getScope(ctx))
+}
+
+// Render executes the template with the values of data. The values are not
+// registered to be redacted.
+func Render(node string, data Secrets) (string, error) {
+	return render(node, data, nil)
+}
+
+// render executes the template, registering the secrets derived in it to sc.
+func render(node string, data Secrets, sc *Scope) (string, error) {
+	// A misspelled key is an error rather than "<no value>" in the output.
+	tmpl, err := template.New("").Funcs(funcs).Funcs(template.FuncMap{
+		"sesSmtpPassword": sesSMTPPasswordFunc(sc),
+	}).Option("missingkey=error").Parse(node)
 	if err != nil {
 		return "", err
 	}
 
 	writer := new(strings.Builder)
-	err = tmpl.Execute(writer, GetSecrets(ctx))
+	err = tmpl.Execute(writer, data)
 	if err != nil {
 		return "", err
 	}
 	return writer.String(), nil
 }
+
+// ValidateTemplate returns an error if the template cannot be parsed or refers
+// to a secret other than keys, which are the secrets the from provider issues.
+func ValidateTemplate(node string, keys []string) error {
+	tmpl, err := template.New("").Funcs(funcs).Parse(node)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		known[k] = true
+	}
+	var unknown []string
+	for _, k := range referencedKeys(tmpl.Tree.Root, true) {
+		if !known[k] {
+			unknown = append(unknown, k)
+			known[k] = true
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	available := append([]string(nil), keys...)
+	sort.Strings(available)
+	return fmt.Errorf("unknown secret %s, available secrets are %s",
+		strings.Join(unknown, ", "), strings.Join(available, ", "))
+}
+
+// referencedKeys returns the keys of the secrets referenced in node. The dot
+// refers to the secrets only if root is true, since range and with move it.
+func referencedKeys(node parse.Node, root bool) []string {
+	var keys []string
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			keys = append(keys, referencedKeys(c, root)...)
+		}
+	case *parse.ActionNode:
+		keys = referencedKeys(n.Pipe, root)
+	case *parse.TemplateNode:
+		keys = referencedKeys(n.Pipe, root)
+	case *parse.IfNode:
+		keys = append(referencedKeys(n.Pipe, root), referencedKeys(n.List, root)...)
+		keys = append(keys, referencedKeys(n.ElseList, root)...)
+	case *parse.RangeNode:
+		keys = append(referencedKeys(n.Pipe, root), referencedKeys(n.List, false)...)
+		keys = append(keys, referencedKeys(n.ElseList, root)...)
+	case *parse.WithNode:
+		keys = append(referencedKeys(n.Pipe, root), referencedKeys(n.List, false)...)
+		keys = append(keys, referencedKeys(n.ElseList, root)...)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Cmds {
+			keys = append(keys, referencedKeys(c, root)...)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			keys = append(keys, referencedKeys(a, root)...)
+		}
+	case *parse.ChainNode:
+		keys = referencedKeys(n.Node, root)
+	case *parse.FieldNode:
+		if root {
+			keys = append(keys, n.Ident[0])
+		}
+	case *parse.VariableNode:
+		// $ is the secrets wherever the dot is.
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			keys = append(keys, n.Ident[1])
+		}
+	}
+	return keys
+}
@@ -48,6 +48,16 @@ func TestExecuteTemplate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Missing key",
+			args: args{
+				ctx: WithSecrets(context.Background(), Secrets{
+					"AWSAccessKeyID": "SAMPLE_ID",
+				}),
+				node: "{{ .AWSAccessKeyId }}",
+			},
+			wantErr: true,
+		},
 		{
 			name: "Pure string",
 			args: args{
@@ -73,3 +83,73 @@ func TestExecuteTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestRender(t *testing.T) {
+	got, err := Render("{{ .Status }}", Secrets{"Status": "render test status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "render test status"; got != want {
+		t.Errorf("Render() = %v, want %v", got, want)
+	}
+	// The values aren't registered to be redacted.
+	if got := Redact(got); got != "render test status" {
+		t.Errorf("Redact() = %v, want the value unredacted", got)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	keys := []string{"AWSSecretAccessKey", "AWSAccessKeyID"}
+	tests := []struct {
+		name    string
+		node    string
+		wantErr string
+	}{
+		{
+			name: "Known keys",
+			node: "{{ .AWSAccessKeyID }}:{{ $.AWSSecretAccessKey }}",
+		},
+		{
+			name: "Functions",
+			node: `{{ .AWSAccessKeyID | default "none" | b64enc }}`,
+		},
+		{
+			name:    "Unknown key",
+			node:    "{{ .AWSAccessKeyId }}",
+			wantErr: "unknown secret AWSAccessKeyId, available secrets are AWSAccessKeyID, AWSSecretAccessKey",
+		},
+		{
+			name:    "Unknown keys in a condition",
+			node:    "{{ if .Input }}{{ .Input }}{{ else }}{{ .Output }}{{ end }}",
+			wantErr: "unknown secret Input, Output, available secrets are AWSAccessKeyID, AWSSecretAccessKey",
+		},
+		{
+			name: "Dot moved by with",
+			node: "{{ with .AWSAccessKeyID }}{{ .Unrelated }}{{ end }}",
+		},
+		{
+			name:    "Root in range",
+			node:    "{{ range .AWSAccessKeyID }}{{ $.Input }}{{ end }}",
+			wantErr: "unknown secret Input, available secrets are AWSAccessKeyID, AWSSecretAccessKey",
+		},
+		{
+			name:    "Invalid template",
+			node:    "{{ .AWSAccessKeyID",
+			wantErr: "template: :1: unclosed action",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplate(tt.node, keys)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateTemplate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ValidateTemplate() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package revolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/schedule"
+	"github.com/grezar/revolver/schema"
+	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/state"
+)
+
+// Server runs each rotation on its own schedule. Rotations without a schedule
+// are not run.
+type Server struct {
+	path string
+	opts []Option
+	// Interval is how often the schedules are checked.
+	Interval time.Duration
+	// Parallelism is the number of providers called at the same time, or
+	// reporting.DefaultParallelism if zero.
+	Parallelism int
+
+	mu        sync.Mutex
+	runner    *Runner
+	schedules map[string]schedule.Schedule
+	// started is when the schedules were first checked. A rotation with a
+	// cron schedule which has never run is due at the first time after it.
+	started time.Time
+	// failures holds the rotations which failed in their last run, to be
+	// run again after a backoff rather than on their next schedule.
+	failures map[string]*failure
+}
+
+const (
+	// retryBackoff is the wait before a rotation which failed runs again. It
+	// doubles on each failure up to maxRetryBackoff.
+	retryBackoff    = 5 * time.Minute
+	maxRetryBackoff = 6 * time.Hour
+)
+
+// failure tells when a rotation which failed runs again.
+type failure struct {
+	count   int
+	retryAt time.Time
+}
+
+// NewServer loads the configuration from path. A state store is required to
+// remember when the rotations ran across restarts.
+func NewServer(path string, opts ...Option) (*Server, error) {
+	s := &Server{
+		path:     path,
+		opts:     opts,
+		Interval: time.Minute,
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload loads the configuration again. The current configuration is kept if
+// the new one is invalid.
+func (s *Server) Reload() error {
+	runner, err := NewRunner(s.path, false, s.opts...)
+	if err != nil {
+		return err
+	}
+	if runner.state == nil {
+		return errors.New("serve requires a state file")
+	}
+
+	schedules := make(map[string]schedule.Schedule)
+	for _, rn := range runner.rotations {
+		if rn.Schedule == "" {
+			continue
+		}
+		sc, err := schedule.Parse(rn.Schedule)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rn.Name, err)
+		}
+		schedules[rn.Name] = sc
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runner = runner
+	s.schedules = schedules
+	return nil
+}
+
+// Serve runs the rotations when they are due until ctx is canceled.
+func (s *Server) Serve(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		s.runDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// runDue runs the rotations due at now, and returns false if any of them
+// failed.
+func (s *Server) runDue(ctx context.Context, now time.Time) bool {
+	s.mu.Lock()
+	runner, schedules := s.runner, s.schedules
+	if s.started.IsZero() {
+		s.started = now
+	}
+	s.mu.Unlock()
+
+	var (
+		due   []*schema.Rotation
+		names []string
+	)
+	for _, rn := range runner.rotations {
+		sc, ok := schedules[rn.Name]
+		if !ok {
+			continue
+		}
+		rs, err := runner.state.Load(rn.Name)
+		if err != nil {
+			log.Printf("failed to load the state of %s: %v", rn.Name, err)
+			continue
+		}
+		if next := s.next(rn.Name, sc, rs); next.IsZero() || now.Before(next) {
+			continue
+		}
+		due = append(due, rn)
+		names = append(names, rn.Name)
+	}
+	if len(due) == 0 {
+		return true
+	}
+
+	log.Printf("running the scheduled rotations: %s", strings.Join(names, ", "))
+	scheduled := *runner
+	scheduled.rotations = due
+	// The secrets of the run are redacted until its report and notifications
+	// have been written, so that they don't pile up in a long-running server.
+	scheduled.redaction = &secrets.Scope{}
+	var root *reporting.R
+	ok := reporting.Run(func(rptr *reporting.R) {
+		root = rptr
+		scheduled.RunContext(ctx, rptr)
+	}, reporting.WithParallelism(s.Parallelism))
+	scheduled.redaction.Close()
+
+	// Only a successful run is saved, so that a rotation which failed runs
+	// again after a backoff rather than on its next schedule.
+	for _, result := range root.Result().Children {
+		if outcome := result.Outcome(); outcome == reporting.Error || outcome == reporting.Cancelled {
+			s.fail(result.Name, now)
+			continue
+		}
+		s.succeed(runner.state, result.Name, now)
+	}
+	return ok
+}
+
+// next returns when the rotation is due. A rotation which failed is due after
+// its backoff, and one which has never run is due right away, or at the first
+// time of its cron schedule after the schedules were first checked.
+func (s *Server) next(name string, sc schedule.Schedule, rs *state.Rotation) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f := s.failures[name]; f != nil {
+		return f.retryAt
+	}
+	if last := lastRun(rs); !last.IsZero() {
+		return sc.Next(last)
+	}
+	if _, ok := sc.(schedule.Every); ok {
+		return s.started
+	}
+	return sc.Next(s.started)
+}
+
+// fail schedules the rotation which failed at now to run again after a
+// backoff.
+func (s *Server) fail(name string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures == nil {
+		s.failures = make(map[string]*failure)
+	}
+	f := s.failures[name]
+	if f == nil {
+		f = &failure{}
+		s.failures[name] = f
+	}
+	f.count++
+	backoff := retryBackoff
+	for i := 1; i < f.count && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	f.retryAt = now.Add(backoff)
+	log.Printf("%s failed and runs again at %s", name, f.retryAt.Format(time.RFC3339))
+}
+
+// succeed saves that the rotation scheduled at now succeeded.
+func (s *Server) succeed(store state.Store, name string, now time.Time) {
+	s.mu.Lock()
+	delete(s.failures, name)
+	s.mu.Unlock()
+
+	rs, err := store.Load(name)
+	if err == nil {
+		rs.ScheduledAt = &now
+		err = store.Save(name, rs)
+	}
+	if err != nil {
+		log.Printf("failed to save the state of %s: %v", name, err)
+	}
+}
+
+// lastRun returns when the rotation last ran successfully, either by serve or
+// rotate.
+func lastRun(rs *state.Rotation) time.Time {
+	var last time.Time
+	if rs.ScheduledAt != nil {
+		last = *rs.ScheduledAt
+	}
+	for i := len(rs.History) - 1; i >= 0; i-- {
+		run := rs.History[i]
+		if run.Status == reporting.Error || run.Status == reporting.Cancelled {
+			continue
+		}
+		if run.StartedAt.After(last) {
+			last = run.StartedAt
+		}
+		break
+	}
+	return last
+}
@@ -0,0 +1,306 @@
+package revolver
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mockedfp "github.com/grezar/revolver/provider/from/mocks"
+	mockedtp "github.com/grezar/revolver/provider/to/mocks"
+	"github.com/grezar/revolver/reporting"
+	"github.com/grezar/revolver/schedule"
+	"github.com/grezar/revolver/schema"
+	"github.com/grezar/revolver/secrets"
+	"github.com/grezar/revolver/state"
+)
+
+func TestServer_runDue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	mockedFromOperator := mockedfp.NewMockOperator(ctrl)
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+	unscheduledFromOperator := mockedfp.NewMockOperator(ctrl)
+	unscheduledToOperator := mockedtp.NewMockOperator(ctrl)
+	expectedSecrets := secrets.Secrets{
+		"KEY_ID": "key1",
+		"SECRET": "serve test secret",
+	}
+
+	// The scheduled rotation runs twice, each with an advance dry-run.
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator").Times(4)
+	// The context of a run of serve carries the scope of its secrets.
+	mockedFromOperator.EXPECT().Do(gomock.Any(), true).Return(nil, nil).Times(2)
+	mockedFromOperator.EXPECT().Do(gomock.Any(), false).Return(expectedSecrets, nil).Times(2)
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").Times(4)
+	mockedToOperator.EXPECT().Do(gomock.Any(), true).Times(2)
+	mockedToOperator.EXPECT().Do(gomock.Any(), false).DoAndReturn(func(ctx context.Context, dryRun bool) error {
+		if got := secrets.GetSecrets(ctx); !reflect.DeepEqual(got, expectedSecrets) {
+			t.Errorf("secrets = %v, want %v", got, expectedSecrets)
+		}
+		return nil
+	}).Times(2)
+
+	schedules := map[string]schedule.Schedule{
+		"Scheduled": schedule.Every(time.Hour),
+	}
+	s := &Server{
+		runner: &Runner{
+			rotations: []*schema.Rotation{
+				{
+					Name: "Scheduled",
+					From: schema.From{
+						Provider: "Mock",
+						Spec:     schema.FromProviderSpec{Operator: mockedFromOperator},
+					},
+					To: []*schema.To{
+						{
+							Provider: "Mock",
+							Spec:     schema.ToProviderSpec{Operator: mockedToOperator},
+						},
+					},
+				},
+				{
+					Name: "Unscheduled",
+					From: schema.From{
+						Provider: "Mock",
+						Spec:     schema.FromProviderSpec{Operator: unscheduledFromOperator},
+					},
+					To: []*schema.To{
+						{
+							Provider: "Mock",
+							Spec:     schema.ToProviderSpec{Operator: unscheduledToOperator},
+						},
+					},
+				},
+			},
+			state: store,
+		},
+		schedules: schedules,
+	}
+
+	now := time.Now()
+	for _, tc := range []struct {
+		at      time.Time
+		wantRan bool
+	}{
+		// Never ran before.
+		{at: now, wantRan: true},
+		{at: now.Add(30 * time.Minute), wantRan: false},
+		{at: now.Add(61 * time.Minute), wantRan: true},
+		{at: now.Add(90 * time.Minute), wantRan: false},
+	} {
+		before, err := store.Load("Scheduled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok := s.runDue(context.Background(), tc.at); !ok {
+			t.Errorf("runDue(%s) failed", tc.at)
+		}
+		after, err := store.Load("Scheduled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ran := len(after.History) > len(before.History); ran != tc.wantRan {
+			t.Errorf("runDue(%s) ran = %t, want %t", tc.at, ran, tc.wantRan)
+		}
+	}
+
+	// A restarted server sees when the rotation ran from the state.
+	restarted := &Server{runner: s.runner, schedules: schedules}
+	restarted.runDue(context.Background(), now.Add(90*time.Minute))
+
+	// The secrets of the runs are no longer redacted once they are over.
+	if got := secrets.Redact("serve test secret"); got != "serve test secret" {
+		t.Errorf("Redact() = %q, want the secret of a finished run as is", got)
+	}
+}
+
+func TestServer_runDue_Failure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	mockedFromOperator := mockedfp.NewMockOperator(ctrl)
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+
+	// The rotation fails twice, then succeeds.
+	mockedFromOperator.EXPECT().Summary().Return("mocked from operator").AnyTimes()
+	mockedFromOperator.EXPECT().Do(gomock.Any(), true).Return(nil, nil).Times(3)
+	gomock.InOrder(
+		mockedFromOperator.EXPECT().Do(gomock.Any(), false).Return(nil, errors.New("rejected")).Times(2),
+		mockedFromOperator.EXPECT().Do(gomock.Any(), false).Return(nil, nil),
+	)
+	mockedToOperator.EXPECT().Summary().Return("mocked to operator").AnyTimes()
+	mockedToOperator.EXPECT().Do(gomock.Any(), true).Times(3)
+
+	s := &Server{
+		runner: &Runner{
+			rotations: []*schema.Rotation{
+				{
+					Name: "Scheduled",
+					From: schema.From{
+						Provider: "Mock",
+						Spec:     schema.FromProviderSpec{Operator: mockedFromOperator},
+					},
+					To: []*schema.To{
+						{
+							Provider: "Mock",
+							Spec:     schema.ToProviderSpec{Operator: mockedToOperator},
+						},
+					},
+				},
+			},
+			state: store,
+		},
+		schedules: map[string]schedule.Schedule{
+			"Scheduled": schedule.Every(30 * 24 * time.Hour),
+		},
+	}
+
+	now := time.Now()
+	for _, tc := range []struct {
+		at      time.Time
+		wantRan bool
+		wantOK  bool
+	}{
+		{at: now, wantRan: true, wantOK: false},
+		{at: now.Add(4 * time.Minute), wantRan: false, wantOK: true},
+		{at: now.Add(5 * time.Minute), wantRan: true, wantOK: false},
+		// The backoff doubles.
+		{at: now.Add(14 * time.Minute), wantRan: false, wantOK: true},
+		{at: now.Add(15 * time.Minute), wantRan: true, wantOK: true},
+		// The rotation succeeded, so it waits for its schedule.
+		{at: now.Add(30 * time.Minute), wantRan: false, wantOK: true},
+	} {
+		before, err := store.Load("Scheduled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok := s.runDue(ctx, tc.at); ok != tc.wantOK {
+			t.Errorf("runDue(%s) = %t, want %t", tc.at, ok, tc.wantOK)
+		}
+		after, err := store.Load("Scheduled")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ran := len(after.History) > len(before.History); ran != tc.wantRan {
+			t.Errorf("runDue(%s) ran = %t, want %t", tc.at, ran, tc.wantRan)
+		}
+	}
+
+	rs, err := store.Load("Scheduled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(15 * time.Minute); rs.ScheduledAt == nil || !rs.ScheduledAt.Equal(want) {
+		t.Errorf("ScheduledAt = %v, want %s", rs.ScheduledAt, want)
+	}
+}
+
+func TestServer_runDue_Cron(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+
+	// The rotation which has never run waits for the next time of its
+	// cron schedule.
+	mockedFromOperator := mockedfp.NewMockOperator(ctrl)
+	mockedToOperator := mockedtp.NewMockOperator(ctrl)
+
+	sc, err := schedule.Parse("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		runner: &Runner{
+			rotations: []*schema.Rotation{
+				{
+					Name: "Scheduled",
+					From: schema.From{
+						Provider: "Mock",
+						Spec:     schema.FromProviderSpec{Operator: mockedFromOperator},
+					},
+					To: []*schema.To{
+						{
+							Provider: "Mock",
+							Spec:     schema.ToProviderSpec{Operator: mockedToOperator},
+						},
+					},
+				},
+			},
+			state: store,
+		},
+		schedules: map[string]schedule.Schedule{"Scheduled": sc},
+	}
+
+	started := time.Date(2022, 1, 1, 10, 0, 0, 0, time.Local)
+	for _, at := range []time.Time{started, started.Add(time.Hour)} {
+		if ok := s.runDue(context.Background(), at); !ok {
+			t.Errorf("runDue(%s) failed", at)
+		}
+	}
+	if got, want := s.next("Scheduled", sc, &state.Rotation{}), started.Add(23*time.Hour); !got.Equal(want) {
+		t.Errorf("next() = %s, want %s", got, want)
+	}
+}
+
+func TestLastRun(t *testing.T) {
+	scheduledAt := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	startedAt := scheduledAt.Add(time.Minute)
+
+	tests := []struct {
+		name string
+		rs   *state.Rotation
+		want time.Time
+	}{
+		{name: "never", rs: &state.Rotation{}, want: time.Time{}},
+		{name: "scheduled", rs: &state.Rotation{ScheduledAt: &scheduledAt}, want: scheduledAt},
+		{
+			name: "rotated after scheduled",
+			rs: &state.Rotation{
+				ScheduledAt: &scheduledAt,
+				History:     []*state.Run{{StartedAt: startedAt}},
+			},
+			want: startedAt,
+		},
+		{
+			name: "rotated before scheduled",
+			rs: &state.Rotation{
+				ScheduledAt: &startedAt,
+				History:     []*state.Run{{StartedAt: scheduledAt}},
+			},
+			want: startedAt,
+		},
+		{
+			name: "failed after scheduled",
+			rs: &state.Rotation{
+				ScheduledAt: &scheduledAt,
+				History: []*state.Run{
+					{StartedAt: startedAt, Status: reporting.Error},
+				},
+			},
+			want: scheduledAt,
+		},
+		{
+			name: "rotated before failed",
+			rs: &state.Rotation{
+				History: []*state.Run{
+					{StartedAt: scheduledAt, Status: reporting.Success},
+					{StartedAt: startedAt, Status: reporting.Error},
+				},
+			},
+			want: scheduledAt,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastRun(tt.rs); !got.Equal(tt.want) {
+				t.Errorf("lastRun() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxHistory is the number of runs kept in the history of a rotation.
+const MaxHistory = 100
+
+// Store persists the state of rotations between runs. Implementations must be
+// safe for concurrent use.
+type Store interface {
+	Load(rotation string) (*Rotation, error)
+	Save(rotation string, r *Rotation) error
+	// Rotations returns the names of the rotations in the store in
+	// lexicographical order.
+	Rotations() ([]string, error)
+}
+
+// Rotation is the state of a rotation.
+type Rotation struct {
+	RetiringSecrets []*RetiringSecret `json:"retiringSecrets,omitempty"`
+	History         []*Run            `json:"history,omitempty"`
+	// Checkpoint is the progress of an interrupted run. It is cleared once the
+	// run completes.
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+	// ScheduledAt is when serve last started the rotation on its schedule.
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+}
+
+// Checkpoint holds the secrets issued in a run and the destinations they have
+// been distributed to, so that an interrupted run can be resumed without
+// issuing another secret.
+type Checkpoint struct {
+	CreatedAt time.Time `json:"createdAt"`
+	SecretID  string    `json:"secretId,omitempty"`
+	// EncryptedSecrets is the issued secrets encrypted by secrets.Encrypt.
+	EncryptedSecrets []byte `json:"encryptedSecrets"`
+	// Superseded is the IDs of the previous secrets to clean up after the
+	// distribution, if any.
+	Superseded []string `json:"superseded,omitempty"`
+	// CleanedUp is true if the previous secrets have already been cleaned up.
+	CleanedUp bool `json:"cleanedUp"`
+	// Destinations is the IDs of the destinations already updated.
+	Destinations []string `json:"destinations,omitempty"`
+}
+
+// AddRun appends the run to the history, dropping the oldest runs beyond
+// MaxHistory.
+func (r *Rotation) AddRun(run *Run) {
+	r.History = append(r.History, run)
+	if len(r.History) > MaxHistory {
+		r.History = r.History[len(r.History)-MaxHistory:]
+	}
+}
+
+// Run is a record of a rotation run. It must not contain any secret.
+type Run struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Status     string    `json:"status"`
+	// SecretID is the non-sensitive identifier of the issued secret, like an
+	// access key ID.
+	SecretID  string            `json:"secretId,omitempty"`
+	Providers []*ProviderResult `json:"providers,omitempty"`
+}
+
+// ProviderResult is the outcome of a provider in a run.
+type ProviderResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Summary string `json:"summary,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Attempts is the number of calls to the provider including retries.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// RetiringSecret is a previous secret which has been replaced by a new one and
+// is going to be deactivated and then deleted in later runs.
+type RetiringSecret struct {
+	ID            string     `json:"id"`
+	SupersededAt  time.Time  `json:"supersededAt"`
+	DeactivatedAt *time.Time `json:"deactivatedAt,omitempty"`
+}
+
+// FileStore is a Store which keeps the state of all rotations in a JSON file.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		path: path,
+	}
+}
+
+func (s *FileStore) Load(rotation string) (*Rotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rotations, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := rotations[rotation]; ok {
+		return r, nil
+	}
+	return &Rotation{}, nil
+}
+
+func (s *FileStore) Save(rotation string, r *Rotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rotations, err := s.read()
+	if err != nil {
+		return err
+	}
+	rotations[rotation] = r
+	return s.write(rotations)
+}
+
+func (s *FileStore) Rotations() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rotations, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range rotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *FileStore) read() (map[string]*Rotation, error) {
+	rotations := make(map[string]*Rotation)
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return rotations, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &rotations); err != nil {
+		return nil, err
+	}
+	return rotations, nil
+}
+
+// write replaces the file atomically so that the state is never left half
+// written.
+func (s *FileStore) write(rotations map[string]*Rotation) error {
+	b, err := json.MarshalIndent(rotations, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,79 @@
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s := NewFileStore(path)
+
+	got, err := s.Load("rotation1")
+	if err != nil {
+		t.Fatalf("FileStore.Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, &Rotation{}) {
+		t.Errorf("FileStore.Load() = %v, want empty state", got)
+	}
+
+	supersededAt := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	want := &Rotation{
+		RetiringSecrets: []*RetiringSecret{
+			{
+				ID:           "AAAAAAAAAAAA",
+				SupersededAt: supersededAt,
+			},
+		},
+	}
+	if err := s.Save("rotation1", want); err != nil {
+		t.Fatalf("FileStore.Save() error = %v", err)
+	}
+	if err := s.Save("rotation2", &Rotation{}); err != nil {
+		t.Fatalf("FileStore.Save() error = %v", err)
+	}
+
+	got, err = NewFileStore(path).Load("rotation1")
+	if err != nil {
+		t.Fatalf("FileStore.Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FileStore.Load() = %v, want %v", got, want)
+	}
+}
+
+func TestFileStore_Rotations(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	for _, name := range []string{"rotation2", "rotation1"} {
+		if err := s.Save(name, &Rotation{}); err != nil {
+			t.Fatalf("FileStore.Save() error = %v", err)
+		}
+	}
+
+	got, err := s.Rotations()
+	if err != nil {
+		t.Fatalf("FileStore.Rotations() error = %v", err)
+	}
+	want := []string{"rotation1", "rotation2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FileStore.Rotations() = %v, want %v", got, want)
+	}
+}
+
+func TestRotation_AddRun(t *testing.T) {
+	r := &Rotation{}
+	for i := 0; i < MaxHistory+10; i++ {
+		r.AddRun(&Run{
+			SecretID: fmt.Sprintf("KEY%d", i),
+		})
+	}
+	if len(r.History) != MaxHistory {
+		t.Fatalf("len(Rotation.History) = %d, want %d", len(r.History), MaxHistory)
+	}
+	if r.History[0].SecretID != "KEY10" {
+		t.Errorf("the oldest run = %s, want KEY10", r.History[0].SecretID)
+	}
+}
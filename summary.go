@@ -0,0 +1,203 @@
+package revolver
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/grezar/revolver/plan"
+	"github.com/grezar/revolver/reporting"
+)
+
+// FailureKind tells how a rotation failed, and so whether anything has to be
+// cleaned up by hand.
+type FailureKind int
+
+const (
+	// NotFailed is the kind of a rotation which succeeded or was skipped.
+	NotFailed FailureKind = iota
+	// Failed is the kind of a rotation which failed without leaving a new
+	// secret behind, e.g. as it was rolled back.
+	Failed
+	// FromFailed is the kind of a rotation whose from provider failed, so
+	// no secret was issued.
+	FromFailed
+	// PartiallyDistributed is the kind of a rotation which issued a new
+	// secret but failed to update some destinations with it.
+	PartiallyDistributed
+)
+
+// alreadyUpdated is the summary of a destination which was updated before the
+// run was interrupted.
+const alreadyUpdated = "already updated"
+
+// RotationFailure is a rotation which failed.
+type RotationFailure struct {
+	Name string
+	Kind FailureKind
+	// Step is the name of the first step which failed, and Err its error.
+	Step string
+	Err  string
+	// Stale is the destinations which were not updated with the new secret
+	// in a partially distributed rotation.
+	Stale []string
+}
+
+// Summary is the outcome of a run of rotations.
+type Summary struct {
+	Rotations int
+	Failures  []*RotationFailure
+}
+
+// Summarize returns the summary of the result of a run of rotations.
+func Summarize(result *reporting.Result) *Summary {
+	s := &Summary{Rotations: len(result.Children)}
+	for _, rn := range result.Children {
+		if f := rotationFailure(rn, result.DryRun); f != nil {
+			s.Failures = append(s.Failures, f)
+		}
+	}
+	return s
+}
+
+// Kind returns the kind of the failures: PartiallyDistributed if any rotation
+// left stale destinations, FromFailed if all failed rotations failed to issue
+// a secret, and Failed otherwise.
+func (s *Summary) Kind() FailureKind {
+	kind := NotFailed
+	for _, f := range s.Failures {
+		switch {
+		case f.Kind == PartiallyDistributed:
+			return PartiallyDistributed
+		case kind == NotFailed:
+			kind = f.Kind
+		case kind != f.Kind:
+			kind = Failed
+		}
+	}
+	return kind
+}
+
+// Write writes the failed rotations and what is left to be done about them.
+func (s *Summary) Write(w io.Writer) {
+	if len(s.Failures) == 0 {
+		fmt.Fprintf(w, "All %d rotations succeeded\n", s.Rotations)
+		return
+	}
+	fmt.Fprintf(w, "%d of %d rotations failed:\n", len(s.Failures), s.Rotations)
+	for _, f := range s.Failures {
+		fmt.Fprintf(w, "  %s: %s\n", f.Name, f.describe())
+	}
+}
+
+func (f *RotationFailure) describe() string {
+	switch f.Kind {
+	case FromFailed:
+		return fmt.Sprintf("%s failed and no secret was issued: %s", f.Step, f.Err)
+	case PartiallyDistributed:
+		if len(f.Stale) == 0 {
+			return fmt.Sprintf("a new secret was issued and the rollback failed. Manual cleanup is needed: %s failed: %s", f.Step, f.Err)
+		}
+		return fmt.Sprintf("a new secret was issued but %s %s not updated. Manual cleanup is needed: %s failed: %s",
+			strings.Join(f.Stale, ", "), pluralVerb(len(f.Stale)), f.Step, f.Err)
+	default:
+		return fmt.Sprintf("%s failed: %s", f.Step, f.Err)
+	}
+}
+
+func pluralVerb(n int) string {
+	if n == 1 {
+		return "was"
+	}
+	return "were"
+}
+
+// rotationFailure returns how the rotation failed, or nil if it didn't.
+func rotationFailure(rn *reporting.Result, dryRun bool) *RotationFailure {
+	if outcome := rn.Outcome(); outcome != reporting.Error && outcome != reporting.Cancelled {
+		return nil
+	}
+	f := &RotationFailure{Name: rn.Name, Kind: Failed}
+	for _, step := range rn.Children {
+		if step.Status == reporting.Error || step.Status == reporting.Cancelled {
+			f.Step, f.Err = step.Name, step.Err
+			break
+		}
+	}
+
+	var from *reporting.Result
+	for _, step := range rn.Children {
+		if strings.HasPrefix(step.Name, "From/") {
+			from = step
+			break
+		}
+	}
+	if from == nil || dryRun {
+		return f
+	}
+
+	switch from.Status {
+	case reporting.Error, reporting.Cancelled:
+		// The from provider may have issued a secret before a later call,
+		// like the cleanup of the previous one, failed.
+		if issued(from) {
+			for _, step := range rn.Children {
+				if strings.HasPrefix(step.Name, "To/") {
+					f.Stale = append(f.Stale, step.Name)
+				}
+			}
+			f.Kind = PartiallyDistributed
+			return f
+		}
+		// A from provider which wasn't called failed to resume.
+		if from.Status == reporting.Cancelled || from.Attempts > 0 {
+			f.Kind = FromFailed
+		}
+		return f
+	case reporting.Created, reporting.Success:
+	default:
+		return f
+	}
+
+	var (
+		rolledBack     bool
+		rollbackFailed *reporting.Result
+	)
+	for _, step := range rn.Children {
+		switch {
+		case strings.HasPrefix(step.Name, "To/"):
+			if !reporting.Succeeded(step.Status) && step.Summary != alreadyUpdated {
+				f.Stale = append(f.Stale, step.Name)
+			}
+		case strings.HasPrefix(step.Name, "Rollback/"):
+			rolledBack = true
+			if !reporting.Succeeded(step.Status) && rollbackFailed == nil {
+				rollbackFailed = step
+			}
+		}
+	}
+	// The destinations are restored and the new secret is revoked when a
+	// transactional rotation is rolled back, unless the rollback fails.
+	if rolledBack {
+		f.Stale = nil
+		if rollbackFailed != nil {
+			f.Kind = PartiallyDistributed
+			f.Step, f.Err = rollbackFailed.Name, rollbackFailed.Err
+		}
+		return f
+	}
+	if len(f.Stale) > 0 {
+		f.Kind = PartiallyDistributed
+	}
+	return f
+}
+
+// issued returns true if the from provider reported to have created a secret.
+func issued(from *reporting.Result) bool {
+	for _, c := range from.Changes {
+		if c.Action == plan.Create {
+			return true
+		}
+	}
+	return false
+}
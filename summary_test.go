@@ -0,0 +1,131 @@
+package revolver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grezar/revolver/plan"
+	"github.com/grezar/revolver/reporting"
+)
+
+func TestSummarize(t *testing.T) {
+	step := func(name, status string) *reporting.Result {
+		r := &reporting.Result{Name: name, Status: status, Attempts: 1}
+		if status == reporting.Error {
+			r.Err = "fake error"
+		}
+		return r
+	}
+	rotation := func(name string, steps ...*reporting.Result) *reporting.Result {
+		return &reporting.Result{Name: name, Children: steps}
+	}
+	cleanupFailed := step("From/AWSIAMUser", reporting.Error)
+	cleanupFailed.Changes = []*plan.Change{{Action: plan.Create, Resource: "access key of user"}}
+
+	tests := []struct {
+		name      string
+		rotations []*reporting.Result
+		dryRun    bool
+		wantKind  FailureKind
+		want      string
+	}{
+		{
+			name: "All rotations succeeded",
+			rotations: []*reporting.Result{
+				rotation("a", step("From/Mock", reporting.Created), step("To/Mock", reporting.Updated)),
+				rotation("b", step("From/Mock", reporting.NotDue)),
+			},
+			wantKind: NotFailed,
+			want:     "All 2 rotations succeeded\n",
+		},
+		{
+			name: "The from provider failed",
+			rotations: []*reporting.Result{
+				rotation("a", step("From/Mock", reporting.Error), step("To/Mock", reporting.Skip)),
+				rotation("b", step("From/Mock", reporting.Created), step("To/Mock", reporting.Updated)),
+			},
+			wantKind: FromFailed,
+			want: `1 of 2 rotations failed:
+  a: From/Mock failed and no secret was issued: fake error
+`,
+		},
+		{
+			name: "A destination failed",
+			rotations: []*reporting.Result{
+				rotation("a", step("From/Mock", reporting.Created), step("To/Mock1", reporting.Updated), step("To/Mock2", reporting.Error)),
+				rotation("b", step("From/Mock", reporting.Error)),
+			},
+			wantKind: PartiallyDistributed,
+			want: `2 of 2 rotations failed:
+  a: a new secret was issued but To/Mock2 was not updated. Manual cleanup is needed: To/Mock2 failed: fake error
+  b: From/Mock failed and no secret was issued: fake error
+`,
+		},
+		{
+			name: "The cleanup of the previous secret failed after a new one was issued",
+			rotations: []*reporting.Result{
+				rotation("a", cleanupFailed, step("To/Mock1", reporting.Skip), step("To/Mock2", reporting.Skip)),
+			},
+			wantKind: PartiallyDistributed,
+			want: `1 of 1 rotations failed:
+  a: a new secret was issued but To/Mock1, To/Mock2 were not updated. Manual cleanup is needed: From/AWSIAMUser failed: fake error
+`,
+		},
+		{
+			name: "A transactional rotation was rolled back",
+			rotations: []*reporting.Result{
+				rotation("a", step("From/Mock", reporting.Created), step("To/Mock1", reporting.Updated), step("To/Mock2", reporting.Error),
+					step("Rollback/To/Mock2", reporting.Success), step("Rollback/To/Mock1", reporting.Success), step("Rollback/From/Mock", reporting.Success)),
+				rotation("b", step("From/Mock", reporting.Error)),
+			},
+			wantKind: Failed,
+			want: `2 of 2 rotations failed:
+  a: To/Mock2 failed: fake error
+  b: From/Mock failed and no secret was issued: fake error
+`,
+		},
+		{
+			name: "The rollback of a transactional rotation failed",
+			rotations: []*reporting.Result{
+				rotation("a", step("From/Mock", reporting.Created), step("To/Mock1", reporting.Updated), step("To/Mock2", reporting.Error),
+					step("Rollback/To/Mock2", reporting.Success), step("Rollback/To/Mock1", reporting.Error), step("Rollback/From/Mock", reporting.Success)),
+			},
+			wantKind: PartiallyDistributed,
+			want: `1 of 1 rotations failed:
+  a: a new secret was issued and the rollback failed. Manual cleanup is needed: Rollback/To/Mock1 failed: fake error
+`,
+		},
+		{
+			name: "No secret is issued in dry-run mode",
+			rotations: []*reporting.Result{
+				rotation("a", step("From/Mock", reporting.WouldCreate), step("To/Mock", reporting.Error)),
+			},
+			dryRun:   true,
+			wantKind: Failed,
+			want: `1 of 1 rotations failed:
+  a: To/Mock failed: fake error
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, rn := range tt.rotations {
+				for _, step := range rn.Children {
+					if step.Status == reporting.Error {
+						rn.Status = reporting.Error
+					}
+				}
+			}
+			s := Summarize(&reporting.Result{DryRun: tt.dryRun, Children: tt.rotations})
+			if got := s.Kind(); got != tt.wantKind {
+				t.Errorf("Summary.Kind() = %d, want %d", got, tt.wantKind)
+			}
+			var b bytes.Buffer
+			s.Write(&b)
+			if diff := cmp.Diff(tt.want, b.String()); diff != "" {
+				t.Errorf("Summary.Write() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Package validation checks the specs of providers, so that mistakes in the
+// configuration are reported before any rotation starts.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is a problem with a field of a spec.
+type FieldError struct {
+	// Field is the path of the field in the spec by the YAML keys, like
+	// "secrets[0].category", or empty if the problem is with the whole spec.
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// The fields are named by their YAML keys, which default to the lower
+	// cased field names as in go-yaml.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return strings.ToLower(f.Name)
+		}
+		return name
+	})
+	return v
+}
+
+// Struct checks the validate tags of the fields of the spec.
+func Struct(spec interface{}) []*FieldError {
+	err := validate.Struct(spec)
+	if err == nil {
+		return nil
+	}
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return []*FieldError{{Message: err.Error()}}
+	}
+
+	var errs []*FieldError
+	for _, fe := range ves {
+		// The namespace starts with the name of the spec type.
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		errs = append(errs, &FieldError{Field: field, Message: message(fe)})
+	}
+	return errs
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	}
+	return fmt.Sprintf("failed on the %s rule", fe.Tag())
+}